@@ -16,6 +16,8 @@ type Artifact interface {
 	Type() ArtifactType
 	Digests() map[DigestType]string
 	SetDigests(digests map[DigestType]string)
+	Signature() []byte
+	SetSignature(signature []byte)
 	Content() io.Reader
 }
 
@@ -39,6 +41,7 @@ type artifact struct {
 	normaliseNameFn normaliseNameFn
 	artifactType    ArtifactType
 	digests         map[DigestType]string
+	signature       []byte
 	content         []byte
 }
 
@@ -99,6 +102,14 @@ func (a *artifact) Digests() map[DigestType]string {
 	return a.digests
 }
 
+func (a *artifact) SetSignature(signature []byte) {
+	a.signature = signature
+}
+
+func (a *artifact) Signature() []byte {
+	return a.signature
+}
+
 func (a *artifact) Content() io.Reader {
 	return bytes.NewReader(a.content)
 }
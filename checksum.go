@@ -0,0 +1,261 @@
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Checksum contains a single entry of a
+// checksum file, e.g., SHA256SUMS
+type Checksum struct {
+	Name   string
+	Type   DigestType
+	Digest string
+}
+
+// ChecksumFileName returns the conventional name of a checksum
+// file for the given digest type, e.g., SHA256SUMS
+func ChecksumFileName(digestType DigestType) string {
+	return fmt.Sprintf("%sSUMS", strings.ToUpper(string(digestType)))
+}
+
+// WriteChecksums writes the digests of the given type for all
+// the artifacts in the manifest in a format that can be checked
+// using the coreutils tools, e.g., sha256sum -c
+func WriteChecksums(writer io.Writer, digestType DigestType, manifest Manifester) error {
+	for _, artifact := range manifest.Artifacts() {
+		digest, ok := artifact.Digests[digestType]
+		if !ok {
+			return fmt.Errorf("artifact: %s has no digest of type: %s", artifact.Name, digestType)
+		}
+		prefix, name := "", artifact.Name
+		// coreutils escapes file names containing a backslash or
+		// a newline and marks the line with a leading backslash
+		if strings.ContainsAny(name, "\\\n") {
+			prefix = "\\"
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		_, err := fmt.Fprintf(writer, "%s%s  %s\n", prefix, digest, name)
+		if err != nil {
+			return errors.Wrap(err, "failed to write checksum")
+		}
+	}
+	return nil
+}
+
+var (
+	// Tagged lines as produced by BSD md5/sha256 and coreutils --tag
+	// e.g., SHA256 (myproject_v1.0.0.relnotes) = 3739...
+	taggedChecksum = regexp.MustCompile(`^(MD5|SHA1|SHA256|SHA512) \((.*)\) = ([0-9a-fA-F]+)$`)
+
+	// Untagged lines as produced by coreutils, where the separator
+	// is either two spaces (text mode) or a space and an asterisk
+	// (binary mode)
+	untaggedChecksum = regexp.MustCompile(`^([0-9a-fA-F]+) [ *](.*)$`)
+
+	digestTypeByLength = map[int]DigestType{
+		32:  DigestTypeMD5,
+		40:  DigestTypeSHA1,
+		64:  DigestTypeSHA256,
+		128: DigestTypeSHA512,
+	}
+)
+
+// ParseChecksums reads a checksum file in either the coreutils
+// or the BSD tag format. For untagged lines the digest type is
+// derived from the length of the digest.
+func ParseChecksums(reader io.Reader) ([]Checksum, error) {
+	var checksums []Checksum
+
+	scanner := bufio.NewScanner(reader)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+
+		var checksum Checksum
+		if match := taggedChecksum.FindStringSubmatch(line); match != nil {
+			checksum = Checksum{
+				Name:   match[2],
+				Type:   DigestType(strings.ToLower(match[1])),
+				Digest: strings.ToLower(match[3]),
+			}
+		} else if match := untaggedChecksum.FindStringSubmatch(line); match != nil {
+			digestType, ok := digestTypeByLength[len(match[1])]
+			if !ok {
+				return nil, fmt.Errorf("unknown digest length on line: %d", n)
+			}
+			checksum = Checksum{
+				Name:   match[2],
+				Type:   digestType,
+				Digest: strings.ToLower(match[1]),
+			}
+		} else {
+			return nil, fmt.Errorf("malformed checksum on line: %d", n)
+		}
+
+		if escaped {
+			checksum.Name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(checksum.Name)
+		}
+		checksums = append(checksums, checksum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read checksums")
+	}
+
+	return checksums, nil
+}
+
+// ChecksumVerifier provides the interface required for
+// verifying a directory of artifacts against a checksum file
+type ChecksumVerifier interface {
+	// VerifyChecksums asserts that every file listed in the
+	// checksum file exists in the directory and matches its
+	// digest. The verified checksums are returned.
+	VerifyChecksums(directory string, checksums io.Reader) ([]Checksum, error)
+}
+
+var (
+	// ErrUnsafeChecksumName indicates that a name in a checksum
+	// file is a path rather than a file in the directory
+	ErrUnsafeChecksumName = errors.New("unsafe file name in checksum file")
+)
+
+// safeChecksumName returns true if the name is a
+// file directly in the directory being verified
+func safeChecksumName(name string) bool {
+	if name == "" || name == "." || name == ".." || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`)
+}
+
+type checksumVerifier struct {
+	verifier Verifier
+}
+
+// NewChecksumVerifier creates a checksum verifier that
+// uses the provided verifier for comparing digests
+func NewChecksumVerifier(verifier Verifier) ChecksumVerifier {
+	return &checksumVerifier{
+		verifier: verifier,
+	}
+}
+
+// VerifyChecksums using the provided input
+func (cv *checksumVerifier) VerifyChecksums(directory string, checksums io.Reader) ([]Checksum, error) {
+	parsed, err := ParseChecksums(checksums)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, ErrNoDigests
+	}
+
+	absPath, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get absolute path")
+	}
+
+	for _, checksum := range parsed {
+		if !safeChecksumName(checksum.Name) {
+			return nil, errors.Wrapf(ErrUnsafeChecksumName, "%q", checksum.Name)
+		}
+	}
+
+	for _, checksum := range parsed {
+		f, err := os.Open(filepath.Join(absPath, checksum.Name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open file: %s", checksum.Name)
+		}
+		err = cv.verifier.VerifyDigests(map[DigestType]string{checksum.Type: checksum.Digest}, f)
+		f.Close() // nolint: errcheck, gosec
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to verify file: %s", checksum.Name)
+		}
+	}
+
+	return parsed, nil
+}
+
+type checksumSaver struct {
+	directory   string
+	signer      Signer
+	signatory   Signatory
+	digestTypes []DigestType
+}
+
+// NewChecksumSaver creates a saver that writes a checksum file, e.g.,
+// SHA256SUMS, for each of the digest types to the directory. If a
+// signatory is provided each checksum file is also signed and the
+// signature stored next to it, e.g., SHA256SUMS.asc
+func NewChecksumSaver(directory string, signer Signer, signatory Signatory, digestType DigestType, digestTypes ...DigestType) Saver {
+	return &checksumSaver{
+		directory:   directory,
+		signer:      signer,
+		signatory:   signatory,
+		digestTypes: append([]DigestType{digestType}, digestTypes...),
+	}
+}
+
+// Save the checksum files to the file system
+func (cs *checksumSaver) Save(signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return cs.SaveContext(context.Background(), signatures, manifest, artifacts)
+}
+
+// SaveContext saves the checksum files to the file
+// system, writing stops once the context is done
+func (cs *checksumSaver) SaveContext(ctx context.Context, _ [][]byte, manifest Manifester, _ []Artifact) error {
+	absPath, err := filepath.Abs(cs.directory)
+	if err != nil {
+		return errors.Wrap(err, "failed to get absolute path")
+	}
+
+	err = os.MkdirAll(absPath, os.ModePerm)
+	if err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+
+	for _, digestType := range cs.digestTypes {
+		var sums bytes.Buffer
+		err = WriteChecksums(&sums, digestType, manifest)
+		if err != nil {
+			return err
+		}
+
+		name := ChecksumFileName(digestType)
+		err = createAndWriteFile(ctx, bytes.NewReader(sums.Bytes()), absPath, name)
+		if err != nil {
+			return err
+		}
+
+		if cs.signatory == nil {
+			continue
+		}
+		signature, err := cs.signer.Sign(cs.signatory, sums.Bytes())
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign checksums: %s", name)
+		}
+		err = createAndWriteFile(ctx, bytes.NewReader(signature), absPath, signatureName(name, 0))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package release_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksums(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		expect    []release.Checksum
+		expectErr bool
+	}{
+		{
+			name:    "GNU text and binary mode",
+			content: "736db904ad222bf88ee6b8d103fceb8e  a.bin\n5ec1a3cb71c75c52cf23934b137985bd2499bd85 *b.bin\n",
+			expect: []release.Checksum{
+				{Name: "a.bin", Type: release.DigestTypeMD5, Digest: "736db904ad222bf88ee6b8d103fceb8e"},
+				{Name: "b.bin", Type: release.DigestTypeSHA1, Digest: "5ec1a3cb71c75c52cf23934b137985bd2499bd85"},
+			},
+		},
+		{
+			name:    "BSD tag",
+			content: "SHA256 (a.bin) = 373993310775A34F5AD48AAE265DAC65C7ABF420DFBAEF62819E2CF5AAFC64CA\r\n",
+			expect: []release.Checksum{
+				{Name: "a.bin", Type: release.DigestTypeSHA256, Digest: "373993310775a34f5ad48aae265dac65c7abf420dfbaef62819e2cf5aafc64ca"},
+			},
+		},
+		{
+			name:    "GNU escaped name",
+			content: "\\736db904ad222bf88ee6b8d103fceb8e  a\\\\b\\nc\n",
+			expect: []release.Checksum{
+				{Name: "a\\b\nc", Type: release.DigestTypeMD5, Digest: "736db904ad222bf88ee6b8d103fceb8e"},
+			},
+		},
+		{
+			name:      "Unknown digest length",
+			content:   "736db9  a.bin\n",
+			expectErr: true,
+		},
+		{
+			name:      "Malformed",
+			content:   "not a checksum\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := release.ParseChecksums(strings.NewReader(tc.content))
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+		} else {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.expect, got, tc.name)
+		}
+	}
+}
+
+func TestChecksumSaver(t *testing.T) {
+	artifacts := mock.ValidArtifacts()
+	manifest := release.NewManifest(mock.ProjectName, "v1.0.0", mock.ValidSignee(), artifacts)
+	signatory, err := mock.ValidSignatory()
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+
+	err = release.NewSavers([]release.Saver{
+		release.NewFileSystemSaver(dir),
+		release.NewChecksumSaver(dir, release.NewSigner(pgp.DefaultConfig), signatory, release.DigestTypeSHA256, release.DigestTypeSHA512),
+	}).Save([][]byte{[]byte("signature")}, manifest, artifacts)
+	assert.Nil(t, err)
+
+	sums, err := ioutil.ReadFile(path.Join(dir, "SHA256SUMS"))
+	assert.Nil(t, err)
+	assert.Equal(t, "373993310775a34f5ad48aae265dac65c7abf420dfbaef62819e2cf5aafc64ca  myproject_v1.0.0-darwin.amd64.bin\n", string(sums))
+
+	signature, err := ioutil.ReadFile(path.Join(dir, "SHA256SUMS.asc"))
+	assert.Nil(t, err)
+	_, err = release.NewVerifier(pgp.DefaultConfig).VerifySignature(mock.ValidSignee(), sums, signature)
+	assert.Nil(t, err)
+
+	verifier := release.NewChecksumVerifier(release.NewVerifier(pgp.DefaultConfig))
+	for _, name := range []string{"SHA256SUMS", "SHA512SUMS"} {
+		sums, err := ioutil.ReadFile(path.Join(dir, name))
+		assert.Nil(t, err)
+		got, err := verifier.VerifyChecksums(dir, bytes.NewReader(sums))
+		assert.Nil(t, err, name)
+		assert.Len(t, got, 1, name)
+	}
+
+	_, err = verifier.VerifyChecksums(dir, strings.NewReader("736db904ad222bf88ee6b8d103fceb8f  myproject_v1.0.0-darwin.amd64.bin\n"))
+	assert.Error(t, err)
+	_, err = verifier.VerifyChecksums(dir, strings.NewReader("736db904ad222bf88ee6b8d103fceb8e  missing.bin\n"))
+	assert.Error(t, err)
+
+	// Names can't reach outside of the directory
+	for _, name := range []string{"../SHA256SUMS", "/etc/passwd", "sub/file.bin", `sub\file.bin`, ".."} {
+		_, err = verifier.VerifyChecksums(dir, strings.NewReader("736db904ad222bf88ee6b8d103fceb8e  "+name+"\n"))
+		assert.Equal(t, release.ErrUnsafeChecksumName, errors.Cause(err), name)
+	}
+}
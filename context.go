@@ -0,0 +1,235 @@
+package release
+
+import (
+	"context"
+	"io"
+)
+
+// VersionerContext is a versioner that can be cancelled
+type VersionerContext interface {
+	Versioner
+	VersionContext(ctx context.Context) (string, error)
+}
+
+// SigneeContext is a signee whose public
+// key can be fetched with a deadline
+type SigneeContext interface {
+	Signee
+	PublicKeyContext(ctx context.Context) ([]byte, error)
+}
+
+// DeployerContext is a deployer that can be cancelled
+type DeployerContext interface {
+	Deployer
+	DeployContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error
+}
+
+// SaverContext is a saver that can be cancelled
+type SaverContext interface {
+	Saver
+	SaveContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error
+}
+
+// LoaderContext is a loader that can be cancelled
+type LoaderContext interface {
+	Loader
+	LoadContext(ctx context.Context) (signatures [][]byte, manifester Manifester, artifacts []Artifact, err error)
+}
+
+// CreatorContext is a creator that can be cancelled
+type CreatorContext interface {
+	Creator
+	CreateContext(ctx context.Context, signee Signee, signees ...Signee) (manifest Manifester, artifacts []Artifact, err error)
+}
+
+// VerifierContext is a verifier that fetches
+// the public key of signees with a deadline
+type VerifierContext interface {
+	Verifier
+	VerifySignatureContext(ctx context.Context, signee Signee, signed []byte, signature []byte) ([]string, error)
+	VerifyArtifactsContext(ctx context.Context, signee Signee, artifacts []Artifact) error
+}
+
+// ManifestVerifierContext is a manifest verifier that
+// fetches the public key of signees with a deadline
+type ManifestVerifierContext interface {
+	ManifestVerifier
+	VerifyManifestSignatureContext(ctx context.Context, signee Signee, manifest Manifester, signature []byte) ([]string, error)
+}
+
+// The adapters below return implementations that are already
+// context aware as they are. Any other is only called if the
+// context isn't done yet, as it can't be interrupted once called
+
+type versionerContext struct {
+	Versioner
+}
+
+// NewVersionerContext adapts the versioner to a context
+func NewVersionerContext(versioner Versioner) VersionerContext {
+	if v, ok := versioner.(VersionerContext); ok {
+		return v
+	}
+	return &versionerContext{Versioner: versioner}
+}
+
+func (v *versionerContext) VersionContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return v.Version()
+}
+
+type signeeContext struct {
+	Signee
+}
+
+// NewSigneeContext adapts the signee to a context
+func NewSigneeContext(signee Signee) SigneeContext {
+	if s, ok := signee.(SigneeContext); ok {
+		return s
+	}
+	return &signeeContext{Signee: signee}
+}
+
+func (s *signeeContext) PublicKeyContext(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.PublicKey()
+}
+
+type deployerContext struct {
+	Deployer
+}
+
+// NewDeployerContext adapts the deployer to a context
+func NewDeployerContext(deployer Deployer) DeployerContext {
+	if d, ok := deployer.(DeployerContext); ok {
+		return d
+	}
+	return &deployerContext{Deployer: deployer}
+}
+
+func (d *deployerContext) DeployContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Deploy(signatures, manifest, artifacts)
+}
+
+type saverContext struct {
+	Saver
+}
+
+// NewSaverContext adapts the saver to a context
+func NewSaverContext(saver Saver) SaverContext {
+	if s, ok := saver.(SaverContext); ok {
+		return s
+	}
+	return &saverContext{Saver: saver}
+}
+
+func (s *saverContext) SaveContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Save(signatures, manifest, artifacts)
+}
+
+type loaderContext struct {
+	Loader
+}
+
+// NewLoaderContext adapts the loader to a context
+func NewLoaderContext(loader Loader) LoaderContext {
+	if l, ok := loader.(LoaderContext); ok {
+		return l
+	}
+	return &loaderContext{Loader: loader}
+}
+
+func (l *loaderContext) LoadContext(ctx context.Context) ([][]byte, Manifester, []Artifact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	return l.Load()
+}
+
+type creatorContext struct {
+	Creator
+}
+
+// NewCreatorContext adapts the creator to a context
+func NewCreatorContext(creator Creator) CreatorContext {
+	if c, ok := creator.(CreatorContext); ok {
+		return c
+	}
+	return &creatorContext{Creator: creator}
+}
+
+func (c *creatorContext) CreateContext(ctx context.Context, signee Signee, signees ...Signee) (Manifester, []Artifact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return c.Create(signee, signees...)
+}
+
+type verifierContext struct {
+	Verifier
+}
+
+// NewVerifierContext adapts the verifier to a context
+func NewVerifierContext(verifier Verifier) VerifierContext {
+	if v, ok := verifier.(VerifierContext); ok {
+		return v
+	}
+	return &verifierContext{Verifier: verifier}
+}
+
+func (v *verifierContext) VerifySignatureContext(ctx context.Context, signee Signee, signed []byte, signature []byte) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v.VerifySignature(signee, signed, signature)
+}
+
+func (v *verifierContext) VerifyArtifactsContext(ctx context.Context, signee Signee, artifacts []Artifact) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return v.VerifyArtifacts(signee, artifacts)
+}
+
+type manifestVerifierContext struct {
+	ManifestVerifier
+}
+
+// NewManifestVerifierContext adapts the manifest verifier to a context
+func NewManifestVerifierContext(verifier ManifestVerifier) ManifestVerifierContext {
+	if v, ok := verifier.(ManifestVerifierContext); ok {
+		return v
+	}
+	return &manifestVerifierContext{ManifestVerifier: verifier}
+}
+
+func (v *manifestVerifierContext) VerifyManifestSignatureContext(ctx context.Context, signee Signee, manifest Manifester, signature []byte) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v.VerifyManifestSignature(signee, manifest, signature)
+}
+
+// contextReader stops reading once the context is done,
+// so copying a large artifact can be cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,179 @@
+package release_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+type versioner struct{}
+
+func (v *versioner) Version() (string, error) {
+	return "v1.0.0", nil
+}
+
+func TestContextAdapters(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	signee := release.NewSigneeContext(mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.SignerPub, nil))
+	key, err := signee.PublicKeyContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerPub, key)
+	_, err = signee.PublicKeyContext(cancelled)
+	assert.Equal(t, context.Canceled, err)
+
+	versioner := release.NewVersionerContext(&versioner{})
+	version, err := versioner.VersionContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0", version)
+	_, err = versioner.VersionContext(cancelled)
+	assert.Equal(t, context.Canceled, err)
+
+	// Implementations that are context aware are used as they are
+	provided := release.NewProvidedVersion(1, 2, 3)
+	assert.Equal(t, provided, release.NewVersionerContext(provided))
+	saver := release.NewFileSystemSaver(".")
+	assert.Equal(t, saver, release.NewSaverContext(saver))
+	loader := release.NewFileSystemLoader(".")
+	assert.Equal(t, loader, release.NewLoaderContext(loader))
+	deployer := release.NewGithubDeployer("bob", "token")
+	assert.Equal(t, deployer, release.NewDeployerContext(deployer))
+	assert.Equal(t, context.Canceled, release.NewDeployerContext(deployer).DeployContext(cancelled, nil, nil, nil))
+	verifier := release.NewVerifier(nil)
+	assert.Equal(t, verifier, release.NewVerifierContext(verifier))
+	manifestVerifier := release.NewManifestVerifier(nil)
+	assert.Equal(t, manifestVerifier, release.NewManifestVerifierContext(manifestVerifier))
+}
+
+func TestCreateContext(t *testing.T) {
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("some content")), "MyProject", release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+	releaser := release.New("MyProject",
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+	).Add(release.NewDigester(release.DigestTypeSHA256), a)
+
+	// The releaser is context aware, so it is used as it is
+	creator := release.NewCreatorContext(releaser)
+	assert.Equal(t, releaser, creator)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = creator.CreateContext(cancelled, mock.ValidSignee())
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+
+	manifest, artifacts, err := creator.CreateContext(context.Background(), mock.ValidSignee())
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0", manifest.Version())
+	assert.Len(t, artifacts, 1)
+}
+
+// cancellingArtifact cancels the context once its content is read
+type cancellingArtifact struct {
+	release.Artifact
+	cancel context.CancelFunc
+}
+
+func (a *cancellingArtifact) Content() io.Reader {
+	return &cancellingReader{r: a.Artifact.Content(), cancel: a.cancel}
+}
+
+type cancellingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return r.r.Read(p)
+}
+
+func TestCreateContextCancelledWhileReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("some content")), "MyProject", release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+	releaser := release.New("MyProject",
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+	).Add(release.NewDigester(release.DigestTypeSHA256), &cancellingArtifact{Artifact: a, cancel: cancel})
+
+	_, _, err = release.NewCreatorContext(releaser).CreateContext(ctx, mock.ValidSignee())
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+}
+
+func TestSaveLoadContext(t *testing.T) {
+	artifacts := mock.ValidArtifacts()
+	manifest := release.NewManifest("MyProject", "v1.0.0", mock.ValidSignee(), artifacts)
+	signatures := [][]byte{[]byte("some kind of signature")}
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	saver := release.NewSaverContext(release.NewSavers([]release.Saver{release.NewFileSystemSaver(dir)}))
+	err = saver.SaveContext(cancelled, signatures, manifest, artifacts)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	err = saver.SaveContext(context.Background(), signatures, manifest, artifacts)
+	assert.Nil(t, err)
+
+	loader := release.NewLoaderContext(release.NewFileSystemLoader(dir))
+	_, _, _, err = loader.LoadContext(cancelled)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	sigs, _, _, err := loader.LoadContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, signatures, sigs)
+}
+
+func TestSigneeContext(t *testing.T) {
+	server := githubServer(githubKeys, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer server.Close()
+
+	client := release.NewGithubClient(release.GithubEndpoint(server.URL))
+	signees := []release.Signee{
+		release.NewGithubSignee(client, mock.GithubPublicKeyUser, mock.GithubPublicKeyID),
+		release.NewPinnedSignee(release.NewGithubSignee(client, mock.GithubPublicKeyUser, mock.GithubPublicKeyID), mock.SignerFingerPrint),
+	}
+
+	for _, signee := range signees {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start := time.Now()
+		_, err := release.NewSigneeContext(signee).PublicKeyContext(ctx)
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+		assert.True(t, time.Since(start) < time.Second)
+	}
+
+	// Verifying fetches the public key with the same deadline
+	for _, signee := range signees {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start := time.Now()
+		_, err := release.NewVerifierContext(release.NewVerifier(nil)).VerifySignatureContext(ctx, signee, mock.Signed, []byte(testSignature))
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+		assert.True(t, time.Since(start) < time.Second)
+
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start = time.Now()
+		manifest := release.NewManifest("MyProject", "v1.0.0", signee, nil)
+		_, err = release.NewManifestVerifierContext(release.NewManifestVerifier(nil)).VerifyManifestSignatureContext(ctx, signee, manifest, []byte(testSignature))
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+		assert.True(t, time.Since(start) < time.Second)
+	}
+}
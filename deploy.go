@@ -1,10 +1,67 @@
 package release
 
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
 // Deployer defines the operations required for deploying
 // a release
 type Deployer interface {
 	// Deploy the artifacts to the given destination
-	Deploy(signature []byte, manifest Manifester, artifacts []Artifact) error
+	Deploy(signatures [][]byte, manifest Manifester, artifacts []Artifact) error
+}
+
+// NamedDeployer is a deployer with a stable name,
+// which the events of the deployer carry
+type NamedDeployer interface {
+	Deployer
+	// Name of the deployer, e.g., github
+	Name() string
+}
+
+// deployerName returns the name of the deployer, or
+// its position among the deployers if it has none
+func deployerName(deployer Deployer, i int) string {
+	if named, ok := deployer.(NamedDeployer); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("deployer %d", i)
+}
+
+// progressArtifact reports the progress of reading
+// its content, which deployers upload
+type progressArtifact struct {
+	Artifact
+	ctx     context.Context
+	version string
+}
+
+// Content returns a reader that emits upload progress
+func (a *progressArtifact) Content() io.Reader {
+	return NewProgressReader(a.ctx, a.NormalisedName(a.version), 0, a.Artifact.Content())
+}
+
+// withProgress wraps the artifacts so the upload progress of
+// each is emitted, unless they already are
+func withProgress(ctx context.Context, manifester Manifester, artifacts []Artifact) []Artifact {
+	var version string
+	if manifester != nil {
+		version = manifester.Version()
+	}
+	wrapped := make([]Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if _, ok := artifact.(*progressArtifact); !ok {
+			artifact = &progressArtifact{
+				Artifact: artifact,
+				ctx:      ctx,
+				version:  version,
+			}
+		}
+		wrapped = append(wrapped, artifact)
+	}
+	return wrapped
 }
 
 type deployer struct {
@@ -19,17 +76,33 @@ func NewDeployers(deployers []Deployer) Deployer {
 	}
 }
 
-// Deploy the manifest, artifacts and signature
-func (d *deployer) Deploy(signature []byte, manifester Manifester, artifacts []Artifact) error {
-	for _, deps := range d.deployers {
-		err := deps.Deploy(signature, manifester, artifacts)
+// Deploy the manifest, artifacts and signatures
+func (d *deployer) Deploy(signatures [][]byte, manifester Manifester, artifacts []Artifact) error {
+	return d.DeployContext(context.Background(), signatures, manifester, artifacts)
+}
+
+// DeployContext deploys with each deployer in turn until the context is
+// done, the upload progress is emitted as the deployers read the artifacts
+func (d *deployer) DeployContext(ctx context.Context, signatures [][]byte, manifester Manifester, artifacts []Artifact) error {
+	artifacts = withProgress(ctx, manifester, artifacts)
+	for i, deps := range d.deployers {
+		err := NewDeployerContext(deps).DeployContext(ctx, signatures, manifester, artifacts)
 		if err != nil {
 			return err
 		}
+		emit(ctx, Event{
+			Type: EventDeployerFinished,
+			Name: deployerName(deps, i),
+		})
 	}
 	return nil
 }
 
+// Name of the composed deployers
+func (d *deployer) Name() string {
+	return "deployers"
+}
+
 type githubDeployer struct {
 	user  string
 	token string
@@ -44,7 +117,18 @@ func NewGithubDeployer(user, token string) Deployer {
 	}
 }
 
+// Name of the deployer
+func (gd *githubDeployer) Name() string {
+	return "github"
+}
+
 // Deploy the provided data
-func (gd *githubDeployer) Deploy(signature []byte, manifester Manifester, artifacts []Artifact) error {
-	return nil
+func (gd *githubDeployer) Deploy(signatures [][]byte, manifester Manifester, artifacts []Artifact) error {
+	return gd.DeployContext(context.Background(), signatures, manifester, artifacts)
+}
+
+// DeployContext deploys the provided data, the
+// context applies to all requests made
+func (gd *githubDeployer) DeployContext(ctx context.Context, signatures [][]byte, manifester Manifester, artifacts []Artifact) error {
+	return ctx.Err()
 }
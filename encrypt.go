@@ -0,0 +1,284 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stoic-cli/stoic-release/vault"
+)
+
+// Encrypter provides the interface for encrypting
+// artifacts for a set of recipients
+type Encrypter interface {
+	// Encrypt returns a writer that encrypts everything
+	// written to it, it must be closed when done
+	Encrypt(w io.Writer) (io.WriteCloser, error)
+}
+
+// Decrypter provides the interface for decrypting
+// artifacts as one of the recipients
+type Decrypter interface {
+	// Decrypt returns a reader of the plaintext, it
+	// must be closed when done
+	Decrypt(r io.Reader) (io.ReadCloser, error)
+}
+
+// EncryptedArtifact is an artifact whose content has been
+// encrypted, the digests are those of the ciphertext
+type EncryptedArtifact interface {
+	Artifact
+	PlaintextDigests() map[DigestType]string
+}
+
+// EncryptedArtifactExt is appended to the name of encrypted artifacts
+const EncryptedArtifactExt = ".enc"
+
+type pgpEncrypter struct {
+	recipients [][]byte
+	config     *packet.Config
+}
+
+// NewPGPEncrypter creates an encrypter for the
+// armored pgp public keys of the recipients
+func NewPGPEncrypter(config *packet.Config, recipient []byte, recipients ...[]byte) Encrypter {
+	return &pgpEncrypter{
+		recipients: append([][]byte{recipient}, recipients...),
+		config:     config,
+	}
+}
+
+// Encrypt the content for the recipients
+func (e *pgpEncrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	return pgp.Encrypt(w, e.recipients, e.config)
+}
+
+type pgpDecrypter struct {
+	recipient Signatory
+	config    *packet.Config
+}
+
+// NewPGPDecrypter creates a decrypter using the
+// pgp private key of the recipient
+func NewPGPDecrypter(config *packet.Config, recipient Signatory) Decrypter {
+	return &pgpDecrypter{
+		recipient: recipient,
+		config:    config,
+	}
+}
+
+// Decrypt the content
+func (d *pgpDecrypter) Decrypt(r io.Reader) (io.ReadCloser, error) {
+	plaintext, err := pgp.Decrypt(d.recipient.PrivateKey(), r, d.config)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(plaintext), nil
+}
+
+type vaultEncrypter struct {
+	recipients []vault.Recipient
+}
+
+// NewVaultEncrypter creates an encrypter for passphrase
+// and X25519 recipients using a vault stream
+func NewVaultEncrypter(recipient vault.Recipient, recipients ...vault.Recipient) Encrypter {
+	return &vaultEncrypter{
+		recipients: append([]vault.Recipient{recipient}, recipients...),
+	}
+}
+
+// Encrypt the content for the recipients
+func (e *vaultEncrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	return vault.EncryptFor(w, e.recipients...)
+}
+
+type vaultDecrypter struct {
+	identities []vault.Identity
+}
+
+// NewVaultDecrypter creates a decrypter for vault streams
+// using the first identity that is a recipient
+func NewVaultDecrypter(identity vault.Identity, identities ...vault.Identity) Decrypter {
+	return &vaultDecrypter{
+		identities: append([]vault.Identity{identity}, identities...),
+	}
+}
+
+// Decrypt the content
+func (d *vaultDecrypter) Decrypt(r io.Reader) (io.ReadCloser, error) {
+	return vault.DecryptWith(r, d.identities...)
+}
+
+type encryptedArtifact struct {
+	Artifact
+	plaintextDigests map[DigestType]string
+	digests          map[DigestType]string
+	signature        []byte
+	content          []byte
+}
+
+// encryptArtifact creates digests of the plaintext
+// and returns the artifact with encrypted content
+func encryptArtifact(ctx context.Context, encrypter Encrypter, digester Digester, artifact Artifact) (Artifact, error) {
+	plaintextDigests, err := digester.Digest(&contextReader{ctx: ctx, r: artifact.Content()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to digest plaintext")
+	}
+
+	var buf bytes.Buffer
+	w, err := encrypter.Encrypt(&buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt artifact")
+	}
+	_, err = io.Copy(w, &contextReader{ctx: ctx, r: artifact.Content()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt artifact")
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt artifact")
+	}
+
+	return &encryptedArtifact{
+		Artifact:         artifact,
+		plaintextDigests: plaintextDigests,
+		digests:          map[DigestType]string{},
+		content:          buf.Bytes(),
+	}, nil
+}
+
+func (a *encryptedArtifact) NormalisedName(version string) string {
+	return a.Artifact.NormalisedName(version) + EncryptedArtifactExt
+}
+
+func (a *encryptedArtifact) PlaintextDigests() map[DigestType]string {
+	return a.plaintextDigests
+}
+
+func (a *encryptedArtifact) SetDigests(digests map[DigestType]string) {
+	a.digests = digests
+}
+
+func (a *encryptedArtifact) Digests() map[DigestType]string {
+	return a.digests
+}
+
+func (a *encryptedArtifact) SetSignature(signature []byte) {
+	a.signature = signature
+}
+
+func (a *encryptedArtifact) Signature() []byte {
+	return a.signature
+}
+
+func (a *encryptedArtifact) Content() io.Reader {
+	return bytes.NewReader(a.content)
+}
+
+type decryptingLoader struct {
+	loader    Loader
+	decrypter Decrypter
+}
+
+// NewDecryptingLoader creates a loader that decrypts the encrypted
+// artifacts of the release loaded by the provided loader. The
+// ciphertext digests are verified before decrypting and the plaintext
+// digests after, the returned artifacts carry the plaintext digests
+// and no signature, since the artifact signatures cover the ciphertext
+func NewDecryptingLoader(loader Loader, decrypter Decrypter) Loader {
+	return &decryptingLoader{
+		loader:    loader,
+		decrypter: decrypter,
+	}
+}
+
+func (l *decryptingLoader) Load() ([][]byte, Manifester, []Artifact, error) {
+	return l.LoadContext(context.Background())
+}
+
+// LoadContext loads and decrypts the release until the context is done
+func (l *decryptingLoader) LoadContext(ctx context.Context) ([][]byte, Manifester, []Artifact, error) {
+	signatures, manifest, artifacts, err := NewLoaderContext(l.loader).LoadContext(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	manifestArtifacts := manifest.Artifacts()
+	if len(manifestArtifacts) != len(artifacts) {
+		return nil, nil, nil, fmt.Errorf("expected %d artifacts, got: %d", len(manifestArtifacts), len(artifacts))
+	}
+
+	for i, manifestArtifact := range manifestArtifacts {
+		if len(manifestArtifact.PlaintextDigests) == 0 {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+		artifact := artifacts[i]
+		err = verifyDigests(artifact.Digests(), artifact.Content())
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to verify ciphertext: %s", manifestArtifact.Name)
+		}
+
+		plaintext, err := l.decrypt(artifact.Content())
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to decrypt artifact: %s", manifestArtifact.Name)
+		}
+		err = verifyDigests(manifestArtifact.PlaintextDigests, bytes.NewReader(plaintext))
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to verify plaintext: %s", manifestArtifact.Name)
+		}
+
+		artifacts[i] = &decryptedArtifact{
+			Artifact: artifact,
+			digests:  manifestArtifact.PlaintextDigests,
+			content:  plaintext,
+		}
+	}
+
+	return signatures, manifest, artifacts, nil
+}
+
+func (l *decryptingLoader) decrypt(ciphertext io.Reader) ([]byte, error) {
+	r, err := l.decrypter.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint: errcheck
+	return ioutil.ReadAll(r)
+}
+
+type decryptedArtifact struct {
+	Artifact
+	digests   map[DigestType]string
+	signature []byte
+	content   []byte
+}
+
+func (a *decryptedArtifact) SetDigests(digests map[DigestType]string) {
+	a.digests = digests
+}
+
+func (a *decryptedArtifact) Digests() map[DigestType]string {
+	return a.digests
+}
+
+func (a *decryptedArtifact) SetSignature(signature []byte) {
+	a.signature = signature
+}
+
+func (a *decryptedArtifact) Signature() []byte {
+	return a.signature
+}
+
+func (a *decryptedArtifact) Content() io.Reader {
+	return bytes.NewReader(a.content)
+}
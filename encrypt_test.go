@@ -0,0 +1,124 @@
+package release_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stoic-cli/stoic-release/vault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptArtifacts(t *testing.T) {
+	signatory, err := mock.ValidSignatory()
+	assert.Nil(t, err)
+	identity, err := vault.GenerateX25519Identity()
+	assert.Nil(t, err)
+	other, err := vault.GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		encrypter release.Encrypter
+		decrypter release.Decrypter
+		expectErr bool
+	}{
+		{
+			name:      "Vault",
+			encrypter: release.NewVaultEncrypter(identity.Recipient()),
+			decrypter: release.NewVaultDecrypter(identity),
+		},
+		{
+			name:      "PGP",
+			encrypter: release.NewPGPEncrypter(pgp.DefaultConfig, mock.SignerPub),
+			decrypter: release.NewPGPDecrypter(pgp.DefaultConfig, signatory),
+		},
+		{
+			name:      "Not a recipient",
+			encrypter: release.NewVaultEncrypter(identity.Recipient()),
+			decrypter: release.NewVaultDecrypter(other),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "release-")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir) // nolint: errcheck
+
+			a, err := release.NewBinaryArtifact(ioutil.NopCloser(strings.NewReader("confidential")), "MyProject", release.OperatingSystemTypeLinux, release.ArchTypeamd64)
+			assert.Nil(t, err)
+
+			releaser := release.New("MyProject",
+				release.Version(release.NewProvidedVersion(1, 0, 0)),
+				release.Encrypt(tc.encrypter),
+				release.SignArtifacts(signatory),
+				release.Save(release.NewFileSystemSaver(dir)),
+			).Add(release.NewDigester(release.DigestTypeSHA256), a)
+			manifest, artifacts, err := releaser.Create(mock.ValidSignee())
+			assert.Nil(t, err)
+
+			ma := manifest.Artifacts()[0]
+			assert.Equal(t, "myproject_v1.0.0-linux.amd64.bin.enc", ma.Name)
+			assert.NotEmpty(t, ma.PlaintextDigests)
+			assert.NotEqual(t, ma.Digests, ma.PlaintextDigests)
+			assert.Nil(t, releaser.VerifyArtifacts(mock.ValidSignee(), artifacts))
+
+			// Creating the release again encrypts the added
+			// artifacts, not the ciphertext of the last release
+			again, _, err := releaser.Create(mock.ValidSignee())
+			assert.Nil(t, err)
+			assert.Equal(t, ma.Name, again.Artifacts()[0].Name)
+			assert.Equal(t, ma.PlaintextDigests, again.Artifacts()[0].PlaintextDigests)
+
+			err = releaser.Save([][]byte{[]byte("manifest signature")}, manifest, artifacts)
+			assert.Nil(t, err)
+
+			_, _, loaded, err := release.NewDecryptingLoader(release.NewFileSystemLoader(dir), tc.decrypter).Load()
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			content, err := ioutil.ReadAll(loaded[0].Content())
+			assert.Nil(t, err)
+			assert.Equal(t, "confidential", string(content))
+			assert.Equal(t, ma.PlaintextDigests, loaded[0].Digests())
+		})
+	}
+}
+
+func TestDecryptingLoaderDigestMismatch(t *testing.T) {
+	identity, err := vault.GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("confidential")), "MyProject", release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+
+	releaser := release.New("MyProject",
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+		release.Encrypt(release.NewVaultEncrypter(identity.Recipient())),
+		release.Save(release.NewFileSystemSaver(dir)),
+	).Add(release.NewDigester(release.DigestTypeSHA256), a)
+	manifest, artifacts, err := releaser.Create(mock.ValidSignee())
+	assert.Nil(t, err)
+
+	// Record a plaintext digest that doesn't match
+	manifest.(*release.Manifest).ReleaseArtifacts[0].PlaintextDigests[release.DigestTypeSHA256] = "00"
+	err = releaser.Save([][]byte{[]byte("manifest signature")}, manifest, artifacts)
+	assert.Nil(t, err)
+
+	_, _, _, err = release.NewDecryptingLoader(release.NewFileSystemLoader(dir), release.NewVaultDecrypter(identity)).Load()
+	assert.Error(t, err)
+	assert.Contains(t, errors.Cause(err).Error(), "hash mismatch")
+}
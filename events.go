@@ -0,0 +1,142 @@
+package release
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// EventType enumerates the events emitted while releasing
+type EventType string
+
+// nolint
+const (
+	EventArtifactDigested EventType = "artifact_digested"
+	EventSignatureCreated EventType = "signature_created"
+	EventFileWritten      EventType = "file_written"
+	EventUploadProgress   EventType = "upload_progress"
+	EventDeployerFinished EventType = "deployer_finished"
+)
+
+// Event describes a step of the release, only the
+// fields relevant to the type of event are set
+type Event struct {
+	Type EventType
+	// Artifact that was digested or signed, unset
+	// for a signature of the manifest
+	Artifact Artifact
+	Digests  map[DigestType]string
+	// Name of the file written or uploaded, or of the deployer
+	Name string
+	// Bytes written or uploaded so far, and in total if known
+	Bytes int64
+	Total int64
+}
+
+// Observer is notified of each event, it is called synchronously
+// so it should return quickly
+type Observer func(event Event)
+
+// Stage enumerates the stages of the release that can be hooked
+type Stage string
+
+// nolint
+const (
+	StageCreate Stage = "create"
+	StageSave   Stage = "save"
+	StageDeploy Stage = "deploy"
+)
+
+// Hook is run before or after a stage, returning an error vetoes
+// the stage. The manifest is unset before the release is created
+type Hook func(ctx context.Context, stage Stage, manifest Manifester, artifacts []Artifact) error
+
+// Observe adds an observer of the events of the release
+func Observe(observer Observer) Option {
+	return func(args *releaser) {
+		args.observers = append(args.observers, observer)
+	}
+}
+
+// Before adds a hook that is run before the stage
+func Before(stage Stage, hook Hook) Option {
+	return func(args *releaser) {
+		args.before[stage] = append(args.before[stage], hook)
+	}
+}
+
+// After adds a hook that is run after the stage, the
+// stage fails if the hook returns an error
+func After(stage Stage, hook Hook) Option {
+	return func(args *releaser) {
+		args.after[stage] = append(args.after[stage], hook)
+	}
+}
+
+func runHooks(ctx context.Context, hooks []Hook, stage Stage, manifest Manifester, artifacts []Artifact) error {
+	for _, hook := range hooks {
+		err := hook(ctx, stage, manifest, artifacts)
+		if err != nil {
+			return errors.Wrapf(err, "%s vetoed by hook", stage)
+		}
+	}
+	return nil
+}
+
+type observerKey struct{}
+
+// WithObserver returns a context that carries the observer, context
+// aware savers and deployers emit their events to it
+func WithObserver(ctx context.Context, observer Observer) context.Context {
+	if previous, ok := ctx.Value(observerKey{}).(Observer); ok {
+		next := observer
+		observer = func(event Event) {
+			previous(event)
+			next(event)
+		}
+	}
+	return context.WithValue(ctx, observerKey{}, observer)
+}
+
+func emit(ctx context.Context, event Event) {
+	if observer, ok := ctx.Value(observerKey{}).(Observer); ok {
+		observer(event)
+	}
+}
+
+type progressReader struct {
+	ctx   context.Context
+	r     io.Reader
+	name  string
+	read  int64
+	total int64
+}
+
+// NewProgressReader returns a reader that emits upload progress
+// for the named file to the observer of the context, the total
+// may be zero if the size isn't known. The reader stops once the
+// context is done. Deployers composed with NewDeployers already
+// read the content of the artifacts through it
+func NewProgressReader(ctx context.Context, name string, total int64, r io.Reader) io.Reader {
+	return &progressReader{
+		ctx:   ctx,
+		r:     &contextReader{ctx: ctx, r: r},
+		name:  name,
+		total: total,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		emit(p.ctx, Event{
+			Type:  EventUploadProgress,
+			Name:  p.name,
+			Bytes: p.read,
+			Total: p.total,
+		})
+	}
+	return n, err
+}
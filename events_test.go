@@ -0,0 +1,159 @@
+package release_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+type uploader struct{}
+
+func (u *uploader) Deploy(signatures [][]byte, manifest release.Manifester, artifacts []release.Artifact) error {
+	return u.DeployContext(context.Background(), signatures, manifest, artifacts)
+}
+
+func (u *uploader) DeployContext(ctx context.Context, _ [][]byte, _ release.Manifester, artifacts []release.Artifact) error {
+	for _, artifact := range artifacts {
+		_, err := io.Copy(ioutil.Discard, artifact.Content())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *uploader) Name() string {
+	return "uploader"
+}
+
+func TestEvents(t *testing.T) {
+	p := "MyProject"
+	signatory, err := mock.ValidSignatory()
+	assert.Nil(t, err)
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("some content")), p, release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	var events []release.EventType
+	var names []string
+	releaser := release.New(p,
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+		release.SignArtifacts(signatory),
+		release.Save(release.NewFileSystemSaver(dir)),
+		release.Deploy(&uploader{}),
+		release.Observe(func(event release.Event) {
+			events = append(events, event.Type)
+			names = append(names, event.Name)
+		}),
+	).Add(release.NewDigester(release.DigestTypeSHA256), a)
+
+	manifest, artifacts, err := releaser.Create(mock.ValidSignee())
+	assert.Nil(t, err)
+	assert.Equal(t, []release.EventType{release.EventArtifactDigested, release.EventSignatureCreated}, events)
+
+	events = nil
+	_, err = releaser.Sign(signatory, []byte("manifest"))
+	assert.Nil(t, err)
+	assert.Equal(t, []release.EventType{release.EventSignatureCreated}, events)
+
+	// Manifest, its signature, the artifact and its signature
+	events = nil
+	err = releaser.Save([][]byte{[]byte("manifest signature")}, manifest, artifacts)
+	assert.Nil(t, err)
+	assert.Equal(t, []release.EventType{
+		release.EventFileWritten,
+		release.EventFileWritten,
+		release.EventFileWritten,
+		release.EventFileWritten,
+	}, events)
+
+	// The deployer uploads the artifact, without emitting the progress itself
+	events, names = nil, nil
+	err = releaser.Deploy(nil, manifest, artifacts)
+	assert.Nil(t, err)
+	assert.Equal(t, []release.EventType{release.EventUploadProgress, release.EventDeployerFinished}, events)
+	assert.Equal(t, []string{artifacts[0].NormalisedName(manifest.Version()), "uploader"}, names)
+}
+
+func TestHooks(t *testing.T) {
+	p := "MyProject"
+	errMalware := errors.New("malware found")
+	scan := func(_ context.Context, _ release.Stage, _ release.Manifester, artifacts []release.Artifact) error {
+		for _, artifact := range artifacts {
+			content, err := ioutil.ReadAll(artifact.Content())
+			if err != nil {
+				return err
+			}
+			if strings.Contains(string(content), "malware") {
+				return errMalware
+			}
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name      string
+		content   string
+		hook      release.Option
+		expect    error
+		expectErr bool
+	}{
+		{
+			name:    "Clean",
+			content: "some content",
+			hook:    release.Before(release.StageDeploy, scan),
+		},
+		{
+			name:      "Vetoed before deploy",
+			content:   "some malware",
+			hook:      release.Before(release.StageDeploy, scan),
+			expect:    errMalware,
+			expectErr: true,
+		},
+		{
+			name:      "Vetoed after deploy",
+			content:   "some malware",
+			hook:      release.After(release.StageDeploy, scan),
+			expect:    errMalware,
+			expectErr: true,
+		},
+		{
+			name:    "Other stage",
+			content: "some malware",
+			hook:    release.Before(release.StageSave, scan),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader(tc.content)), p, release.ArtifactTypeReleaseNotes)
+			assert.Nil(t, err)
+
+			releaser := release.New(p,
+				release.Version(release.NewProvidedVersion(1, 0, 0)),
+				release.Deploy(&uploader{}),
+				tc.hook,
+			).Add(release.NewDigester(release.DigestTypeSHA256), a)
+			manifest, artifacts, err := releaser.Create(mock.ValidSignee())
+			assert.Nil(t, err)
+
+			err = releaser.Deploy(nil, manifest, artifacts)
+			if tc.expectErr {
+				assert.Equal(t, tc.expect, errors.Cause(err))
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHTTPClient is used for fetching the public keys of signees
+// from anywhere but github, unless the signee has its own client
+var DefaultHTTPClient = &http.Client{Timeout: DefaultGithubTimeout}
+
+// maxResponseSize limits how much of a response is read
+const maxResponseSize = 1 << 22
+
+// HTTPError is returned when a server responds
+// with anything but 200 OK
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+// Error returns the status and message of the response
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s responded with: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s responded with: %d %s: %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// fetcher sends requests with its client, or DefaultHTTPClient
+// if it has none, and adds its authorization to them
+type fetcher struct {
+	client        *http.Client
+	authorization string
+}
+
+// fetch returns the body of the response to a GET request
+// sent with DefaultHTTPClient
+func fetch(ctx context.Context, link string, accept string) ([]byte, error) {
+	return fetcher{}.fetch(ctx, link, accept)
+}
+
+// fetchJSON decodes the response to a GET request
+// sent with DefaultHTTPClient into v
+func fetchJSON(ctx context.Context, link string, v interface{}) error {
+	return fetcher{}.fetchJSON(ctx, link, v)
+}
+
+// fetch returns the body of the response to a GET request, the
+// authorization is sent in the Authorization header, which the
+// client drops when it is redirected to another host
+func (f fetcher) fetch(ctx context.Context, link string, accept string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", accept)
+	if f.authorization != "" {
+		req.Header.Set("Authorization", f.authorization)
+	}
+
+	client := f.client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, errors.Wrap(err, "failed to send request")
+	}
+	defer closeBody(res.Body)
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	if res.StatusCode != http.StatusOK {
+		httpErr := &HTTPError{
+			URL:        link,
+			StatusCode: res.StatusCode,
+		}
+		// Both gitlab and gitea explain errors in a message
+		var message struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &message) == nil {
+			httpErr.Message = message.Message
+		}
+		return nil, httpErr
+	}
+
+	return body, nil
+}
+
+// fetchJSON decodes the response to a GET request into v
+func (f fetcher) fetchJSON(ctx context.Context, link string, v interface{}) error {
+	body, err := f.fetch(ctx, link, "application/json")
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
+
+// DefaultGiteaEndpoint can be overridden if a different access
+// point is required, it is the root of the gitea site, as the
+// armored keys are served outside of the api
+var DefaultGiteaEndpoint = "https://gitea.com"
+
+// GiteaGPGKey unmarshals a gitea response for gpg keys, its public
+// key is only the key packet, without identities or subkeys
+type GiteaGPGKey struct {
+	ID                int64         `json:"id"`
+	PrimaryKeyID      string        `json:"primary_key_id"`
+	KeyID             string        `json:"key_id"`
+	PublicKey         string        `json:"public_key"`
+	Emails            []GPGEmail    `json:"emails"`
+	Subkeys           []GiteaGPGKey `json:"subkeys"`
+	CanSign           bool          `json:"can_sign"`
+	CanEncryptComms   bool          `json:"can_encrypt_comms"`
+	CanEncryptStorage bool          `json:"can_encrypt_storage"`
+	CanCertify        bool          `json:"can_certify"`
+	Verified          bool          `json:"verified"`
+	CreatedAt         time.Time     `json:"created_at,omitempty"`
+	ExpiresAt         time.Time     `json:"expires_at,omitempty"`
+}
+
+// gpgKey returns the key in the form github uses
+func (k GiteaGPGKey) gpgKey() GPGKey {
+	key := GPGKey{
+		ID:                k.ID,
+		KeyID:             k.KeyID,
+		PublicKey:         k.PublicKey,
+		Emails:            k.Emails,
+		CanSign:           k.CanSign,
+		CanEncryptComms:   k.CanEncryptComms,
+		CanEncryptStorage: k.CanEncryptStorage,
+		CanCertify:        k.CanCertify,
+		CreatedAt:         k.CreatedAt,
+		ExpiresAt:         k.ExpiresAt,
+	}
+	for _, subkey := range k.Subkeys {
+		key.Subkeys = append(key.Subkeys, subkey.gpgKey())
+	}
+	return key
+}
+
+// GiteaOption configures how the keys of a gitea signee are fetched
+type GiteaOption func(*giteaClient)
+
+// GiteaHTTPClient sets the http client used for the
+// requests, by default DefaultHTTPClient is used
+func GiteaHTTPClient(client *http.Client) GiteaOption {
+	return func(c *giteaClient) {
+		c.client = client
+	}
+}
+
+// GiteaEndpoint sets the root of the gitea site, by
+// default DefaultGiteaEndpoint is used
+func GiteaEndpoint(endpoint string) GiteaOption {
+	return func(c *giteaClient) {
+		c.endpoint = endpoint
+	}
+}
+
+// GiteaToken authenticates the requests with the access
+// token, e.g., for a gitea that requires signing in
+func GiteaToken(token string) GiteaOption {
+	return func(c *giteaClient) {
+		c.authorization = "token " + token
+	}
+}
+
+type giteaClient struct {
+	fetcher
+	endpoint string
+}
+
+// NewGiteaSignee fetches the public key of the
+// signee with the provided options
+func NewGiteaSignee(user string, key string, options ...GiteaOption) Signee {
+	c := &giteaClient{}
+	for _, option := range options {
+		option(c)
+	}
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: GiteaSigneeType,
+		gitea:      c,
+	}
+}
+
+// giteaPublicKey finds the key among the listed keys of the user, with
+// the same checks as for github, the key itself is then taken from the
+// armored keys gitea serves for the user
+func (s *signee) giteaPublicKey(ctx context.Context) ([]byte, error) {
+	c := s.gitea
+	if c == nil {
+		c = &giteaClient{}
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = DefaultGiteaEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	user := url.PathEscape(s.user)
+
+	var giteaKeys []GiteaGPGKey
+	err := c.fetchJSON(ctx, fmt.Sprintf("%s/api/v1/users/%s/gpg_keys", endpoint, user), &giteaKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users gpg keys")
+	}
+	var keys []GPGKey
+	for _, key := range giteaKeys {
+		keys = append(keys, key.gpgKey())
+	}
+
+	key, err := s.findGPGKey(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	armored, err := c.fetch(ctx, fmt.Sprintf("%s/%s.gpg", endpoint, user), "application/pgp-keys")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users gpg keys")
+	}
+	keyring, err := pgp.ReadKeyRing(armored)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read users gpg keys")
+	}
+	publicKey, err := pgp.FindKey(keyring, key.KeyID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "gpg key: %s", s.key)
+	}
+
+	err = s.checkFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
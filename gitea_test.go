@@ -0,0 +1,151 @@
+package release_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// giteaServer requires the authorization, if any
+func giteaServer(authorization string) *httptest.Server {
+	keys := []release.GiteaGPGKey{
+		{
+			ID:      1,
+			KeyID:   "5331C126086E1C18",
+			CanSign: true,
+			Emails:  []release.GPGEmail{{Email: "alice@example.com"}},
+		},
+		{
+			ID:      2,
+			KeyID:   strings.ToUpper(mock.SignerKeyID),
+			CanSign: true,
+			Emails:  []release.GPGEmail{{Email: "bob@builder.com", Verified: true}},
+			Subkeys: []release.GiteaGPGKey{
+				{
+					KeyID:     "C357BBD557E5F702",
+					ExpiresAt: time.Now().Add(-time.Hour),
+				},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/bob/gpg_keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys) // nolint: errcheck
+	})
+	mux.HandleFunc("/bob.gpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(append(append([]byte{}, mock.AltSignerPub...), mock.SignerPub...)) // nolint: errcheck
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "user does not exist"}`) // nolint: errcheck
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`) // nolint: errcheck
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestGiteaSignee(t *testing.T) {
+	server := giteaServer("")
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		user      string
+		key       string
+		expectErr bool
+	}{
+		{
+			name: "Key ID",
+			user: "bob",
+			key:  mock.SignerKeyID,
+		},
+		{
+			name: "Fingerprint",
+			user: "bob",
+			key:  mock.SignerFingerPrint,
+		},
+		{
+			name:      "Unverified key",
+			user:      "bob",
+			key:       "5331C126086E1C18",
+			expectErr: true,
+		},
+		{
+			name:      "Subkey that can't sign",
+			user:      "bob",
+			key:       "C357BBD557E5F702",
+			expectErr: true,
+		},
+		{
+			name:      "Unknown key",
+			user:      "bob",
+			key:       "0123456789abcdef",
+			expectErr: true,
+		},
+		{
+			name:      "Unknown user",
+			user:      "nobody",
+			key:       mock.SignerKeyID,
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		s := release.NewGiteaSignee(tc.user, tc.key, release.GiteaEndpoint(server.URL))
+		identities, err := verifier.VerifySignature(s, mock.Signed, mock.Signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, identities, tc.name)
+	}
+
+	_, err := release.NewGiteaSignee("nobody", mock.SignerKeyID, release.GiteaEndpoint(server.URL)).PublicKey()
+	httpErr, ok := errors.Cause(err).(*release.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, "user does not exist", httpErr.Message)
+}
+
+func TestGiteaSigneeOptions(t *testing.T) {
+	server := giteaServer("token secret")
+	defer server.Close()
+
+	var requests int
+	client := &http.Client{
+		Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			requests++
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	s := release.NewGiteaSignee("bob", mock.SignerKeyID, release.GiteaEndpoint(server.URL), release.GiteaHTTPClient(client), release.GiteaToken("secret"))
+	_, err := s.PublicKey()
+	assert.Nil(t, err)
+	assert.NotZero(t, requests)
+
+	s = release.NewGiteaSignee("bob", mock.SignerKeyID, release.GiteaEndpoint(server.URL))
+	_, err = s.PublicKey()
+	httpErr, ok := errors.Cause(err).(*release.HTTPError)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	}
+}
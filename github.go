@@ -0,0 +1,335 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GithubClient provides the interface for
+// fetching the gpg keys of github users
+type GithubClient interface {
+	// GPGKeys returns all the gpg keys of the user
+	GPGKeys(ctx context.Context, user string) ([]GPGKey, error)
+}
+
+// GPGKey unmarshals a github response for gpg keys
+// Proudly stolen from: https://github.com/google/go-github
+// They currently don't support the `RawKey` field.
+type GPGKey struct {
+	ID                int64      `json:"id,omitempty"`
+	PrimaryKeyID      int64      `json:"primary_key_id,omitempty"`
+	KeyID             string     `json:"key_id,omitempty"`
+	PublicKey         string     `json:"public_key,omitempty"`
+	RawKey            string     `json:"raw_key,omitempty"`
+	Emails            []GPGEmail `json:"emails,omitempty"`
+	Subkeys           []GPGKey   `json:"subkeys,omitempty"`
+	CanSign           bool       `json:"can_sign,omitempty"`
+	CanEncryptComms   bool       `json:"can_encrypt_comms,omitempty"`
+	CanEncryptStorage bool       `json:"can_encrypt_storage,omitempty"`
+	CanCertify        bool       `json:"can_certify,omitempty"`
+	CreatedAt         time.Time  `json:"created_at,omitempty"`
+	ExpiresAt         time.Time  `json:"expires_at,omitempty"`
+}
+
+// GPGEmail represents the gpg email section
+type GPGEmail struct {
+	Email    string `json:"email,omitempty"`
+	Verified bool   `json:"verified,omitempty"`
+}
+
+// GithubError is returned when github responds with
+// anything but 200 OK
+type GithubError struct {
+	StatusCode int
+	Message    string
+
+	// RateLimited is set when the request was rejected
+	// because the rate limit was exceeded, the limit is
+	// lifted again at the reset time, if known
+	RateLimited bool
+	Reset       time.Time
+}
+
+// Error returns the status and message of the response
+func (e *GithubError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("github responded with: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("github responded with: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// nolint
+const (
+	DefaultGithubTimeout    = 30 * time.Second
+	DefaultGithubRetries    = 3
+	DefaultGithubBackoff    = time.Second
+	DefaultGithubMaxBackoff = time.Minute
+	DefaultGithubMaxPages   = 100
+)
+
+// DefaultGithubClient is used by github signees
+// that are created without a client
+var DefaultGithubClient = NewGithubClient()
+
+// GithubOption configures a github client
+type GithubOption func(*githubClient)
+
+// GithubHTTPClient sets the http client used for the requests
+func GithubHTTPClient(client *http.Client) GithubOption {
+	return func(c *githubClient) {
+		c.client = client
+	}
+}
+
+// GithubEndpoint sets the github api endpoint, by
+// default DefaultGithubAPIEndpoint is used
+func GithubEndpoint(endpoint string) GithubOption {
+	return func(c *githubClient) {
+		c.endpoint = endpoint
+	}
+}
+
+// GithubToken authenticates the requests with the token,
+// which raises the rate limit considerably
+func GithubToken(token string) GithubOption {
+	return func(c *githubClient) {
+		c.token = token
+	}
+}
+
+// GithubRetries sets how many times a rate limited request is
+// retried, the backoff doubles for every retry unless github
+// says how long to wait. A request is not retried if it
+// would have to wait longer than the max backoff
+func GithubRetries(retries int, backoff, maxBackoff time.Duration) GithubOption {
+	return func(c *githubClient) {
+		c.retries = retries
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// GithubMaxPages sets how many pages of a paginated
+// response are followed before giving up
+func GithubMaxPages(pages int) GithubOption {
+	return func(c *githubClient) {
+		c.maxPages = pages
+	}
+}
+
+type githubClient struct {
+	client     *http.Client
+	endpoint   string
+	token      string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	maxPages   int
+}
+
+// NewGithubClient creates a github client, by default it
+// isn't authenticated and times out after DefaultGithubTimeout
+func NewGithubClient(options ...GithubOption) GithubClient {
+	c := &githubClient{
+		client:     &http.Client{Timeout: DefaultGithubTimeout},
+		retries:    DefaultGithubRetries,
+		backoff:    DefaultGithubBackoff,
+		maxBackoff: DefaultGithubMaxBackoff,
+		maxPages:   DefaultGithubMaxPages,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// GPGKeys returns the gpg keys of the user, following
+// the pagination of the response. Only links to the
+// endpoint itself are followed, so the token isn't
+// sent anywhere else
+func (c *githubClient) GPGKeys(ctx context.Context, user string) ([]GPGKey, error) {
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = DefaultGithubAPIEndpoint
+	}
+	base, err := url.Parse(fmt.Sprintf("%s/users/%s/gpg_keys?per_page=100", strings.TrimSuffix(endpoint, "/"), url.PathEscape(user)))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid github endpoint")
+	}
+
+	var keys []GPGKey
+	next := base.String()
+	for pages := 0; next != ""; pages++ {
+		if pages >= c.maxPages {
+			return nil, errors.Errorf("github returned more than %d pages of gpg keys", c.maxPages)
+		}
+
+		var page []GPGKey
+		link, err := c.get(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		next, err = sameOrigin(base, link)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// sameOrigin resolves the link against the base url and
+// rejects it unless it has the same scheme and host
+func sameOrigin(base *url.URL, link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	u, err := base.Parse(link)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid github next page link")
+	}
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return "", errors.Errorf("github next page link points to %s://%s instead of %s://%s",
+			u.Scheme, u.Host, base.Scheme, base.Host)
+	}
+	return u.String(), nil
+}
+
+// get decodes the response into v and returns the
+// link to the next page, if there is one
+func (c *githubClient) get(ctx context.Context, link string, v interface{}) (string, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := c.do(ctx, link)
+		if err != nil {
+			return "", err
+		}
+
+		if res.StatusCode == http.StatusOK {
+			err = json.NewDecoder(res.Body).Decode(v)
+			closeBody(res.Body)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to decode github response")
+			}
+			return nextLink(res.Header.Get("Link")), nil
+		}
+
+		githubErr := responseError(res)
+		closeBody(res.Body)
+		if !githubErr.RateLimited || attempt >= c.retries {
+			return "", githubErr
+		}
+
+		wait := c.wait(attempt, res)
+		if wait > c.maxBackoff {
+			return "", githubErr
+		}
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrap(ctx.Err(), "github request cancelled")
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *githubClient) do(ctx context.Context, link string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create github request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		// Report a cancelled request as such rather than as a url.Error
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, errors.Wrap(err, "failed to send github request")
+	}
+	return res, nil
+}
+
+// wait returns how long to wait before retrying, github
+// either sets Retry-After or the reset of the rate limit,
+// see: https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+func (c *githubClient) wait(attempt int, res *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if reset, ok := rateLimitReset(res); ok && res.Header.Get("X-RateLimit-Remaining") == "0" {
+		return time.Until(reset)
+	}
+	return c.backoff << uint(attempt)
+}
+
+func rateLimitReset(res *http.Response) (time.Time, bool) {
+	secs, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// responseError creates an error from the response, a
+// 403 is only caused by the rate limit if none remain
+func responseError(res *http.Response) *GithubError {
+	githubErr := &GithubError{
+		StatusCode: res.StatusCode,
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(&body) == nil {
+		githubErr.Message = body.Message
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		githubErr.RateLimited = true
+	case http.StatusForbidden:
+		githubErr.RateLimited = res.Header.Get("X-RateLimit-Remaining") == "0" || res.Header.Get("Retry-After") != ""
+	}
+	if githubErr.RateLimited {
+		githubErr.Reset, _ = rateLimitReset(res)
+	}
+	return githubErr
+}
+
+// closeBody drains the body, so the connection can be reused
+func closeBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, body) // nolint: errcheck, gosec
+	body.Close()                  // nolint: errcheck, gosec
+}
+
+// nextLink returns the link to the next page, if any, see:
+// https://docs.github.com/en/rest/guides/using-pagination-in-the-rest-api
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
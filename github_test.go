@@ -0,0 +1,245 @@
+package release_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+// githubKeys is served by the github test server
+var githubKeys = []release.GPGKey{
+	{
+		ID:      1,
+		KeyID:   "0123456789ABCDEF",
+		CanSign: true,
+	},
+	{
+		ID:      2,
+		KeyID:   mock.GithubPublicKeyID,
+		RawKey:  mock.GithubPublicKey,
+		CanSign: true,
+		Emails: []release.GPGEmail{
+			{
+				Email:    "paulbes@example.com",
+				Verified: true,
+			},
+		},
+	},
+}
+
+// githubServer serves the keys of mock.GithubPublicKeyUser, one
+// key per page, the handler may respond first instead
+func githubServer(keys []release.GPGKey, handler http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler != nil {
+			rw := &recorder{ResponseWriter: w}
+			handler(rw, r)
+			if rw.written {
+				return
+			}
+		}
+
+		if r.URL.Path != fmt.Sprintf("/users/%s/gpg_keys", mock.GithubPublicKeyUser) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`) // nolint: errcheck
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < len(keys)-1 {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next", <http://%s%s?page=%d>; rel="last"`,
+				r.Host, r.URL.Path, page+1, r.Host, r.URL.Path, len(keys)-1))
+		}
+		json.NewEncoder(w).Encode(keys[page : page+1]) // nolint: errcheck
+	}))
+}
+
+type recorder struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.written = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func TestGithubClient(t *testing.T) {
+	var requests int
+	testCases := []struct {
+		name      string
+		user      string
+		handler   http.HandlerFunc
+		options   []release.GithubOption
+		expect    int
+		expectErr *release.GithubError
+	}{
+		{
+			name:   "Paginated keys",
+			user:   mock.GithubPublicKeyUser,
+			expect: len(githubKeys),
+		},
+		{
+			name: "Token",
+			user: mock.GithubPublicKeyUser,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "token secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			},
+			options: []release.GithubOption{release.GithubToken("secret")},
+			expect:  len(githubKeys),
+		},
+		{
+			name: "Retry after rate limit",
+			user: mock.GithubPublicKeyUser,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests%2 == 1 {
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+			expect: len(githubKeys),
+		},
+		{
+			name: "Rate limit exceeded",
+			user: mock.GithubPublicKeyUser,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", "1700000000")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "API rate limit exceeded"}`) // nolint: errcheck
+			},
+			expectErr: &release.GithubError{
+				StatusCode:  http.StatusForbidden,
+				Message:     "API rate limit exceeded",
+				RateLimited: true,
+				Reset:       time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name: "Rate limit resets too late",
+			user: mock.GithubPublicKeyUser,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			expectErr: &release.GithubError{
+				StatusCode:  http.StatusTooManyRequests,
+				RateLimited: true,
+			},
+		},
+		{
+			name: "Forbidden",
+			user: mock.GithubPublicKeyUser,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			expectErr: &release.GithubError{
+				StatusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name: "Unknown user",
+			user: "nobody",
+			expectErr: &release.GithubError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Not Found",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		server := githubServer(githubKeys, tc.handler)
+		options := append([]release.GithubOption{
+			release.GithubEndpoint(server.URL),
+			release.GithubRetries(2, time.Millisecond, time.Second),
+		}, tc.options...)
+		keys, err := release.NewGithubClient(options...).GPGKeys(context.Background(), tc.user)
+		server.Close()
+
+		if tc.expectErr != nil {
+			assert.Equal(t, tc.expectErr, errors.Cause(err), tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Len(t, keys, tc.expect, tc.name)
+	}
+}
+
+func TestGithubClientContext(t *testing.T) {
+	server := githubServer(githubKeys, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	client := release.NewGithubClient(
+		release.GithubEndpoint(server.URL),
+		release.GithubRetries(5, time.Second, time.Minute),
+	)
+	start := time.Now()
+	_, err := client.GPGKeys(ctx, mock.GithubPublicKeyUser)
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestGithubClientPagination(t *testing.T) {
+	testCases := []struct {
+		name      string
+		link      string
+		expectErr string
+	}{
+		{
+			name:      "Link to another host",
+			link:      "<http://example.com/users/paulbes/gpg_keys?page=1>; rel=\"next\"",
+			expectErr: "github next page link points to http://example.com instead of",
+		},
+		{
+			name:      "Link to another scheme",
+			link:      "<https://%s/users/paulbes/gpg_keys?page=1>; rel=\"next\"",
+			expectErr: "github next page link points to https://",
+		},
+		{
+			name:      "Endless pages",
+			link:      "</users/paulbes/gpg_keys?page=0>; rel=\"next\"",
+			expectErr: "github returned more than 3 pages of gpg keys",
+		},
+	}
+
+	for _, tc := range testCases {
+		server := githubServer(githubKeys, func(w http.ResponseWriter, r *http.Request) {
+			link := tc.link
+			if strings.Contains(link, "%s") {
+				link = fmt.Sprintf(link, r.Host)
+			}
+			w.Header().Set("Link", link)
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(githubKeys[:1]) // nolint: errcheck
+		})
+		client := release.NewGithubClient(
+			release.GithubEndpoint(server.URL),
+			release.GithubToken("secret"),
+			release.GithubMaxPages(3),
+		)
+		_, err := client.GPGKeys(context.Background(), mock.GithubPublicKeyUser)
+		server.Close()
+
+		if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.expectErr, tc.name)
+		}
+	}
+}
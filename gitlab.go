@@ -0,0 +1,155 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
+
+// DefaultGitlabAPIEndpoint can be overridden if a different access
+// point is required, e.g., a self-hosted gitlab
+var DefaultGitlabAPIEndpoint = "https://gitlab.com/api/v4"
+
+// GitlabUser unmarshals the parts of a gitlab
+// user that are needed for verifying a key
+type GitlabUser struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	PublicEmail string `json:"public_email"`
+}
+
+// GitlabGPGKey unmarshals a gitlab response for gpg keys
+type GitlabGPGKey struct {
+	ID  int64  `json:"id"`
+	Key string `json:"key"`
+}
+
+// GitlabOption configures how the keys of a gitlab signee are fetched
+type GitlabOption func(*gitlabClient)
+
+// GitlabHTTPClient sets the http client used for the
+// requests, by default DefaultHTTPClient is used
+func GitlabHTTPClient(client *http.Client) GitlabOption {
+	return func(c *gitlabClient) {
+		c.client = client
+	}
+}
+
+// GitlabEndpoint sets the gitlab api endpoint, by
+// default DefaultGitlabAPIEndpoint is used
+func GitlabEndpoint(endpoint string) GitlabOption {
+	return func(c *gitlabClient) {
+		c.endpoint = endpoint
+	}
+}
+
+// GitlabToken authenticates the requests with the personal
+// access token, e.g., for a self-hosted gitlab that doesn't
+// list its users publicly
+func GitlabToken(token string) GitlabOption {
+	return func(c *gitlabClient) {
+		c.authorization = "Bearer " + token
+	}
+}
+
+type gitlabClient struct {
+	fetcher
+	endpoint string
+}
+
+// NewGitlabSignee fetches the public key of the signee, the
+// username or numeric ID, with the provided options
+func NewGitlabSignee(user string, key string, options ...GitlabOption) Signee {
+	c := &gitlabClient{}
+	for _, option := range options {
+		option(c)
+	}
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: GitlabSigneeType,
+		gitlab:     c,
+	}
+}
+
+// gitlabPublicKey finds the key among the keys of the user, which is
+// either the numeric ID or the username. Gitlab doesn't tell which
+// emails of a key are verified, only public emails are, so the key
+// must have an identity with the public email of the user
+func (s *signee) gitlabPublicKey(ctx context.Context) ([]byte, error) {
+	c := s.gitlab
+	if c == nil {
+		c = &gitlabClient{}
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = DefaultGitlabAPIEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	user, err := c.user(ctx, endpoint, s.user)
+	if err != nil {
+		return nil, err
+	}
+	if user.PublicEmail == "" {
+		return nil, fmt.Errorf("gitlab user: %s has no public email to verify gpg key: %s", s.user, s.key)
+	}
+
+	var keys []GitlabGPGKey
+	err = c.fetchJSON(ctx, fmt.Sprintf("%s/users/%d/gpg_keys", endpoint, user.ID), &keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users gpg keys")
+	}
+
+	for _, key := range keys {
+		keyring, err := pgp.ReadKeyRing([]byte(key.Key))
+		if err != nil {
+			continue
+		}
+		publicKey, err := pgp.FindKey(keyring, s.key)
+		if err != nil {
+			continue
+		}
+
+		emails, err := pgp.Emails(publicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read gpg key: %s", s.key)
+		}
+		for _, email := range emails {
+			if strings.EqualFold(email, user.PublicEmail) {
+				return publicKey, nil
+			}
+		}
+		return nil, fmt.Errorf("gpg key: %s is not verified for user: %s", s.key, s.user)
+	}
+
+	return nil, fmt.Errorf("gpg key: %s not found for user: %s", s.key, s.user)
+}
+
+func (c *gitlabClient) user(ctx context.Context, endpoint, user string) (*GitlabUser, error) {
+	id, err := strconv.ParseInt(user, 10, 64)
+	if err != nil {
+		var users []GitlabUser
+		err = c.fetchJSON(ctx, fmt.Sprintf("%s/users?username=%s", endpoint, url.QueryEscape(user)), &users)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find gitlab user")
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("gitlab user: %s not found", user)
+		}
+		id = users[0].ID
+	}
+
+	u := &GitlabUser{}
+	err = c.fetchJSON(ctx, fmt.Sprintf("%s/users/%d", endpoint, id), u)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get gitlab user")
+	}
+	return u, nil
+}
@@ -0,0 +1,161 @@
+package release_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// gitlabServer requires the authorization, if any
+func gitlabServer(authorization string) *httptest.Server {
+	users := map[string]release.GitlabUser{
+		"1": {ID: 1, Username: "bob", PublicEmail: "bob@builder.com"},
+		"2": {ID: 2, Username: "eve", PublicEmail: "eve@example.com"},
+		"3": {ID: 3, Username: "mallory"},
+	}
+	keys := []release.GitlabGPGKey{
+		{ID: 1, Key: string(mock.AltSignerPub)},
+		{ID: 2, Key: string(mock.SignerPub)},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+		var found []release.GitlabUser
+		for _, u := range users {
+			if u.Username == r.URL.Query().Get("username") {
+				found = append(found, u)
+			}
+		}
+		json.NewEncoder(w).Encode(found) // nolint: errcheck
+	})
+	mux.HandleFunc("/api/v4/users/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v4/users/")
+		keysPath := strings.HasSuffix(id, "/gpg_keys")
+		id = strings.TrimSuffix(id, "/gpg_keys")
+		u, ok := users[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "404 User Not Found"}`) // nolint: errcheck
+			return
+		}
+		if keysPath {
+			json.NewEncoder(w).Encode(keys) // nolint: errcheck
+			return
+		}
+		json.NewEncoder(w).Encode(u) // nolint: errcheck
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`) // nolint: errcheck
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+// roundTripper sends a request with the function
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestGitlabSignee(t *testing.T) {
+	server := gitlabServer("")
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		user      string
+		key       string
+		expectErr bool
+	}{
+		{
+			name: "Username and key ID",
+			user: "bob",
+			key:  mock.SignerKeyID,
+		},
+		{
+			name: "User ID and fingerprint",
+			user: "1",
+			key:  mock.SignerFingerPrint,
+		},
+		{
+			name:      "Key without the public email",
+			user:      "eve",
+			key:       mock.SignerKeyID,
+			expectErr: true,
+		},
+		{
+			name:      "User without public email",
+			user:      "mallory",
+			key:       mock.SignerKeyID,
+			expectErr: true,
+		},
+		{
+			name:      "Unknown key",
+			user:      "bob",
+			key:       "0123456789abcdef",
+			expectErr: true,
+		},
+		{
+			name:      "Unknown user",
+			user:      "nobody",
+			key:       mock.SignerKeyID,
+			expectErr: true,
+		},
+		{
+			name:      "Unknown user ID",
+			user:      "4",
+			key:       mock.SignerKeyID,
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		s := release.NewGitlabSignee(tc.user, tc.key, release.GitlabEndpoint(server.URL+"/api/v4"))
+		identities, err := verifier.VerifySignature(s, mock.Signed, mock.Signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, identities, tc.name)
+	}
+}
+
+func TestGitlabSigneeOptions(t *testing.T) {
+	server := gitlabServer("Bearer secret")
+	defer server.Close()
+
+	var requests int
+	client := &http.Client{
+		Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			requests++
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	s := release.NewGitlabSignee("bob", mock.SignerKeyID, release.GitlabEndpoint(server.URL+"/api/v4"), release.GitlabHTTPClient(client), release.GitlabToken("secret"))
+	_, err := s.PublicKey()
+	assert.Nil(t, err)
+	assert.NotZero(t, requests)
+
+	s = release.NewGitlabSignee("bob", mock.SignerKeyID, release.GitlabEndpoint(server.URL+"/api/v4"))
+	_, err = s.PublicKey()
+	httpErr, ok := errors.Cause(err).(*release.HTTPError)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	}
+}
@@ -0,0 +1,344 @@
+package gpgagent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1" // nolint: gosec
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Implements the parts of the Assuan protocol required for
+// signing with gpg-agent, see:
+// https://www.gnupg.org/documentation/manuals/assuan/
+
+// nolint
+var (
+	ErrUnsupportedKey  = errors.New("unsupported key type")
+	ErrUnsupportedHash = errors.New("unsupported hash")
+)
+
+// Hash algorithm identifiers as used by libgcrypt
+var hashAlgorithms = map[crypto.Hash]int{
+	crypto.SHA1:   2,
+	crypto.SHA256: 8,
+	crypto.SHA384: 9,
+	crypto.SHA512: 10,
+	crypto.SHA224: 11,
+}
+
+// DefaultSocket asks gpgconf for the path of the
+// agent socket, an empty homedir uses the default
+func DefaultSocket(homedir string) (string, error) {
+	args := []string{"--list-dirs", "agent-socket"}
+	if len(homedir) > 0 {
+		args = append([]string{"--homedir", homedir}, args...)
+	}
+	out, err := exec.Command("gpgconf", args...).Output() // nolint: gosec
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find agent socket")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Error is returned when the agent responds with ERR
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gpg-agent: %d %s", e.Code, e.Message)
+}
+
+// Client is a connection to gpg-agent
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial connects to the agent listening on the socket
+func Dial(socket string) (*Client, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to gpg-agent")
+	}
+	c := &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	// The agent greets us with an OK
+	_, err = c.response()
+	if err != nil {
+		conn.Close() // nolint: errcheck, gosec
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close the connection to the agent
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Transact sends a command and returns the data
+// the agent responds with
+func (c *Client) Transact(command string) ([]byte, error) {
+	_, err := fmt.Fprintf(c.conn, "%s\n", command)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send command")
+	}
+	return c.response()
+}
+
+func (c *Client) response() ([]byte, error) {
+	var data bytes.Buffer
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read response")
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK "):
+			return data.Bytes(), nil
+		case strings.HasPrefix(line, "ERR "):
+			fields := strings.SplitN(strings.TrimPrefix(line, "ERR "), " ", 2)
+			code, _ := strconv.Atoi(fields[0])
+			e := &Error{Code: code}
+			if len(fields) > 1 {
+				e.Message = fields[1]
+			}
+			return nil, e
+		case strings.HasPrefix(line, "D "):
+			data.Write(unescape(strings.TrimPrefix(line, "D ")))
+		case strings.HasPrefix(line, "INQUIRE "):
+			// We have nothing to offer, the agent
+			// will use its defaults
+			_, err = fmt.Fprint(c.conn, "END\n")
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to answer inquiry")
+			}
+		case strings.HasPrefix(line, "S "), strings.HasPrefix(line, "#"):
+			// Status and comment lines are informational
+		default:
+			return nil, fmt.Errorf("unexpected response: %s", line)
+		}
+	}
+}
+
+// unescape decodes the percent escaping used for data lines
+func unescape(in string) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(in); i++ {
+		if in[i] == '%' && i+2 < len(in) {
+			b, err := hex.DecodeString(in[i+1 : i+3])
+			if err == nil {
+				out.Write(b)
+				i += 2
+				continue
+			}
+		}
+		out.WriteByte(in[i])
+	}
+	return out.Bytes()
+}
+
+// HaveKey returns whether the agent has the secret
+// key with the given keygrip
+func (c *Client) HaveKey(keygrip string) (bool, error) {
+	_, err := c.Transact(fmt.Sprintf("HAVEKEY %s", keygrip))
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Sign asks the agent to sign the digest with the key of the
+// given keygrip, the signature is returned as an s-expression
+func (c *Client) Sign(keygrip string, hash crypto.Hash, digest []byte) ([]byte, error) {
+	algorithm, ok := hashAlgorithms[hash]
+	if !ok {
+		return nil, ErrUnsupportedHash
+	}
+	for _, command := range []string{
+		"RESET",
+		fmt.Sprintf("SIGKEY %s", keygrip),
+		fmt.Sprintf("SETHASH %d %s", algorithm, strings.ToUpper(hex.EncodeToString(digest))),
+	} {
+		_, err := c.Transact(command)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to send: %s", strings.Fields(command)[0])
+		}
+	}
+	signature, err := c.Transact("PKSIGN")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign")
+	}
+	return signature, nil
+}
+
+// Keygrip returns the keygrip gpg-agent uses to identify a key as
+// calculated by libgcrypt, it is the SHA-1 of the modulus for RSA keys
+// and of the curve parameters and the point for ECDSA keys
+func Keygrip(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		// The modulus is stored as a signed integer, so it gets
+		// a leading zero when the high bit is set
+		n := key.N.Bytes()
+		if len(n) > 0 && n[0]&0x80 != 0 {
+			n = append([]byte{0}, n...)
+		}
+		grip := sha1.Sum(n) // nolint: gosec
+		return strings.ToUpper(hex.EncodeToString(grip[:])), nil
+	case *ecdsa.PublicKey:
+		// Each parameter is hashed as an s-expression and the points
+		// are uncompressed, the curves of crypto/elliptic have a = -3
+		params := key.Curve.Params()
+		h := sha1.New() // nolint: gosec
+		for _, param := range []struct {
+			name  string
+			value []byte
+		}{
+			{"p", params.P.Bytes()},
+			{"a", new(big.Int).Sub(params.P, big.NewInt(3)).Bytes()},
+			{"b", params.B.Bytes()},
+			{"g", elliptic.Marshal(key.Curve, params.Gx, params.Gy)}, // nolint: staticcheck
+			{"n", params.N.Bytes()},
+			{"q", elliptic.Marshal(key.Curve, key.X, key.Y)}, // nolint: staticcheck
+		} {
+			fmt.Fprintf(h, "(1:%s%d:%s)", param.name, len(param.value), param.value)
+		}
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+	default:
+		return "", errors.Wrapf(ErrUnsupportedKey, "%T", publicKey)
+	}
+}
+
+// Signer implements crypto.Signer by delegating
+// to the key held by gpg-agent
+type Signer struct {
+	socket  string
+	keygrip string
+	public  crypto.PublicKey
+}
+
+// NewSigner creates a signer for the public key, the key
+// must be known by the agent listening on the socket
+func NewSigner(socket string, publicKey crypto.PublicKey) (*Signer, error) {
+	keygrip, err := Keygrip(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		socket:  socket,
+		keygrip: keygrip,
+		public:  publicKey,
+	}, nil
+}
+
+// Public returns the public key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign the digest using the agent, a new connection is made for each
+// signature so that a restarted agent doesn't break the signer
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	client, err := Dial(s.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close() // nolint: errcheck
+
+	sexp, err := client.Sign(s.keygrip, opts.HashFunc(), digest)
+	if err != nil {
+		return nil, err
+	}
+	values, err := signatureValues(sexp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := s.public.(type) {
+	case *rsa.PublicKey:
+		sig, ok := values["s"]
+		if !ok {
+			return nil, fmt.Errorf("rsa signature value missing")
+		}
+		// The value may carry a leading zero, like the modulus,
+		// while the signature must be padded to the modulus size
+		sig = bytes.TrimLeft(sig, "\x00")
+		size := (key.N.BitLen() + 7) / 8
+		if len(sig) > size {
+			return nil, fmt.Errorf("rsa signature too long")
+		}
+		return append(make([]byte, size-len(sig)), sig...), nil
+	case *ecdsa.PublicKey:
+		r, okR := values["r"]
+		sig, okS := values["s"]
+		if !okR || !okS {
+			return nil, fmt.Errorf("ecdsa signature values missing")
+		}
+		// A crypto.Signer returns ECDSA signatures ASN.1 encoded
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(r),
+			S: new(big.Int).SetBytes(sig),
+		})
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedKey, "%T", s.public)
+	}
+}
+
+// signatureValues reads the values of a canonical s-expression of the
+// form: (7:sig-val(3:rsa(1:s3:...))) or (7:sig-val(5:ecdsa(1:r...)(1:s...)))
+func signatureValues(sexp []byte) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	var tokens [][]byte
+	for i := 0; i < len(sexp); {
+		switch sexp[i] {
+		case '(':
+			tokens = nil
+			i++
+		case ')':
+			if len(tokens) == 2 {
+				values[string(tokens[0])] = tokens[1]
+			}
+			tokens = nil
+			i++
+		default:
+			colon := bytes.IndexByte(sexp[i:], ':')
+			if colon < 1 {
+				return nil, fmt.Errorf("malformed s-expression")
+			}
+			length, err := strconv.Atoi(string(sexp[i : i+colon]))
+			if err != nil || i+colon+1+length > len(sexp) {
+				return nil, fmt.Errorf("malformed s-expression")
+			}
+			start := i + colon + 1
+			tokens = append(tokens, sexp[start:start+length])
+			i = start + length
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,120 @@
+package gpgagent_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/gpgagent"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// startAgent imports the mock signer into a throwaway homedir, which
+// starts an agent for it, and returns the homedir and socket of that agent
+func startAgent(t *testing.T) (string, string, func()) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not found, skipping")
+	}
+
+	// Keep the path short, unix sockets have a limited path length
+	dir, err := ioutil.TempDir("", "gpg")
+	assert.Nil(t, err)
+
+	cmd := exec.Command("gpg", "--homedir", dir, "--batch", "--import")
+	cmd.Stdin = bytes.NewReader(mock.SignerPriv)
+	out, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(out))
+
+	socket, err := gpgagent.DefaultSocket(dir)
+	assert.Nil(t, err)
+
+	return dir, socket, func() {
+		exec.Command("gpgconf", "--homedir", dir, "--kill", "gpg-agent").Run() // nolint: errcheck
+		os.RemoveAll(dir)                                                      // nolint: errcheck
+	}
+}
+
+func signerPublicKey(t *testing.T) *rsa.PublicKey {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(mock.SignerPub))
+	assert.Nil(t, err)
+	return keyring[0].PrimaryKey.PublicKey.(*rsa.PublicKey)
+}
+
+func TestKeygrip(t *testing.T) {
+	// As reported by: gpg --with-keygrip -K
+	grip, err := gpgagent.Keygrip(signerPublicKey(t))
+	assert.Nil(t, err)
+	assert.Equal(t, "B998119A96F2E847A27B8390402C78EC1364A904", grip)
+
+	_, err = gpgagent.Keygrip(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	assert.Equal(t, gpgagent.ErrUnsupportedKey, errors.Cause(err))
+}
+
+func TestSigner(t *testing.T) {
+	_, socket, stop := startAgent(t)
+	defer stop()
+
+	client, err := gpgagent.Dial(socket)
+	assert.Nil(t, err)
+	have, err := client.HaveKey("B998119A96F2E847A27B8390402C78EC1364A904")
+	assert.Nil(t, err)
+	assert.True(t, have)
+	have, err = client.HaveKey("0000000000000000000000000000000000000000")
+	assert.Nil(t, err)
+	assert.False(t, have)
+	assert.Nil(t, client.Close())
+
+	signer, err := gpgagent.NewSigner(socket, signerPublicKey(t))
+	assert.Nil(t, err)
+	digest := sha256.Sum256(mock.Signed)
+	signature, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	assert.Nil(t, err)
+	assert.Nil(t, rsa.VerifyPKCS1v15(signerPublicKey(t), crypto.SHA256, digest[:], signature))
+
+	_, err = signer.Sign(nil, digest[:], crypto.MD5)
+	assert.Equal(t, gpgagent.ErrUnsupportedHash, err)
+}
+
+func TestECDSASigner(t *testing.T) {
+	dir, socket, stop := startAgent(t)
+	defer stop()
+
+	out, err := exec.Command("gpg", "--homedir", dir, "--batch", "--pinentry-mode", "loopback", "--passphrase", "",
+		"--quick-add-key", mock.SignerFingerPrint, "nistp256/ecdsa", "sign").CombinedOutput()
+	assert.Nil(t, err, string(out))
+	armored, err := exec.Command("gpg", "--homedir", dir, "--armor", "--export", mock.SignerFingerPrint).Output()
+	assert.Nil(t, err)
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	assert.Nil(t, err)
+	key, ok := keyring[0].SigningKey(time.Now())
+	assert.True(t, ok)
+	publicKey, err := pgp.SignerPublicKey(key.PublicKey)
+	assert.Nil(t, err)
+
+	// The subkey is new, so its keygrip is the one gpg reports
+	grip, err := gpgagent.Keygrip(publicKey)
+	assert.Nil(t, err)
+	colons, err := exec.Command("gpg", "--homedir", dir, "--with-keygrip", "--with-colons", "-k", mock.SignerFingerPrint).Output()
+	assert.Nil(t, err)
+	assert.Contains(t, string(colons), fmt.Sprintf("grp:::::::::%s:", grip))
+
+	signer, err := gpgagent.NewSigner(socket, publicKey)
+	assert.Nil(t, err)
+	digest := sha256.Sum256(mock.Signed)
+	signature, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	assert.Nil(t, err)
+	assert.True(t, ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature))
+}
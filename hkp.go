@@ -0,0 +1,88 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHKPEndpoint can be overridden if a different
+// keyserver is required
+var DefaultHKPEndpoint = "https://keys.openpgp.org"
+
+// HKPOption configures how the key of a hkp signee is looked up
+type HKPOption func(*hkpClient)
+
+// HKPHTTPClient sets the http client used for the
+// requests, by default DefaultHTTPClient is used
+func HKPHTTPClient(client *http.Client) HKPOption {
+	return func(c *hkpClient) {
+		c.client = client
+	}
+}
+
+// HKPEndpoint sets the keyserver, by
+// default DefaultHKPEndpoint is used
+func HKPEndpoint(endpoint string) HKPOption {
+	return func(c *hkpClient) {
+		c.endpoint = endpoint
+	}
+}
+
+type hkpClient struct {
+	fetcher
+	endpoint string
+}
+
+// NewHKPSignee looks up the key of the signee, a key ID or
+// fingerprint, on a keyserver with the provided options
+func NewHKPSignee(user string, key string, options ...HKPOption) Signee {
+	c := &hkpClient{}
+	for _, option := range options {
+		option(c)
+	}
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: HKPSigneeType,
+		hkp:        c,
+	}
+}
+
+// hkpPublicKey looks up the key, a key ID or fingerprint, on the
+// keyserver, see:
+// https://datatracker.ietf.org/doc/html/draft-shaw-openpgp-hkp-00#section-3
+func (s *signee) hkpPublicKey(ctx context.Context) ([]byte, error) {
+	c := s.hkp
+	if c == nil {
+		c = &hkpClient{}
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = DefaultHKPEndpoint
+	}
+
+	keyID, fingerprint, err := parseKeyID(s.key)
+	if err != nil {
+		return nil, err
+	}
+	search := fingerprint
+	if search == "" {
+		search = keyID
+	}
+
+	lookup := fmt.Sprintf("%s/pks/lookup?op=get&options=mr&search=%s",
+		strings.TrimSuffix(endpoint, "/"), url.QueryEscape("0x"+strings.ToUpper(search)))
+	keys, err := c.fetch(ctx, lookup, "application/pgp-keys")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up gpg key: %s", s.key)
+	}
+
+	// A keyserver may return any key, so only
+	// the one that was asked for is used
+	return s.selectKey(keys)
+}
@@ -0,0 +1,87 @@
+package release_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHKPSignee(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/pks/lookup" || q.Get("op") != "get" || q.Get("options") != "mr" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		search := strings.ToLower(strings.TrimPrefix(q.Get("search"), "0x"))
+		switch {
+		case search == mock.SignerFingerPrint || search == mock.SignerKeyID:
+			w.Write(mock.SignerPub) // nolint: errcheck
+		case search == "0123456789abcdef":
+			// A misbehaving keyserver returning another key
+			w.Write(mock.AltSignerPub) // nolint: errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		user      string
+		key       string
+		expectErr bool
+	}{
+		{
+			name: "Key ID",
+			user: "bob",
+			key:  mock.SignerKeyID,
+		},
+		{
+			name: "Fingerprint and email",
+			user: "bob@builder.com",
+			key:  mock.SignerFingerPrint,
+		},
+		{
+			name:      "Email without identity",
+			user:      "bob@example.com",
+			key:       mock.SignerFingerPrint,
+			expectErr: true,
+		},
+		{
+			name:      "Another key returned",
+			user:      "bob",
+			key:       "0123456789abcdef",
+			expectErr: true,
+		},
+		{
+			name:      "Unknown key",
+			user:      "bob",
+			key:       "fedcba9876543210",
+			expectErr: true,
+		},
+		{
+			name:      "No key",
+			user:      "bob",
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		s := release.NewHKPSignee(tc.user, tc.key, release.HKPEndpoint(server.URL), release.HKPHTTPClient(server.Client()))
+		identities, err := verifier.VerifySignature(s, mock.Signed, mock.Signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, identities, tc.name)
+	}
+}
@@ -0,0 +1,250 @@
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// Config contains the information required to find
+// a private key in a PKCS#11 token
+type Config struct {
+	// Module is the path to the PKCS#11 library,
+	// e.g., /usr/lib/softhsm/libsofthsm2.so
+	Module     string
+	TokenLabel string
+	PIN        string
+	KeyLabel   string
+}
+
+// Key is a private key that is kept in a PKCS#11 token, it
+// implements crypto.Signer so it can be used for signing
+// without the key ever leaving the token
+type Key struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	handle  pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+// DigestInfo prefixes required for PKCS#1 v1.5 signatures, see:
+// https://tools.ietf.org/html/rfc8017#section-9.2
+var digestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+var curves = map[string]elliptic.Curve{
+	asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}.String(): elliptic.P256(),
+	asn1.ObjectIdentifier{1, 3, 132, 0, 34}.String():          elliptic.P384(),
+	asn1.ObjectIdentifier{1, 3, 132, 0, 35}.String():          elliptic.P521(),
+}
+
+// Open loads the PKCS#11 module, logs into the token and
+// finds the private key and its public key by label
+func Open(config Config) (*Key, error) {
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load pkcs11 module: %s", config.Module)
+	}
+	err := ctx.Initialize()
+	if err != nil {
+		ctx.Destroy()
+		return nil, errors.Wrap(err, "failed to initialise pkcs11 module")
+	}
+
+	key := &Key{ctx: ctx}
+	err = key.open(config)
+	if err != nil {
+		key.Close() // nolint: errcheck, gosec
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *Key) open(config Config) error {
+	slots, err := k.ctx.GetSlotList(true)
+	if err != nil {
+		return errors.Wrap(err, "failed to list slots")
+	}
+
+	found := false
+	var slot uint
+	for _, s := range slots {
+		info, err := k.ctx.GetTokenInfo(s)
+		if err != nil {
+			return errors.Wrap(err, "failed to get token info")
+		}
+		if info.Label == config.TokenLabel {
+			slot, found = s, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("token not found: %s", config.TokenLabel)
+	}
+
+	k.session, err = k.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return errors.Wrap(err, "failed to open session")
+	}
+	err = k.ctx.Login(k.session, pkcs11.CKU_USER, config.PIN)
+	if err != nil {
+		return errors.Wrap(err, "failed to login")
+	}
+
+	k.handle, err = k.find(pkcs11.CKO_PRIVATE_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+	public, err := k.find(pkcs11.CKO_PUBLIC_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+	k.public, err = k.publicKey(public)
+	return err
+}
+
+func (k *Key) find(class uint, label string) (pkcs11.ObjectHandle, error) {
+	err := k.ctx.FindObjectsInit(k.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to find objects")
+	}
+	handles, _, err := k.ctx.FindObjects(k.session, 1)
+	if err != nil {
+		k.ctx.FindObjectsFinal(k.session) // nolint: errcheck, gosec
+		return 0, errors.Wrap(err, "failed to find objects")
+	}
+	err = k.ctx.FindObjectsFinal(k.session)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to finish finding objects")
+	}
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("key not found: %s", label)
+	}
+	return handles[0], nil
+}
+
+func (k *Key) publicKey(handle pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	// Only RSA keys have a modulus, so fall back to
+	// reading an EC key if it is missing
+	attrs, err := k.ctx.GetAttributeValue(k.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err == nil && len(attrs) == 2 {
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	}
+
+	attrs, err = k.ctx.GetAttributeValue(k.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil || len(attrs) != 2 {
+		return nil, fmt.Errorf("unsupported key type")
+	}
+	var oid asn1.ObjectIdentifier
+	_, err = asn1.Unmarshal(attrs[0].Value, &oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse ec params")
+	}
+	curve, ok := curves[oid.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve: %s", oid)
+	}
+	var point []byte
+	_, err = asn1.Unmarshal(attrs[1].Value, &point)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse ec point")
+	}
+	x, y := elliptic.Unmarshal(curve, point) // nolint: staticcheck
+	if x == nil {
+		return nil, fmt.Errorf("invalid ec point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Public returns the public key
+func (k *Key) Public() crypto.PublicKey {
+	return k.public
+}
+
+// Sign the digest using the key in the token, RSA keys create PKCS#1 v1.5
+// signatures and ECDSA keys ASN.1 encoded signatures, as crypto.Signer
+// implementations are expected to
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch k.public.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("rsa pss signatures are not supported")
+		}
+		prefix, ok := digestInfoPrefix[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash: %v", opts.HashFunc())
+		}
+		return k.sign(pkcs11.CKM_RSA_PKCS, append(append([]byte{}, prefix...), digest...))
+	case *ecdsa.PublicKey:
+		raw, err := k.sign(pkcs11.CKM_ECDSA, digest)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw)%2 != 0 {
+			return nil, fmt.Errorf("invalid ecdsa signature length")
+		}
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(raw[:len(raw)/2]),
+			S: new(big.Int).SetBytes(raw[len(raw)/2:]),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key type")
+	}
+}
+
+func (k *Key) sign(mechanism uint, data []byte) ([]byte, error) {
+	err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialise signing")
+	}
+	signature, err := k.ctx.Sign(k.session, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign")
+	}
+	return signature, nil
+}
+
+// Close logs out of the token and unloads the module
+func (k *Key) Close() error {
+	if k.session != 0 {
+		k.ctx.Logout(k.session)       // nolint: errcheck, gosec
+		k.ctx.CloseSession(k.session) // nolint: errcheck, gosec
+	}
+	err := k.ctx.Finalize()
+	k.ctx.Destroy()
+	if err != nil {
+		return errors.Wrap(err, "failed to finalise pkcs11 module")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package hsm_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stoic-cli/stoic-release/hsm"
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests run against SoftHSM, a token can be created with:
+//
+//	softhsm2-util --init-token --free --label stoic --pin 1234 --so-pin 1234
+//
+// and the tests enabled with:
+//
+//	SOFTHSM2_MODULE=/usr/lib/softhsm/libsofthsm2.so SOFTHSM2_TOKEN=stoic SOFTHSM2_PIN=1234
+func softHSM(t *testing.T) hsm.Config {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if len(module) == 0 {
+		t.Skip("SOFTHSM2_MODULE not set, skipping pkcs11 tests")
+	}
+	return hsm.Config{
+		Module:     module,
+		TokenLabel: os.Getenv("SOFTHSM2_TOKEN"),
+		PIN:        os.Getenv("SOFTHSM2_PIN"),
+	}
+}
+
+// generateKeyPair creates a key pair in the token, the private
+// key is marked as sensitive and can never be extracted
+func generateKeyPair(t *testing.T, config hsm.Config, mechanism uint, public []*pkcs11.Attribute) {
+	ctx := pkcs11.New(config.Module)
+	assert.NotNil(t, ctx)
+	assert.Nil(t, ctx.Initialize())
+	defer ctx.Destroy()
+	defer ctx.Finalize() // nolint: errcheck
+
+	slots, err := ctx.GetSlotList(true)
+	assert.Nil(t, err)
+	var slot uint
+	for _, s := range slots {
+		info, err := ctx.GetTokenInfo(s)
+		assert.Nil(t, err)
+		if info.Label == config.TokenLabel {
+			slot = s
+		}
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	assert.Nil(t, err)
+	defer ctx.CloseSession(session) // nolint: errcheck
+	assert.Nil(t, ctx.Login(session, pkcs11.CKU_USER, config.PIN))
+	defer ctx.Logout(session) // nolint: errcheck
+
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)},
+		append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, config.KeyLabel),
+		),
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, config.KeyLabel),
+		},
+	)
+	assert.Nil(t, err)
+}
+
+func TestRSA(t *testing.T) {
+	config := softHSM(t)
+	config.KeyLabel = "stoic-rsa"
+	generateKeyPair(t, config, pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+	})
+
+	key, err := hsm.Open(config)
+	assert.Nil(t, err)
+	defer key.Close() // nolint: errcheck
+
+	digest := sha256.Sum256([]byte("This is my message\n"))
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.Nil(t, err)
+	assert.Nil(t, rsa.VerifyPKCS1v15(key.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature))
+}
+
+func TestECDSA(t *testing.T) {
+	config := softHSM(t)
+	config.KeyLabel = "stoic-ecdsa"
+	generateKeyPair(t, config, pkcs11.CKM_EC_KEY_PAIR_GEN, []*pkcs11.Attribute{
+		// DER encoded OID of P-256
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}),
+	})
+
+	key, err := hsm.Open(config)
+	assert.Nil(t, err)
+	defer key.Close() // nolint: errcheck
+
+	digest := sha256.Sum256([]byte("This is my message\n"))
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.Nil(t, err)
+	assert.True(t, ecdsa.VerifyASN1(key.Public().(*ecdsa.PublicKey), digest[:], signature))
+}
+
+func TestOpenMissingModule(t *testing.T) {
+	_, err := hsm.Open(hsm.Config{Module: "/does/not/exist.so"})
+	assert.Error(t, err)
+}
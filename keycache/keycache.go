@@ -0,0 +1,279 @@
+package keycache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"gopkg.in/yaml.v2"
+)
+
+// Cache provides the interface for pinning the public keys of
+// signees on first use, so a key that is swapped later, e.g.,
+// by a compromised account, doesn't go unnoticed
+type Cache interface {
+	// Signee wraps the signee so its public key is pinned on first
+	// use and checked against the pinned fingerprint afterwards
+	Signee(signee release.Signee) release.Signee
+
+	// Pinned returns the pinned entry of the signee
+	Pinned(signee release.Signee) (*Entry, error)
+
+	// Repin fetches the public key of the signee and pins it,
+	// replacing the pinned key, e.g., after a key rotation
+	Repin(signee release.Signee) (*Entry, error)
+
+	// Forget removes the pinned entry of the signee
+	Forget(signee release.Signee) error
+}
+
+// Entry contains the pinned public key of a signee
+type Entry struct {
+	Type        release.SigneeType
+	User        string
+	Key         string
+	Fingerprint string
+	PinnedAt    time.Time
+	PublicKey   string
+}
+
+// KeyChangeHandler is called when the fetched public key of a signee
+// doesn't have the pinned fingerprint, the fetched key is only used
+// if no error is returned, and it isn't pinned
+type KeyChangeHandler func(entry *Entry, fingerprint string) error
+
+const entryExt = ".yaml"
+
+// nolint
+var (
+	ErrNotPinned  = errors.New("public key not pinned")
+	ErrKeyChanged = errors.New("public key changed")
+)
+
+// Option configures the cache
+type Option func(*cache)
+
+// Offline serves the pinned public keys without fetching
+// them, signees without a pinned key fail
+func Offline() Option {
+	return func(c *cache) {
+		c.offline = true
+	}
+}
+
+// OnKeyChange sets the handler for changed public keys,
+// by default a changed key fails with ErrKeyChanged
+func OnKeyChange(handler KeyChangeHandler) Option {
+	return func(c *cache) {
+		c.onKeyChange = handler
+	}
+}
+
+// WarnOnKeyChange writes a warning when a public key changed
+// and uses the fetched key instead of failing
+func WarnOnKeyChange(w io.Writer) Option {
+	return OnKeyChange(func(entry *Entry, fingerprint string) error {
+		_, err := fmt.Fprintf(w, "warning: public key of %s signee: %s changed from: %s to: %s\n", entry.Type, entry.User, entry.Fingerprint, fingerprint)
+		return err
+	})
+}
+
+func failOnKeyChange(entry *Entry, fingerprint string) error {
+	return errors.Wrapf(ErrKeyChanged, "%s signee: %s, pinned: %s, got: %s", entry.Type, entry.User, entry.Fingerprint, fingerprint)
+}
+
+type cache struct {
+	directory   string
+	offline     bool
+	onKeyChange KeyChangeHandler
+}
+
+// New creates a cache that keeps its pinned keys in the directory
+func New(directory string, options ...Option) Cache {
+	c := &cache{
+		directory:   directory,
+		onKeyChange: failOnKeyChange,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// path returns the path of the entry of the signee, the name is a
+// digest of the type, user and key, as these may contain anything
+func (c *cache) path(signee release.Signee) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", signee.Type(), signee.User(), signee.Key())))
+	return filepath.Join(c.directory, fmt.Sprintf("%x%s", digest, entryExt))
+}
+
+// Pinned returns the pinned entry
+func (c *cache) Pinned(signee release.Signee) (*Entry, error) {
+	content, err := ioutil.ReadFile(c.path(signee))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrNotPinned, "%s signee: %s", signee.Type(), signee.User())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pinned key")
+	}
+
+	entry := &Entry{}
+	err = yaml.Unmarshal(content, entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode pinned key")
+	}
+	return entry, nil
+}
+
+// Repin the public key
+func (c *cache) Repin(signee release.Signee) (*Entry, error) {
+	publicKey, err := signee.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return c.pin(signee, publicKey, time.Now())
+}
+
+func (c *cache) pin(signee release.Signee, publicKey []byte, pinnedAt time.Time) (*Entry, error) {
+	fingerprint, err := pgp.Fingerprint(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key fingerprint")
+	}
+
+	entry := &Entry{
+		Type:        signee.Type(),
+		User:        signee.User(),
+		Key:         signee.Key(),
+		Fingerprint: fingerprint,
+		PinnedAt:    pinnedAt,
+		PublicKey:   string(publicKey),
+	}
+	content, err := yaml.Marshal(entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode pinned key")
+	}
+
+	err = os.MkdirAll(c.directory, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	err = writeFile(c.path(signee), content, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// writeFile writes to a temporary file first, so an
+// entry is never left half written
+func writeFile(name string, content []byte, perm os.FileMode) error {
+	tmp := name + ".tmp"
+	err := ioutil.WriteFile(tmp, content, perm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write file: %s", filepath.Base(name))
+	}
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp) // nolint: errcheck, gosec
+		return errors.Wrapf(err, "failed to write file: %s", filepath.Base(name))
+	}
+	return nil
+}
+
+// Forget the pinned entry
+func (c *cache) Forget(signee release.Signee) error {
+	err := os.Remove(c.path(signee))
+	if os.IsNotExist(err) {
+		return errors.Wrapf(ErrNotPinned, "%s signee: %s", signee.Type(), signee.User())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to remove pinned key")
+	}
+	return nil
+}
+
+// Signee wraps the signee
+func (c *cache) Signee(signee release.Signee) release.Signee {
+	return &cachedSignee{
+		Signee: signee,
+		cache:  c,
+	}
+}
+
+type cachedSignee struct {
+	release.Signee
+	cache *cache
+}
+
+// PublicKey returns the public key of the wrapped signee, pinning
+// it on first use, or the pinned key when the cache is offline
+func (s *cachedSignee) PublicKey() ([]byte, error) {
+	return s.PublicKeyContext(context.Background())
+}
+
+// PublicKeyContext is PublicKey where the context
+// applies to fetching the key of the wrapped signee
+func (s *cachedSignee) PublicKeyContext(ctx context.Context) ([]byte, error) {
+	entry, err := s.cache.Pinned(s.Signee)
+	if err != nil && errors.Cause(err) != ErrNotPinned {
+		return nil, err
+	}
+	if s.cache.offline {
+		if entry == nil {
+			return nil, err
+		}
+		return []byte(entry.PublicKey), nil
+	}
+
+	publicKey, err := release.NewSigneeContext(s.Signee).PublicKeyContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		_, err = s.cache.pin(s.Signee, publicKey, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	}
+
+	fingerprint, err := pgp.Fingerprint(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key fingerprint")
+	}
+	if !pgp.SameFingerprint(fingerprint, entry.Fingerprint) {
+		err = s.cache.onKeyChange(entry, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	}
+
+	if bytes.Equal(publicKey, []byte(entry.PublicKey)) {
+		return publicKey, nil
+	}
+
+	// The same key may have gained subkeys, signatures or a new expiry,
+	// these are added to the pinned key but nothing is ever dropped from
+	// it, so a server leaving out a revocation can't undo it
+	merged, changed, err := pgp.MergeKeys([]byte(entry.PublicKey), publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge public key")
+	}
+	if changed {
+		_, err = s.cache.pin(s.Signee, merged, entry.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,203 @@
+package keycache_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/keycache"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// signee returns whatever public key it currently holds
+type signee struct {
+	publicKey []byte
+	err       error
+	fetches   int
+}
+
+func (s *signee) PublicKey() ([]byte, error) {
+	s.fetches++
+	return s.publicKey, s.err
+}
+
+func (s *signee) Key() string {
+	return mock.SignerKeyID
+}
+
+func (s *signee) User() string {
+	return "bob"
+}
+
+func (s *signee) Type() release.SigneeType {
+	return release.GithubSigneeType
+}
+
+func testCache(t *testing.T, options ...keycache.Option) (keycache.Cache, func()) {
+	dir, err := ioutil.TempDir("", "keycache")
+	assert.Nil(t, err)
+	return keycache.New(dir, options...), func() { os.RemoveAll(dir) } // nolint: errcheck
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	cache, cleanup := testCache(t)
+	defer cleanup()
+
+	s := &signee{publicKey: mock.SignerPub}
+	_, err := cache.Pinned(s)
+	assert.Equal(t, keycache.ErrNotPinned, errors.Cause(err))
+
+	got, err := cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerPub, got)
+
+	entry, err := cache.Pinned(s)
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerFingerPrint, entry.Fingerprint)
+	assert.Equal(t, release.GithubSigneeType, entry.Type)
+	assert.Equal(t, "bob", entry.User)
+	assert.Equal(t, mock.SignerKeyID, entry.Key)
+
+	// A swapped key is noticed
+	s.publicKey = mock.AltSignerPub
+	_, err = cache.Signee(s).PublicKey()
+	assert.Equal(t, keycache.ErrKeyChanged, errors.Cause(err))
+
+	// Until it is explicitly pinned again
+	entry, err = cache.Repin(s)
+	assert.Nil(t, err)
+	assert.NotEqual(t, mock.SignerFingerPrint, entry.Fingerprint)
+	got, err = cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, mock.AltSignerPub, got)
+
+	assert.Nil(t, cache.Forget(s))
+	assert.Equal(t, keycache.ErrNotPinned, errors.Cause(cache.Forget(s)))
+
+	// Only a single key can be pinned
+	s.publicKey = append(append([]byte{}, mock.SignerPub...), mock.AltSignerPub...)
+	_, err = cache.Signee(s).PublicKey()
+	assert.Error(t, err)
+}
+
+func TestOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keycache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	online := keycache.New(dir)
+	offline := keycache.New(dir, keycache.Offline())
+
+	s := &signee{err: fmt.Errorf("no network")}
+	_, err = online.Signee(s).PublicKey()
+	assert.Error(t, err)
+	_, err = offline.Signee(s).PublicKey()
+	assert.Equal(t, keycache.ErrNotPinned, errors.Cause(err))
+
+	s = &signee{publicKey: mock.SignerPub}
+	_, err = online.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.fetches)
+
+	s.err = fmt.Errorf("no network")
+	got, err := offline.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerPub, got)
+	assert.Equal(t, 1, s.fetches)
+}
+
+func TestKeyChangeHandlers(t *testing.T) {
+	var warnings bytes.Buffer
+	cache, cleanup := testCache(t, keycache.WarnOnKeyChange(&warnings))
+	defer cleanup()
+
+	s := &signee{publicKey: mock.SignerPub}
+	_, err := cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+
+	s.publicKey = mock.AltSignerPub
+	got, err := cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, mock.AltSignerPub, got)
+	assert.Contains(t, warnings.String(), mock.SignerFingerPrint)
+
+	// Warning doesn't pin the changed key
+	entry, err := cache.Pinned(s)
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerFingerPrint, entry.Fingerprint)
+
+	rejected := fmt.Errorf("rejected")
+	strict, cleanupStrict := testCache(t, keycache.OnKeyChange(func(_ *keycache.Entry, _ string) error {
+		return rejected
+	}))
+	defer cleanupStrict()
+	s.publicKey = mock.SignerPub
+	_, err = strict.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	s.publicKey = mock.AltSignerPub
+	_, err = strict.Signee(s).PublicKey()
+	assert.Equal(t, rejected, err)
+}
+
+// subkeyRevocations returns the number of revocations of the subkey
+func subkeyRevocations(t *testing.T, publicKey []byte, keyID uint64) int {
+	keyring, err := pgp.ReadKeyRing(publicKey)
+	assert.Nil(t, err)
+	for _, subkey := range keyring[0].Subkeys {
+		if subkey.PublicKey.KeyId == keyID {
+			return len(subkey.Revocations)
+		}
+	}
+	t.Fatalf("subkey %016x not found", keyID)
+	return 0
+}
+
+func TestKeyUpdates(t *testing.T) {
+	cache, cleanup := testCache(t)
+	defer cleanup()
+
+	key, err := pgp.NewSignerWithAlgorithm("bob", "", "bob@example.com", pgp.Ed25519, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	withSubkey, err := pgp.AddSigningSubkey(key.PrivateKey, 0, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	revoked, err := pgp.RevokeSubkey(withSubkey.PrivateKey, withSubkey.SigningKeyID, packet.KeyCompromised, "", pgp.DefaultConfig)
+	assert.Nil(t, err)
+
+	s := &signee{publicKey: key.PublicKey}
+	_, err = cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+
+	// A new subkey is added to the pinned key
+	s.publicKey = withSubkey.PublicKey
+	got, err := cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, subkeyRevocations(t, got, withSubkey.SigningKeyID))
+
+	// And so is its revocation
+	s.publicKey = revoked.PublicKey
+	got, err = cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, subkeyRevocations(t, got, withSubkey.SigningKeyID))
+
+	// Which a copy of the key without it can't undo
+	s.publicKey = withSubkey.PublicKey
+	got, err = cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, subkeyRevocations(t, got, withSubkey.SigningKeyID))
+	entry, err := cache.Pinned(s)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, subkeyRevocations(t, []byte(entry.PublicKey), withSubkey.SigningKeyID))
+
+	// Nor can a copy without the subkey
+	s.publicKey = key.PublicKey
+	got, err = cache.Signee(s).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, subkeyRevocations(t, got, withSubkey.SigningKeyID))
+}
@@ -0,0 +1,124 @@
+package keyless
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OIDIssuer is the certificate extension containing the
+// issuer of the identity, as used by Fulcio
+var OIDIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+// DefaultCertificateLifetime is the validity period of
+// the short lived signing certificates
+var DefaultCertificateLifetime = 10 * time.Minute
+
+// DefaultTime sets the default time function
+var DefaultTime = func() time.Time {
+	return time.Now()
+}
+
+// Identity contains the identity a signing certificate
+// is issued for, e.g., an email address or workflow URI
+// and the issuer that authenticated it
+type Identity struct {
+	Subject string
+	Issuer  string
+}
+
+// CertificateAuthority provides the interface required for issuing
+// short lived signing certificates, e.g., Fulcio
+type CertificateAuthority interface {
+	// IssueCertificate returns the DER encoded certificate
+	// chain for the public key, starting with the leaf
+	IssueCertificate(publicKey crypto.PublicKey, identity Identity) ([][]byte, error)
+}
+
+type localCA struct {
+	key  *ecdsa.PrivateKey
+	root *x509.Certificate
+}
+
+// NewLocalCA creates a certificate authority with a freshly generated
+// root, it stands in for Fulcio in tests and air-gapped setups
+func NewLocalCA() (CertificateAuthority, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate root key")
+	}
+
+	now := DefaultTime()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stoic-release local root"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create root certificate")
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse root certificate")
+	}
+
+	return &localCA{
+		key:  key,
+		root: root,
+	}, root, nil
+}
+
+// IssueCertificate for the provided public key and identity
+func (ca *localCA) IssueCertificate(publicKey crypto.PublicKey, identity Identity) ([][]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate serial number")
+	}
+	issuer, err := asn1.Marshal(identity.Issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode issuer")
+	}
+
+	now := DefaultTime()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    now,
+		NotAfter:     now.Add(DefaultCertificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{
+			{Id: OIDIssuer, Value: issuer},
+		},
+	}
+	if strings.Contains(identity.Subject, "@") {
+		template.EmailAddresses = []string{identity.Subject}
+	} else {
+		uri, err := url.Parse(identity.Subject)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse identity")
+		}
+		template.URIs = []*url.URL{uri}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.root, publicKey, ca.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create certificate")
+	}
+
+	return [][]byte{der, ca.root.Raw}, nil
+}
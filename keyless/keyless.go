@@ -0,0 +1,228 @@
+package keyless
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/merkle"
+)
+
+// Implements keyless signing in the style of sigstore/cosign: an ephemeral
+// key is certified for an identity by a certificate authority and the
+// signature is recorded in a transparency log. The bundle contains
+// everything required to verify the signature offline.
+
+// nolint
+var (
+	ErrIdentityMismatch = errors.New("certificate identity does not match policy")
+	ErrIssuerMismatch   = errors.New("certificate issuer does not match policy")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidLogEntry  = errors.New("invalid transparency log entry")
+	ErrNoRoots          = errors.New("policy has no trust roots")
+)
+
+// Bundle contains a signature, the certificate chain of the
+// ephemeral signing key and the transparency log entry
+type Bundle struct {
+	Signature        []byte   `json:"signature"`
+	CertificateChain [][]byte `json:"certificateChain"`
+	LogEntry         LogEntry `json:"logEntry"`
+}
+
+// Policy contains the trust roots and the identity a bundle must
+// have been signed by, the roots are required as the system roots
+// would trust any certificate with a matching name
+type Policy struct {
+	Roots        *x509.CertPool
+	LogPublicKey crypto.PublicKey
+	Identity     Identity
+}
+
+// Sign creates a signature of the provided data using an ephemeral key that
+// is certified for the identity and records the signature in the log
+func Sign(ca CertificateAuthority, log Log, identity Identity, sign []byte) (*Bundle, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate ephemeral key")
+	}
+
+	chain, err := ca.IssueCertificate(key.Public(), identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to issue certificate")
+	}
+
+	digest := sha256.Sum256(sign)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign data")
+	}
+
+	logEntry, err := log.Append(entry(digest[:], signature, chain[0]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to append to transparency log")
+	}
+
+	return &Bundle{
+		Signature:        signature,
+		CertificateChain: chain,
+		LogEntry:         *logEntry,
+	}, nil
+}
+
+// Serialise encodes the bundle
+func (b *Bundle) Serialise() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+// ReadBundle decodes a bundle
+func ReadBundle(in []byte) (*Bundle, error) {
+	var bundle Bundle
+	err := json.Unmarshal(in, &bundle)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read bundle")
+	}
+	return &bundle, nil
+}
+
+// Verify asserts that the bundle contains a valid signature of the data
+// made by the identity in the policy, that the certificate chains to one
+// of the roots and that the signature was recorded in the log while
+// the certificate was valid
+func Verify(bundle *Bundle, signed []byte, policy Policy) (*x509.Certificate, error) {
+	// A nil pool makes x509 fall back to the system roots
+	if policy.Roots == nil || len(policy.Roots.Subjects()) == 0 { // nolint: staticcheck
+		return nil, ErrNoRoots
+	}
+	if len(bundle.CertificateChain) == 0 {
+		return nil, errors.New("bundle contains no certificates")
+	}
+	var chain []*x509.Certificate
+	for _, der := range bundle.CertificateChain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse certificate")
+		}
+		chain = append(chain, cert)
+	}
+	leaf := chain[0]
+
+	digest := sha256.Sum256(signed)
+	leafHash := merkle.LeafHash(entry(digest[:], bundle.Signature, bundle.CertificateChain[0]))
+	err := verifyLogEntry(bundle.LogEntry, leafHash, policy.LogPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         policy.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(bundle.LogEntry.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify certificate chain")
+	}
+
+	err = verifyIdentity(leaf, policy.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(key, digest[:], bundle.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	return leaf, nil
+}
+
+func verifyLogEntry(logEntry LogEntry, leafHash []byte, logPublicKey crypto.PublicKey) error {
+	key, ok := logPublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("unsupported log public key")
+	}
+
+	proof := logEntry.Proof
+	err := merkle.VerifyInclusion(logEntry.LogIndex, proof.TreeSize, leafHash, proof.Hashes, proof.RootHash)
+	if err != nil {
+		return errors.Wrap(ErrInvalidLogEntry, err.Error())
+	}
+
+	cp := sha256.Sum256(checkpoint(proof.TreeSize, proof.RootHash))
+	if !ecdsa.VerifyASN1(key, cp[:], proof.Checkpoint) {
+		return errors.Wrap(ErrInvalidLogEntry, "invalid checkpoint signature")
+	}
+
+	set := sha256.Sum256(entryTimestamp(logEntry.LogIndex, logEntry.IntegratedTime, leafHash))
+	if !ecdsa.VerifyASN1(key, set[:], logEntry.SignedEntryTimestamp) {
+		return errors.Wrap(ErrInvalidLogEntry, "invalid signed entry timestamp")
+	}
+
+	return nil
+}
+
+// CertificateIdentity returns the subjects of the certificate, its
+// email addresses and URIs, and the issuer of the identity, if any
+func CertificateIdentity(cert *x509.Certificate) ([]string, string) {
+	var subjects []string
+	subjects = append(subjects, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+
+	var issuer string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(OIDIssuer) {
+			continue
+		}
+		_, err := asn1.Unmarshal(ext.Value, &issuer)
+		if err == nil {
+			break
+		}
+	}
+	return subjects, issuer
+}
+
+func verifyIdentity(leaf *x509.Certificate, identity Identity) error {
+	subjects, issuer := CertificateIdentity(leaf)
+	found := false
+	for _, subject := range subjects {
+		if subject == identity.Subject {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrIdentityMismatch
+	}
+	if issuer != identity.Issuer {
+		return ErrIssuerMismatch
+	}
+	return nil
+}
+
+// entry returns the content that is recorded in the
+// log for a signature
+func entry(digest, signature, certificate []byte) []byte {
+	var buf bytes.Buffer
+	// Marshalling a struct of byte slices can't fail
+	_ = json.NewEncoder(&buf).Encode(struct {
+		Digest      []byte `json:"digest"`
+		Signature   []byte `json:"signature"`
+		Certificate []byte `json:"certificate"`
+	}{digest, signature, certificate})
+	return buf.Bytes()
+}
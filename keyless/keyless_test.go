@@ -0,0 +1,130 @@
+package keyless_test
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/keyless"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHappyPath(t *testing.T) {
+	ca, root, err := keyless.NewLocalCA()
+	assert.Nil(t, err)
+	log, logKey, err := keyless.NewLocalLog()
+	assert.Nil(t, err)
+
+	identity := keyless.Identity{Subject: "bob@builder.com", Issuer: "https://accounts.example.com"}
+	msg := []byte("This is my message\n")
+
+	// Fill the log a little, so the proof isn't trivial
+	for i := 0; i < 5; i++ {
+		_, err = keyless.Sign(ca, log, identity, []byte("filler"))
+		assert.Nil(t, err)
+	}
+
+	bundle, err := keyless.Sign(ca, log, identity, msg)
+	assert.Nil(t, err)
+	serialised, err := bundle.Serialise()
+	assert.Nil(t, err)
+	bundle, err = keyless.ReadBundle(serialised)
+	assert.Nil(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	policy := keyless.Policy{Roots: roots, LogPublicKey: logKey, Identity: identity}
+
+	cert, err := keyless.Verify(bundle, msg, policy)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob@builder.com"}, cert.EmailAddresses)
+}
+
+func TestVerify(t *testing.T) {
+	ca, root, err := keyless.NewLocalCA()
+	assert.Nil(t, err)
+	_, altRoot, err := keyless.NewLocalCA()
+	assert.Nil(t, err)
+	log, logKey, err := keyless.NewLocalLog()
+	assert.Nil(t, err)
+	_, altLogKey, err := keyless.NewLocalLog()
+	assert.Nil(t, err)
+
+	identity := keyless.Identity{Subject: "https://github.com/stoic-cli/stoic-release/.github/workflows/release.yml@refs/heads/master", Issuer: "https://token.actions.githubusercontent.com"}
+	msg := []byte("This is my message\n")
+	bundle, err := keyless.Sign(ca, log, identity, msg)
+	assert.Nil(t, err)
+
+	pool := func(cert *x509.Certificate) *x509.CertPool {
+		p := x509.NewCertPool()
+		p.AddCert(cert)
+		return p
+	}
+
+	testCases := []struct {
+		name      string
+		signed    []byte
+		policy    keyless.Policy
+		expect    error
+		expectErr bool
+	}{
+		{
+			name:   "Valid",
+			signed: msg,
+			policy: keyless.Policy{Roots: pool(root), LogPublicKey: logKey, Identity: identity},
+		},
+		{
+			name:   "Wrong subject",
+			signed: msg,
+			policy: keyless.Policy{Roots: pool(root), LogPublicKey: logKey, Identity: keyless.Identity{Subject: "eve@example.com", Issuer: identity.Issuer}},
+			expect: keyless.ErrIdentityMismatch,
+		},
+		{
+			name:   "Wrong issuer",
+			signed: msg,
+			policy: keyless.Policy{Roots: pool(root), LogPublicKey: logKey, Identity: keyless.Identity{Subject: identity.Subject, Issuer: "https://evil.example.com"}},
+			expect: keyless.ErrIssuerMismatch,
+		},
+		{
+			name:   "Wrong log key",
+			signed: msg,
+			policy: keyless.Policy{Roots: pool(root), LogPublicKey: altLogKey, Identity: identity},
+			expect: keyless.ErrInvalidLogEntry,
+		},
+		{
+			name:   "Modified message",
+			signed: []byte("This is not my message\n"),
+			policy: keyless.Policy{Roots: pool(root), LogPublicKey: logKey, Identity: identity},
+			expect: keyless.ErrInvalidLogEntry,
+		},
+		{
+			name:      "Untrusted root",
+			signed:    msg,
+			policy:    keyless.Policy{Roots: pool(altRoot), LogPublicKey: logKey, Identity: identity},
+			expectErr: true,
+		},
+		{
+			name:   "No roots",
+			signed: msg,
+			policy: keyless.Policy{LogPublicKey: logKey, Identity: identity},
+			expect: keyless.ErrNoRoots,
+		},
+		{
+			name:   "Empty roots",
+			signed: msg,
+			policy: keyless.Policy{Roots: x509.NewCertPool(), LogPublicKey: logKey, Identity: identity},
+			expect: keyless.ErrNoRoots,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := keyless.Verify(bundle, tc.signed, tc.policy)
+		if tc.expect != nil {
+			assert.Equal(t, tc.expect, errors.Cause(err), tc.name)
+		} else if tc.expectErr {
+			assert.Error(t, err, tc.name)
+		} else {
+			assert.Nil(t, err, tc.name)
+		}
+	}
+}
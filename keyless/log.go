@@ -0,0 +1,128 @@
+package keyless
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/merkle"
+)
+
+// Log provides the interface required for recording
+// signatures in a transparency log, e.g., Rekor
+type Log interface {
+	// Append records the entry and returns the proof
+	// of its inclusion in the log
+	Append(entry []byte) (*LogEntry, error)
+}
+
+// LogEntry contains the position of an entry in the log,
+// when it was integrated and the proof of its inclusion
+type LogEntry struct {
+	LogIndex       uint64         `json:"logIndex"`
+	IntegratedTime int64          `json:"integratedTime"`
+	Proof          InclusionProof `json:"inclusionProof"`
+
+	// SignedEntryTimestamp is the log's signature over
+	// the index, integrated time and leaf hash
+	SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+}
+
+// InclusionProof contains the audit path of an entry and
+// the signed tree head it leads to
+type InclusionProof struct {
+	TreeSize uint64   `json:"treeSize"`
+	RootHash []byte   `json:"rootHash"`
+	Hashes   [][]byte `json:"hashes"`
+
+	// Checkpoint is the log's signature over
+	// the tree size and root hash
+	Checkpoint []byte `json:"checkpoint"`
+}
+
+type localLog struct {
+	mu   sync.Mutex
+	key  *ecdsa.PrivateKey
+	tree *merkle.Tree
+}
+
+// NewLocalLog creates an in-memory transparency log with a freshly
+// generated signing key, it stands in for Rekor in tests
+func NewLocalLog() (Log, crypto.PublicKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate log key")
+	}
+	return &localLog{
+		key:  key,
+		tree: merkle.NewTree(),
+	}, key.Public(), nil
+}
+
+// Append the entry to the log
+func (l *localLog) Append(entry []byte) (*LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	index := l.tree.Append(entry)
+	size := l.tree.Size()
+	root, err := l.tree.Root(size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate root hash")
+	}
+	hashes, err := l.tree.InclusionProof(index, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create inclusion proof")
+	}
+
+	logEntry := &LogEntry{
+		LogIndex:       index,
+		IntegratedTime: DefaultTime().Unix(),
+		Proof: InclusionProof{
+			TreeSize: size,
+			RootHash: root,
+			Hashes:   hashes,
+		},
+	}
+
+	logEntry.Proof.Checkpoint, err = l.sign(checkpoint(size, root))
+	if err != nil {
+		return nil, err
+	}
+	logEntry.SignedEntryTimestamp, err = l.sign(entryTimestamp(index, logEntry.IntegratedTime, merkle.LeafHash(entry)))
+	if err != nil {
+		return nil, err
+	}
+
+	return logEntry, nil
+}
+
+func (l *localLog) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign log data")
+	}
+	return signature, nil
+}
+
+func checkpoint(size uint64, root []byte) []byte {
+	return []byte(fmt.Sprintf("stoic-release log\n%d\n%s\n", size, base64.StdEncoding.EncodeToString(root)))
+}
+
+func entryTimestamp(index uint64, integratedTime int64, leafHash []byte) []byte {
+	// Marshalling a struct of basic types can't fail
+	out, _ := json.Marshal(struct {
+		LogIndex       uint64 `json:"logIndex"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LeafHash       []byte `json:"leafHash"`
+	}{index, integratedTime, leafHash})
+	return out
+}
@@ -0,0 +1,297 @@
+package keylog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/merkle"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
+
+// Log provides the interface for an append-only log of the signing
+// keys that have been approved for the signees of each project, so
+// a key that is swapped without approval is caught
+type Log interface {
+	// Signee wraps the signee so its public key is only
+	// returned if it has been approved for the project
+	Signee(project string, signee release.Signee) release.Signee
+
+	// Check asserts that the fingerprint has
+	// been approved for the signee of the project
+	Check(project string, signee release.Signee, fingerprint string) error
+
+	// Approve appends the fingerprint for the signee of the project
+	Approve(project string, signee release.Signee, fingerprint string) (*Entry, error)
+
+	// Entries returns all the entries of the log
+	Entries() []Entry
+
+	// Checkpoint returns the size and root hash of the log
+	Checkpoint() *Checkpoint
+
+	// ConsistencyProof proves that the log of the size is
+	// a prefix of the log at its current checkpoint
+	ConsistencyProof(size uint64) ([][]byte, error)
+}
+
+// Entry records the approval of a signing key, every entry contains
+// the hash of the one before it, chaining them together
+type Entry struct {
+	Project     string             `json:"project"`
+	SigneeType  release.SigneeType `json:"signeeType"`
+	User        string             `json:"user"`
+	Key         string             `json:"key"`
+	Fingerprint string             `json:"fingerprint"`
+	Timestamp   time.Time          `json:"timestamp"`
+	Previous    string             `json:"previous"`
+}
+
+// Checkpoint contains the size and root hash of the
+// log, clients keep it to detect a rewritten log
+type Checkpoint struct {
+	Size uint64 `json:"size"`
+	Root []byte `json:"root"`
+}
+
+// nolint
+var (
+	ErrKeyNotApproved = errors.New("signing key not approved")
+	ErrLogCorrupted   = errors.New("key log corrupted")
+)
+
+type log struct {
+	file    string
+	entries []Entry
+	hashes  []string
+	tree    *merkle.Tree
+}
+
+// Approve waits this long for another process to unlock the log
+const (
+	lockTimeout = 10 * time.Second
+	lockRetry   = 50 * time.Millisecond
+)
+
+// Open opens the log stored in the file, creating it if
+// needed, and checks that its entries are chained together
+func Open(file string) (Log, error) {
+	l := &log{
+		file: file,
+	}
+	err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// read replaces the entries with those in the file
+func (l *log) read() error {
+	l.entries = nil
+	l.hashes = nil
+	l.tree = merkle.NewTree()
+
+	f, err := os.Open(l.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to open key log")
+	}
+	defer f.Close() // nolint: errcheck
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		entry := Entry{}
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			return errors.Wrapf(ErrLogCorrupted, "entry: %d: %s", len(l.entries), err)
+		}
+		// The stored form must be the canonical one,
+		// as that is what the hashes are made of
+		data, _ := json.Marshal(entry)
+		if !bytes.Equal(data, line) || entry.Previous != l.head() {
+			return errors.Wrapf(ErrLogCorrupted, "entry: %d", len(l.entries))
+		}
+		l.append(entry, data)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return errors.Wrap(err, "failed to read key log")
+	}
+	return nil
+}
+
+// lock creates the lock file next to the log, waiting for
+// another process that holds it, and returns its unlock
+func (l *log) lock() (func(), error) {
+	lockFile := l.file + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close() // nolint: errcheck, gosec
+			return func() {
+				os.Remove(lockFile) // nolint: errcheck, gosec
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "failed to lock key log")
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("key log is locked, remove: %s if no approval is running", lockFile)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// head returns the hash of the last entry
+func (l *log) head() string {
+	if len(l.hashes) == 0 {
+		return ""
+	}
+	return l.hashes[len(l.hashes)-1]
+}
+
+func (l *log) append(entry Entry, data []byte) {
+	l.entries = append(l.entries, entry)
+	l.hashes = append(l.hashes, hex.EncodeToString(merkle.LeafHash(data)))
+	l.tree.Append(data)
+}
+
+// Check the fingerprint
+func (l *log) Check(project string, signee release.Signee, fingerprint string) error {
+	for _, e := range l.entries {
+		if e.Project == project && e.SigneeType == signee.Type() && e.User == signee.User() && e.Key == signee.Key() &&
+			pgp.SameFingerprint(e.Fingerprint, fingerprint) {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrKeyNotApproved, "project: %s, %s signee: %s, fingerprint: %s", project, signee.Type(), signee.User(), fingerprint)
+}
+
+// Approve the fingerprint, the log is locked and read again first,
+// so the entry is chained to any approved by another process
+func (l *log) Approve(project string, signee release.Signee, fingerprint string) (*Entry, error) {
+	err := os.MkdirAll(filepath.Dir(l.file), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	unlock, err := l.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = l.read()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := Entry{
+		Project:     project,
+		SigneeType:  signee.Type(),
+		User:        signee.User(),
+		Key:         signee.Key(),
+		Fingerprint: fingerprint,
+		Timestamp:   time.Now().UTC().Truncate(time.Second),
+		Previous:    l.head(),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode key log entry")
+	}
+
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open key log")
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close() // nolint: errcheck, gosec
+		return nil, errors.Wrap(err, "failed to append to key log")
+	}
+	err = f.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to close key log")
+	}
+
+	l.append(entry, data)
+	return &entry, nil
+}
+
+// Entries returns the entries
+func (l *log) Entries() []Entry {
+	return append([]Entry{}, l.entries...)
+}
+
+// Checkpoint returns the current checkpoint
+func (l *log) Checkpoint() *Checkpoint {
+	root, _ := l.tree.Root(l.tree.Size())
+	return &Checkpoint{
+		Size: l.tree.Size(),
+		Root: root,
+	}
+}
+
+// ConsistencyProof from the size to the current checkpoint
+func (l *log) ConsistencyProof(size uint64) ([][]byte, error) {
+	return l.tree.ConsistencyProof(size, l.tree.Size())
+}
+
+// VerifyConsistency asserts that the log at the newer checkpoint
+// still contains the log at the older one, i.e., it wasn't rewritten
+func VerifyConsistency(older, newer *Checkpoint, proof [][]byte) error {
+	return merkle.VerifyConsistency(older.Size, newer.Size, older.Root, newer.Root, proof)
+}
+
+// Signee wraps the signee
+func (l *log) Signee(project string, signee release.Signee) release.Signee {
+	return &loggedSignee{
+		Signee:  signee,
+		project: project,
+		log:     l,
+	}
+}
+
+type loggedSignee struct {
+	release.Signee
+	project string
+	log     *log
+}
+
+// PublicKey returns the public key of the wrapped signee
+// if its fingerprint has been approved for the project
+func (s *loggedSignee) PublicKey() ([]byte, error) {
+	return s.PublicKeyContext(context.Background())
+}
+
+// PublicKeyContext is PublicKey where the context
+// applies to fetching the key of the wrapped signee
+func (s *loggedSignee) PublicKeyContext(ctx context.Context) ([]byte, error) {
+	publicKey, err := release.NewSigneeContext(s.Signee).PublicKeyContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := pgp.Fingerprint(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key fingerprint")
+	}
+	err = s.log.Check(s.project, s.Signee, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
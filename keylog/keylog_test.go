@@ -0,0 +1,149 @@
+package keylog_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/keylog"
+	"github.com/stoic-cli/stoic-release/merkle"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keylog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	file := filepath.Join(dir, "keys.log")
+
+	l, err := keylog.Open(file)
+	assert.Nil(t, err)
+
+	bob := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.SignerPub, nil)
+	_, err = l.Signee(mock.ProjectName, bob).PublicKey()
+	assert.Equal(t, keylog.ErrKeyNotApproved, errors.Cause(err))
+
+	entry, err := l.Approve(mock.ProjectName, bob, mock.SignerFingerPrint)
+	assert.Nil(t, err)
+	assert.Equal(t, "", entry.Previous)
+	got, err := l.Signee(mock.ProjectName, bob).PublicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerPub, got)
+
+	// Approvals are per project
+	_, err = l.Signee("OtherProject", bob).PublicKey()
+	assert.Equal(t, keylog.ErrKeyNotApproved, errors.Cause(err))
+
+	// A swapped key isn't approved
+	swapped := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.AltSignerPub, nil)
+	_, err = l.Signee(mock.ProjectName, swapped).PublicKey()
+	assert.Equal(t, keylog.ErrKeyNotApproved, errors.Cause(err))
+
+	checkpoint := l.Checkpoint()
+	assert.Equal(t, uint64(1), checkpoint.Size)
+
+	entry, err = l.Approve(mock.ProjectName, swapped, "2cd01af02299ada64e03c9155331c126086e1c18")
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", entry.Previous)
+
+	// The log is read back from the file
+	l, err = keylog.Open(file)
+	assert.Nil(t, err)
+	assert.Len(t, l.Entries(), 2)
+	_, err = l.Signee(mock.ProjectName, swapped).PublicKey()
+	assert.Nil(t, err)
+
+	proof, err := l.ConsistencyProof(checkpoint.Size)
+	assert.Nil(t, err)
+	assert.Nil(t, keylog.VerifyConsistency(checkpoint, l.Checkpoint(), proof))
+}
+
+func TestRewrittenKeyLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keylog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	file := filepath.Join(dir, "keys.log")
+
+	l, err := keylog.Open(file)
+	assert.Nil(t, err)
+	bob := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.SignerPub, nil)
+	_, err = l.Approve(mock.ProjectName, bob, mock.SignerFingerPrint)
+	assert.Nil(t, err)
+	_, err = l.Approve("OtherProject", bob, mock.SignerFingerPrint)
+	assert.Nil(t, err)
+	checkpoint := l.Checkpoint()
+
+	content, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+
+	// Editing an entry breaks the chain
+	tampered := strings.Replace(string(content), mock.SignerFingerPrint, strings.Repeat("0", 40), 1)
+	assert.Nil(t, ioutil.WriteFile(file, []byte(tampered), 0644))
+	_, err = keylog.Open(file)
+	assert.Equal(t, keylog.ErrLogCorrupted, errors.Cause(err))
+
+	// Rewriting the whole chain is caught by the consistency proof
+	var entries []keylog.Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		entry := keylog.Entry{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Nil(t, os.Remove(file))
+	l, err = keylog.Open(file)
+	assert.Nil(t, err)
+	swapped := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.AltSignerPub, nil)
+	_, err = l.Approve(entries[0].Project, swapped, "2cd01af02299ada64e03c9155331c126086e1c18")
+	assert.Nil(t, err)
+	_, err = l.Approve(entries[1].Project, bob, entries[1].Fingerprint)
+	assert.Nil(t, err)
+	_, err = l.Approve(mock.ProjectName, bob, mock.SignerFingerPrint)
+	assert.Nil(t, err)
+
+	l, err = keylog.Open(file)
+	assert.Nil(t, err)
+	proof, err := l.ConsistencyProof(checkpoint.Size)
+	assert.Nil(t, err)
+	assert.Equal(t, merkle.ErrInvalidConsistency, keylog.VerifyConsistency(checkpoint, l.Checkpoint(), proof))
+}
+
+func TestConcurrentApprovals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keylog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	file := filepath.Join(dir, "keys.log")
+
+	// Each log is opened before any approval, so
+	// they only see the others' under the lock
+	var logs []keylog.Log
+	for i := 0; i < 5; i++ {
+		l, err := keylog.Open(file)
+		assert.Nil(t, err)
+		logs = append(logs, l)
+	}
+
+	bob := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.SignerPub, nil)
+	var wg sync.WaitGroup
+	for _, l := range logs {
+		wg.Add(1)
+		go func(l keylog.Log) {
+			defer wg.Done()
+			_, err := l.Approve(mock.ProjectName, bob, mock.SignerFingerPrint)
+			assert.Nil(t, err)
+		}(l)
+	}
+	wg.Wait()
+
+	l, err := keylog.Open(file)
+	assert.Nil(t, err)
+	assert.Len(t, l.Entries(), len(logs))
+	_, err = os.Stat(file + ".lock")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,277 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stoic-cli/stoic-release/vault"
+)
+
+// Keystore provides the interface for storing signing
+// keys on disk, the private keys are always sealed
+// with a passphrase
+type Keystore interface {
+	// Save seals the private key of the key pair with a passphrase
+	// from the prompt and stores it together with the public key
+	Save(name string, keyPair *pgp.KeyPair) error
+
+	// Load opens the private key with a passphrase
+	// from the prompt and returns it as a signatory
+	Load(name string) (release.Signatory, error)
+
+	// PublicKey returns the armored public key
+	PublicKey(name string) ([]byte, error)
+
+	// List returns the names of the stored keys
+	List() ([]string, error)
+
+	// Rename changes the name of a stored key
+	Rename(from, to string) error
+
+	// Delete removes a stored key
+	Delete(name string) error
+}
+
+const (
+	privateKeyExt = ".key"
+	publicKeyExt  = ".pub"
+)
+
+// nolint
+var (
+	ErrInvalidName = errors.New("invalid key name")
+	ErrKeyExists   = errors.New("key already exists")
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.@-]*$`)
+
+type keystore struct {
+	directory string
+	prompt    Prompt
+}
+
+// New creates a keystore that keeps its keys in the directory
+func New(directory string, prompt Prompt) Keystore {
+	return &keystore{
+		directory: directory,
+		prompt:    prompt,
+	}
+}
+
+func (ks *keystore) path(name, ext string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", errors.Wrap(ErrInvalidName, name)
+	}
+	absPath, err := filepath.Abs(ks.directory)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get absolute path")
+	}
+	return path.Join(absPath, name+ext), nil
+}
+
+func (ks *keystore) exists(name string) (bool, error) {
+	p, err := ks.path(name, privateKeyExt)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to stat key: %s", name)
+	}
+	return true, nil
+}
+
+// Save the key pair
+func (ks *keystore) Save(name string, keyPair *pgp.KeyPair) error {
+	exists, err := ks.exists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Wrap(ErrKeyExists, name)
+	}
+
+	err = os.MkdirAll(ks.directory, 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+
+	pass, err := ks.prompt.Passphrase(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get passphrase")
+	}
+	defer pass.Destroy()
+
+	sealed, err := vault.Seal(pass, keyPair.PrivateKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to seal key: %s", name)
+	}
+
+	privPath, _ := ks.path(name, privateKeyExt)
+	pubPath, _ := ks.path(name, publicKeyExt)
+	err = writeFile(pubPath, keyPair.PublicKey, 0644)
+	if err != nil {
+		return err
+	}
+	return writeFile(privPath, sealed, 0600)
+}
+
+// writeFile writes to a temporary file first, so a
+// key is never left half written
+func writeFile(name string, content []byte, perm os.FileMode) error {
+	tmp := name + ".tmp"
+	err := ioutil.WriteFile(tmp, content, perm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write file: %s", filepath.Base(name))
+	}
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp) // nolint: errcheck, gosec
+		return errors.Wrapf(err, "failed to write file: %s", filepath.Base(name))
+	}
+	return nil
+}
+
+// Load the private key as a signatory
+func (ks *keystore) Load(name string) (release.Signatory, error) {
+	privKey, err := ks.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return release.NewSignatory(privKey), nil
+}
+
+func (ks *keystore) open(name string) (*memguard.LockedBuffer, error) {
+	p, err := ks.path(name, privateKeyExt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrap(ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key: %s", name)
+	}
+
+	pass, err := ks.prompt.Passphrase(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get passphrase")
+	}
+	defer pass.Destroy()
+
+	privKey, err := vault.Open(pass, sealed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open key: %s", name)
+	}
+	return privKey, nil
+}
+
+// PublicKey returns the public key
+func (ks *keystore) PublicKey(name string) ([]byte, error) {
+	p, err := ks.path(name, publicKeyExt)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrap(ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read public key: %s", name)
+	}
+	return pubKey, nil
+}
+
+// List the stored keys
+func (ks *keystore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(ks.directory)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read directory")
+	}
+
+	var names []string
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), privateKeyExt) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(f.Name(), privateKeyExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Rename the key
+func (ks *keystore) Rename(from, to string) error {
+	exists, err := ks.exists(from)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.Wrap(ErrKeyNotFound, from)
+	}
+	exists, err = ks.exists(to)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Wrap(ErrKeyExists, to)
+	}
+
+	// The private key decides whether a key exists, so it is renamed
+	// first and moved back if the public key can't be renamed
+	fromKey, _ := ks.path(from, privateKeyExt)
+	toKey, _ := ks.path(to, privateKeyExt)
+	err = os.Rename(fromKey, toKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to rename key: %s", from)
+	}
+
+	fromPub, _ := ks.path(from, publicKeyExt)
+	toPub, _ := ks.path(to, publicKeyExt)
+	err = os.Rename(fromPub, toPub)
+	if err != nil && !os.IsNotExist(err) {
+		restoreErr := os.Rename(toKey, fromKey)
+		if restoreErr != nil {
+			return errors.Wrapf(err, "failed to rename key: %s, and to restore it: %s", from, restoreErr)
+		}
+		return errors.Wrapf(err, "failed to rename key: %s", from)
+	}
+	return nil
+}
+
+// Delete the key
+func (ks *keystore) Delete(name string) error {
+	exists, err := ks.exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.Wrap(ErrKeyNotFound, name)
+	}
+
+	for _, ext := range []string{privateKeyExt, publicKeyExt} {
+		p, _ := ks.path(name, ext)
+		err = os.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to delete key: %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package keystore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/keystore"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyPair(t *testing.T) *pgp.KeyPair {
+	privKey, err := mock.ArmoredToByte(mock.SignerPriv)
+	assert.Nil(t, err)
+	guarded, err := memguard.NewImmutableFromBytes(privKey)
+	assert.Nil(t, err)
+	return &pgp.KeyPair{
+		PublicKey:  mock.SignerPub,
+		PrivateKey: guarded,
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	os.Setenv("KEYSTORE_TEST_PASSPHRASE", "secret") // nolint: errcheck
+	defer os.Unsetenv("KEYSTORE_TEST_PASSPHRASE")   // nolint: errcheck
+
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("first\r\nsecond\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	fdPrompt := keystore.NewFDPrompt(r.Fd())
+
+	testCases := []struct {
+		name      string
+		prompt    keystore.Prompt
+		expect    string
+		expectErr bool
+	}{
+		{
+			name:   "Env",
+			prompt: keystore.NewEnvPrompt("KEYSTORE_TEST_PASSPHRASE"),
+			expect: "secret",
+		},
+		{
+			name:      "Env unset",
+			prompt:    keystore.NewEnvPrompt("KEYSTORE_TEST_UNSET"),
+			expect:    keystore.ErrEmptyPassphrase.Error(),
+			expectErr: true,
+		},
+		{
+			name:   "FD first line",
+			prompt: fdPrompt,
+			expect: "first",
+		},
+		{
+			name:   "FD second line",
+			prompt: fdPrompt,
+			expect: "second",
+		},
+		{
+			name:      "FD exhausted",
+			prompt:    fdPrompt,
+			expect:    "failed to read passphrase: EOF",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pass, err := tc.prompt.Passphrase("key")
+			if tc.expectErr {
+				assert.Equal(t, tc.expect, err.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expect, string(pass.Buffer()))
+				pass.Destroy()
+			}
+		})
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	passphrase := "correct horse"
+	prompt := keystore.PromptFunc(func(_ string) (*memguard.LockedBuffer, error) {
+		return memguard.NewImmutableFromBytes([]byte(passphrase))
+	})
+	ks := keystore.New(path.Join(dir, "keys"), prompt)
+
+	names, err := ks.List()
+	assert.Nil(t, err)
+	assert.Empty(t, names)
+
+	err = ks.Save("../escape", testKeyPair(t))
+	assert.Equal(t, keystore.ErrInvalidName, errors.Cause(err))
+
+	err = ks.Save("release", testKeyPair(t))
+	assert.Nil(t, err)
+	err = ks.Save("release", testKeyPair(t))
+	assert.Equal(t, keystore.ErrKeyExists, errors.Cause(err))
+
+	info, err := os.Stat(path.Join(dir, "keys", "release.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	pubKey, err := ks.PublicKey("release")
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerPub, pubKey)
+
+	signatory, err := ks.Load("release")
+	assert.Nil(t, err)
+	signature, err := release.NewSigner(nil).Sign(signatory, []byte("content"))
+	assert.Nil(t, err)
+	_, err = pgp.Verify(pubKey, []byte("content"), signature, nil)
+	assert.Nil(t, err)
+
+	passphrase = "wrong"
+	_, err = ks.Load("release")
+	assert.NotNil(t, err)
+	passphrase = "correct horse"
+
+	// A public key that can't be renamed leaves the key as it was
+	blocker := path.Join(dir, "keys", "old-release.pub", "blocker")
+	err = os.MkdirAll(blocker, 0700)
+	assert.Nil(t, err)
+	err = ks.Rename("release", "old-release")
+	assert.Error(t, err)
+	names, err = ks.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"release"}, names)
+	_, err = ks.Load("release")
+	assert.Nil(t, err)
+	err = os.RemoveAll(path.Dir(blocker))
+	assert.Nil(t, err)
+
+	err = ks.Rename("release", "old-release")
+	assert.Nil(t, err)
+	names, err = ks.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"old-release"}, names)
+	_, err = ks.Load("old-release")
+	assert.Nil(t, err)
+
+	err = ks.Delete("old-release")
+	assert.Nil(t, err)
+	_, err = ks.Load("old-release")
+	assert.Equal(t, keystore.ErrKeyNotFound, errors.Cause(err))
+	err = ks.Delete("old-release")
+	assert.Equal(t, keystore.ErrKeyNotFound, errors.Cause(err))
+}
@@ -0,0 +1,119 @@
+package keystore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Prompt provides the interface required for
+// fetching the passphrase of a key
+type Prompt interface {
+	// Passphrase returns the passphrase for
+	// the key with the given name
+	Passphrase(name string) (*memguard.LockedBuffer, error)
+}
+
+// PromptFunc allows an ordinary function
+// to be used as a prompt
+type PromptFunc func(name string) (*memguard.LockedBuffer, error)
+
+// Passphrase calls the function
+func (f PromptFunc) Passphrase(name string) (*memguard.LockedBuffer, error) {
+	return f(name)
+}
+
+// ErrEmptyPassphrase indicates that the
+// prompt provided no passphrase
+var ErrEmptyPassphrase = errors.New("empty passphrase")
+
+func guard(passphrase []byte) (*memguard.LockedBuffer, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	guarded, err := memguard.NewImmutableFromBytes(passphrase) // This also wipes the passphrase
+	if err != nil {
+		if guarded != nil {
+			guarded.Destroy()
+		}
+		return nil, errors.Wrap(err, "failed to protect passphrase")
+	}
+	return guarded, nil
+}
+
+type envPrompt struct {
+	variable string
+}
+
+// NewEnvPrompt creates a prompt that reads the passphrase from
+// an environment variable, e.g., a secret variable in CI
+func NewEnvPrompt(variable string) Prompt {
+	return &envPrompt{
+		variable: variable,
+	}
+}
+
+// Passphrase returns the content of the environment variable
+func (p *envPrompt) Passphrase(_ string) (*memguard.LockedBuffer, error) {
+	return guard([]byte(os.Getenv(p.variable)))
+}
+
+type fdPrompt struct {
+	mu     sync.Mutex
+	reader *bufio.Reader
+}
+
+// NewFDPrompt creates a prompt that reads the passphrase from a
+// file descriptor, one line per passphrase, like gpg --passphrase-fd
+func NewFDPrompt(fd uintptr) Prompt {
+	return &fdPrompt{
+		reader: bufio.NewReader(os.NewFile(fd, "passphrase")),
+	}
+}
+
+// Passphrase returns the next line read from the file descriptor
+func (p *fdPrompt) Passphrase(_ string) (*memguard.LockedBuffer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	line, err := p.reader.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+	defer memguard.WipeBytes(line)
+	return guard(bytes.TrimRight(line, "\r\n"))
+}
+
+type ttyPrompt struct{}
+
+// NewTTYPrompt creates a prompt that asks for the passphrase
+// on the controlling terminal without echoing it
+func NewTTYPrompt() Prompt {
+	return &ttyPrompt{}
+}
+
+// Passphrase asks for the passphrase on the terminal
+func (p *ttyPrompt) Passphrase(name string) (*memguard.LockedBuffer, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open terminal")
+	}
+	defer tty.Close() // nolint: errcheck
+
+	_, err = fmt.Fprintf(tty, "Enter passphrase for key %s: ", name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to write to terminal")
+	}
+	passphrase, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty) // nolint: errcheck
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+	return guard(passphrase)
+}
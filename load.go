@@ -2,12 +2,15 @@ package release
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/stoic-cli/stoic-release/pgp"
@@ -16,7 +19,7 @@ import (
 // Loader defines the operations required for
 // loading a created release
 type Loader interface {
-	Load() (signature []byte, manifester Manifester, artifacts []Artifact, err error)
+	Load() (signatures [][]byte, manifester Manifester, artifacts []Artifact, err error)
 }
 
 // LoadFinaliser defines the operations required
@@ -42,6 +45,16 @@ func NewLoader(loader Loader, deployer Deployer, deployers ...Deployer) (LoadFin
 	}, nil
 }
 
+// LoadContext loads the release until the context is done
+func (l *loadFinaliser) LoadContext(ctx context.Context) ([][]byte, Manifester, []Artifact, error) {
+	return NewLoaderContext(l.Loader).LoadContext(ctx)
+}
+
+// DeployContext deploys the release with all deployers until the context is done
+func (l *loadFinaliser) DeployContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return NewDeployerContext(l.Deployer).DeployContext(ctx, signatures, manifest, artifacts)
+}
+
 type fileSystemLoader struct {
 	directory string
 }
@@ -54,7 +67,16 @@ func NewFileSystemLoader(directory string) Loader {
 	}
 }
 
-func (fs *fileSystemLoader) Load() ([]byte, Manifester, []Artifact, error) {
+func (fs *fileSystemLoader) Load() ([][]byte, Manifester, []Artifact, error) {
+	return fs.LoadContext(context.Background())
+}
+
+// LoadContext loads the release from the file system,
+// reading stops once the context is done
+func (fs *fileSystemLoader) LoadContext(ctx context.Context) ([][]byte, Manifester, []Artifact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
 	absPath, err := filepath.Abs(fs.directory)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "failed to get absolute path")
@@ -69,19 +91,17 @@ func (fs *fileSystemLoader) Load() ([]byte, Manifester, []Artifact, error) {
 		return nil, nil, nil, errors.Wrap(err, "failed to load manifest")
 	}
 
-	var signature bytes.Buffer
-	manifestSigFile, err := fileFromGlob(absPath, "*.manifest.asc")
+	signatures, err := loadSignatures(absPath, manifester.NormalisedName())
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	_, err = io.Copy(&signature, manifestSigFile)
-	if err != nil {
-		return nil, nil, nil, errors.Wrap(err, "failed to load manifest signature")
-	}
 
 	var artifacts []Artifact
 	manifestArtifacts := manifester.Artifacts()
 	for _, artifact := range manifestArtifacts {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
 		f, err := os.Open(path.Join(absPath, artifact.Name))
 		if err != nil {
 			return nil, nil, nil, errors.Wrapf(err, "failed to load artifact: %s", artifact.Name)
@@ -94,7 +114,7 @@ func (fs *fileSystemLoader) Load() ([]byte, Manifester, []Artifact, error) {
 		case ArtifactTypeBinary:
 			binRegex := regexp.MustCompile(".*-(?P<os>[a-z]+).(?P<arch>[a-z0-9]+).bin$")
 			result := make(map[string]string)
-			match := binRegex.FindStringSubmatch(artifact.Name)
+			match := binRegex.FindStringSubmatch(strings.TrimSuffix(artifact.Name, EncryptedArtifactExt))
 			for i, name := range binRegex.SubexpNames() {
 				if i != 0 && name != "" {
 					result[name] = match[i]
@@ -108,10 +128,43 @@ func (fs *fileSystemLoader) Load() ([]byte, Manifester, []Artifact, error) {
 			return nil, nil, nil, errors.Wrap(err, "failed to recreate artifact")
 		}
 		art.SetDigests(artifact.Digests)
+
+		signature, err := ioutil.ReadFile(path.Join(absPath, signatureName(artifact.Name, 0)))
+		if err != nil && (artifact.Signed || !os.IsNotExist(err)) {
+			return nil, nil, nil, errors.Wrapf(err, "failed to load artifact signature: %s", artifact.Name)
+		}
+		art.SetSignature(signature)
+
 		artifacts = append(artifacts, art)
 	}
 
-	return signature.Bytes(), manifester, artifacts, nil
+	return signatures, manifester, artifacts, nil
+}
+
+// loadSignatures reads all the detached signatures stored
+// for the given file name
+func loadSignatures(basePath, name string) ([][]byte, error) {
+	var signatures [][]byte
+	for n := 0; ; n++ {
+		f, err := os.Open(path.Join(basePath, signatureName(name, n)))
+		if os.IsNotExist(err) && n > 0 {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open signature: %s", signatureName(name, n))
+		}
+		var signature bytes.Buffer
+		_, err = io.Copy(&signature, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load signature: %s", signatureName(name, n))
+		}
+		err = f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to close signature: %s", signatureName(name, n))
+		}
+		signatures = append(signatures, signature.Bytes())
+	}
+	return signatures, nil
 }
 
 func fileFromGlob(basePath, pattern string) (*os.File, error) {
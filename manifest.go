@@ -22,6 +22,7 @@ type Manifester interface {
 	NormalisedName() string
 	Version() string
 	Artifacts() []ManifestArtifact
+	Signees() []ManifestSignee
 	Serialise() (io.Reader, error)
 }
 
@@ -30,23 +31,31 @@ type Manifest struct {
 	ReleaseName      string             `yaml:"name"`
 	ReleaseVersion   string             `yaml:"version"`
 	ReleaseSignee    ManifestSignee     `yaml:"signee"`
+	ReleaseSignees   []ManifestSignee   `yaml:"signees,omitempty"`
 	ReleaseArtifacts []ManifestArtifact `yaml:"artifacts"`
 }
 
 // ManifestSignee contains the identity that signed
-// a release
+// a release and the signature scheme that was used,
+// an empty scheme is treated as pgp
 type ManifestSignee struct {
-	User string
-	Key  string
-	Type SigneeType
+	User   string
+	Key    string
+	Type   SigneeType
+	Scheme SignatureScheme `yaml:",omitempty"`
 }
 
 // ManifestArtifact contains the metadata of a
-// release artifact
+// release artifact, the plaintext digests are only
+// set for encrypted artifacts. Signed records that
+// the artifact has a detached signature, so it can't
+// be stripped without invalidating the manifest
 type ManifestArtifact struct {
-	Name    string
-	Type    ArtifactType
-	Digests map[DigestType]string
+	Name             string
+	Type             ArtifactType
+	Digests          map[DigestType]string
+	PlaintextDigests map[DigestType]string `yaml:",omitempty"`
+	Signed           bool                  `yaml:",omitempty"`
 }
 
 // NewManifestLoader returns a loader for recreating
@@ -57,24 +66,53 @@ func NewManifestLoader() ManifestLoader {
 
 // NewManifest creates a new manifest
 func NewManifest(projectName string, version string, signee Signee, artifacts []Artifact) Manifester {
+	return NewMultiSigneeManifest(projectName, version, []Signee{signee}, artifacts)
+}
+
+// NewMultiSigneeManifest creates a new manifest that is
+// expected to be signed by all of the provided signees
+func NewMultiSigneeManifest(projectName string, version string, signees []Signee, artifacts []Artifact) Manifester {
+	return newManifest(projectName, version, "", signees, artifacts)
+}
+
+func newManifest(projectName string, version string, scheme SignatureScheme, signees []Signee, artifacts []Artifact) *Manifest {
+	var manifestSignees []ManifestSignee
+	for _, s := range signees {
+		manifestSignees = append(manifestSignees, ManifestSignee{
+			User:   s.User(),
+			Key:    s.Key(),
+			Type:   s.Type(),
+			Scheme: scheme,
+		})
+	}
+
 	var manifestArtifacts []ManifestArtifact
 	for _, a := range artifacts {
-		manifestArtifacts = append(manifestArtifacts, ManifestArtifact{
+		manifestArtifact := ManifestArtifact{
 			Name:    a.NormalisedName(version),
 			Type:    a.Type(),
 			Digests: a.Digests(),
-		})
+			Signed:  len(a.Signature()) > 0,
+		}
+		if encrypted, ok := a.(EncryptedArtifact); ok {
+			manifestArtifact.PlaintextDigests = encrypted.PlaintextDigests()
+		}
+		manifestArtifacts = append(manifestArtifacts, manifestArtifact)
 	}
-	return &Manifest{
-		ReleaseName:    projectName,
-		ReleaseVersion: version,
-		ReleaseSignee: ManifestSignee{
-			User: signee.User(),
-			Key:  signee.Key(),
-			Type: signee.Type(),
-		},
+	m := &Manifest{
+		ReleaseName:      projectName,
+		ReleaseVersion:   version,
 		ReleaseArtifacts: manifestArtifacts,
 	}
+	if len(manifestSignees) > 0 {
+		m.ReleaseSignee = manifestSignees[0]
+	}
+	// Only record the list when there is more than one
+	// signee, so single signee manifests remain unchanged
+	if len(manifestSignees) > 1 {
+		m.ReleaseSignees = manifestSignees
+	}
+	return m
 }
 
 // Serialise encodes a manifest
@@ -112,3 +150,12 @@ func (m *Manifest) Artifacts() []ManifestArtifact {
 func (m *Manifest) Name() string {
 	return m.ReleaseName
 }
+
+// Signees returns the identities that are expected
+// to sign the release
+func (m *Manifest) Signees() []ManifestSignee {
+	if len(m.ReleaseSignees) > 0 {
+		return m.ReleaseSignees
+	}
+	return []ManifestSignee{m.ReleaseSignee}
+}
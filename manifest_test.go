@@ -36,3 +36,20 @@ func TestManifest(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, buf1.String(), buf2.String())
 }
+
+func TestManifestSignees(t *testing.T) {
+	bob := mock.ValidSignee()
+	alice := mock.Signee("2cd01af02299ada64e03c9155331c126086e1c18", "alice", release.GithubSigneeType, mock.AltSignerPub, nil)
+
+	m := release.NewManifest("MyProject", "v1.0.0", bob, mock.ValidArtifacts())
+	assert.Len(t, m.Signees(), 1)
+	assert.Equal(t, bob.User(), m.Signees()[0].User)
+
+	m = release.NewMultiSigneeManifest("MyProject", "v1.0.0", []release.Signee{bob, alice}, mock.ValidArtifacts())
+	reader, err := m.Serialise()
+	assert.Nil(t, err)
+	m2, err := release.NewManifestLoader().Read(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Signees(), m2.Signees())
+	assert.Equal(t, []string{"bob", "alice"}, []string{m2.Signees()[0].User, m2.Signees()[1].User})
+}
@@ -0,0 +1,231 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Implements the Merkle tree hashing, inclusion and consistency proofs described in:
+// https://tools.ietf.org/html/rfc6962#section-2.1
+
+// nolint
+var (
+	ErrIndexOutOfRange    = errors.New("leaf index out of range")
+	ErrInvalidProof       = errors.New("invalid inclusion proof")
+	ErrInvalidConsistency = errors.New("invalid consistency proof")
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// LeafHash returns the hash of a leaf with
+// the provided data
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix}) // nolint: errcheck
+	h.Write(data)               // nolint: errcheck
+	return h.Sum(nil)
+}
+
+// NodeHash returns the hash of an interior
+// node with the provided children
+func NodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix}) // nolint: errcheck
+	h.Write(left)               // nolint: errcheck
+	h.Write(right)              // nolint: errcheck
+	return h.Sum(nil)
+}
+
+// Tree is an in-memory append-only Merkle tree
+type Tree struct {
+	leaves [][]byte
+}
+
+// NewTree creates a new empty tree
+func NewTree() *Tree {
+	return &Tree{}
+}
+
+// Append adds the data as a new leaf and
+// returns the index of the leaf
+func (t *Tree) Append(data []byte) uint64 {
+	t.leaves = append(t.leaves, LeafHash(data))
+	return uint64(len(t.leaves) - 1)
+}
+
+// Size returns the number of leaves in the tree
+func (t *Tree) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// Root returns the root hash of the tree
+// with the given size
+func (t *Tree) Root(size uint64) ([]byte, error) {
+	if size > t.Size() {
+		return nil, ErrIndexOutOfRange
+	}
+	return rootHash(t.leaves[:size]), nil
+}
+
+// InclusionProof returns the audit path of the leaf at
+// index within the tree of the given size
+func (t *Tree) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > t.Size() || index >= size {
+		return nil, ErrIndexOutOfRange
+	}
+	return path(index, t.leaves[:size]), nil
+}
+
+// ConsistencyProof returns the hashes proving that the tree of the
+// first size is a prefix of the tree of the second size
+func (t *Tree) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	if second > t.Size() || first > second {
+		return nil, ErrIndexOutOfRange
+	}
+	if first == 0 {
+		return nil, nil
+	}
+	return subproof(first, t.leaves[:second], true), nil
+}
+
+func rootHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return NodeHash(rootHash(leaves[:k]), rootHash(leaves[k:]))
+}
+
+func path(index uint64, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := split(len(leaves))
+	if index < uint64(k) {
+		return append(path(index, leaves[:k]), rootHash(leaves[k:]))
+	}
+	return append(path(index-uint64(k), leaves[k:]), rootHash(leaves[:k]))
+}
+
+// subproof is SUBPROOF of:
+// https://tools.ietf.org/html/rfc6962#section-2.1.2
+func subproof(m uint64, leaves [][]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{rootHash(leaves)}
+	}
+	k := uint64(split(len(leaves)))
+	if m <= k {
+		return append(subproof(m, leaves[:k], complete), rootHash(leaves[k:]))
+	}
+	return append(subproof(m-k, leaves[k:], false), rootHash(leaves[:k]))
+}
+
+// split returns the largest power of two smaller than n
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// VerifyInclusion asserts that the leaf hash is included at index
+// in the tree of the given size and root hash
+func VerifyInclusion(index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	if index >= size {
+		return ErrIndexOutOfRange
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = NodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = NodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyConsistency asserts that the tree of the first size and root
+// hash is a prefix of the tree of the second size and root hash, see:
+// https://www.rfc-editor.org/rfc/rfc9162#section-2.1.4.2
+func VerifyConsistency(first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) error {
+	switch {
+	case first > second:
+		return ErrIndexOutOfRange
+	case first == second:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return ErrInvalidConsistency
+		}
+		return nil
+	case first == 0:
+		// Every tree is consistent with the empty tree
+		if len(proof) != 0 {
+			return ErrInvalidConsistency
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrInvalidConsistency
+	}
+
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidConsistency
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = NodeHash(c, fr)
+			sr = NodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, firstRoot) || !bytes.Equal(sr, secondRoot) {
+		return ErrInvalidConsistency
+	}
+	return nil
+}
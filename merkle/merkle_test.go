@@ -0,0 +1,96 @@
+package merkle_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stoic-cli/stoic-release/merkle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoot(t *testing.T) {
+	// Test vectors from the certificate transparency reference implementation
+	leaves := []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	roots := []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+
+	tree := merkle.NewTree()
+	for i, leaf := range leaves {
+		data, err := hex.DecodeString(leaf)
+		assert.Nil(t, err)
+		tree.Append(data)
+		root, err := tree.Root(uint64(i + 1))
+		assert.Nil(t, err)
+		assert.Equal(t, roots[i], hex.EncodeToString(root))
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	tree := merkle.NewTree()
+	for i := 0; i < 13; i++ {
+		tree.Append([]byte(fmt.Sprintf("leaf-%d", i)))
+	}
+
+	for size := uint64(1); size <= tree.Size(); size++ {
+		root, err := tree.Root(size)
+		assert.Nil(t, err)
+		for index := uint64(0); index < size; index++ {
+			proof, err := tree.InclusionProof(index, size)
+			assert.Nil(t, err)
+			leaf := merkle.LeafHash([]byte(fmt.Sprintf("leaf-%d", index)))
+			assert.Nil(t, merkle.VerifyInclusion(index, size, leaf, proof, root), "%d/%d", index, size)
+
+			other := merkle.LeafHash([]byte("other"))
+			assert.Equal(t, merkle.ErrInvalidProof, merkle.VerifyInclusion(index, size, other, proof, root))
+		}
+	}
+
+	_, err := tree.InclusionProof(13, 13)
+	assert.Equal(t, merkle.ErrIndexOutOfRange, err)
+}
+
+func TestConsistencyProof(t *testing.T) {
+	tree := merkle.NewTree()
+	for i := 0; i < 13; i++ {
+		tree.Append([]byte(fmt.Sprintf("leaf-%d", i)))
+	}
+
+	other := merkle.NewTree()
+	for i := 0; i < 13; i++ {
+		other.Append([]byte(fmt.Sprintf("other-%d", i)))
+	}
+
+	for second := uint64(1); second <= tree.Size(); second++ {
+		secondRoot, err := tree.Root(second)
+		assert.Nil(t, err)
+		for first := uint64(0); first <= second; first++ {
+			firstRoot, err := tree.Root(first)
+			assert.Nil(t, err)
+			proof, err := tree.ConsistencyProof(first, second)
+			assert.Nil(t, err)
+			assert.Nil(t, merkle.VerifyConsistency(first, second, firstRoot, secondRoot, proof), "%d/%d", first, second)
+
+			// A rewritten history isn't consistent
+			if first == 0 {
+				continue
+			}
+			otherRoot, err := other.Root(first)
+			assert.Nil(t, err)
+			assert.Equal(t, merkle.ErrInvalidConsistency, merkle.VerifyConsistency(first, second, otherRoot, secondRoot, proof), "%d/%d", first, second)
+		}
+	}
+
+	_, err := tree.ConsistencyProof(5, 14)
+	assert.Equal(t, merkle.ErrIndexOutOfRange, err)
+	_, err = tree.ConsistencyProof(6, 5)
+	assert.Equal(t, merkle.ErrIndexOutOfRange, err)
+}
@@ -0,0 +1,287 @@
+package minisign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Implements the minisign/signify compatible file formats as
+// described in: https://jedisct1.github.io/minisign/
+
+// DefaultTime sets the default time function, it is used
+// for creating the default trusted comment
+var DefaultTime = func() time.Time {
+	return time.Now()
+}
+
+const (
+	keyIDSize = 8
+
+	untrustedCommentPrefix = "untrusted comment: "
+	trustedCommentPrefix   = "trusted comment: "
+
+	// Algorithm identifiers, the legacy Ed algorithm signs the message
+	// itself, while ED signs the BLAKE2b-512 digest of the message
+	algorithmLegacy    = "Ed"
+	algorithmPrehashed = "ED"
+
+	kdfNone           = "\x00\x00"
+	checksumAlgorithm = "B2"
+
+	privateKeySize = 2 + 2 + 2 + 32 + 8 + 8 + keyIDSize + ed25519.PrivateKeySize + blake2b.Size256
+	publicKeySize  = 2 + keyIDSize + ed25519.PublicKeySize
+	signatureSize  = 2 + keyIDSize + ed25519.SignatureSize
+)
+
+// nolint
+var (
+	ErrMalformedPublicKey  = errors.New("malformed public key")
+	ErrMalformedPrivateKey = errors.New("malformed private key")
+	ErrMalformedSignature  = errors.New("malformed signature")
+	ErrEncryptedPrivateKey = errors.New("encrypted private keys are not supported")
+	ErrKeyMismatch         = errors.New("signature made by unknown key")
+	ErrInvalidSignature    = errors.New("invalid signature")
+)
+
+// KeyPair contains a minisign keypair that can be used
+// to sign artifacts
+type KeyPair struct {
+	PublicKey   []byte
+	PublicKeyID uint64
+	PrivateKey  *memguard.LockedBuffer
+}
+
+// Signature contains the verified content of a
+// minisign signature
+type Signature struct {
+	KeyID          uint64
+	TrustedComment string
+}
+
+// NewSigner creates a new Ed25519 keypair capable of signing
+// artifacts. The private key is kept in the unencrypted minisign
+// secret key format.
+func NewSigner() (*KeyPair, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate key")
+	}
+	defer memguard.WipeBytes(priv)
+
+	keyID := make([]byte, keyIDSize)
+	_, err = rand.Read(keyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate key id")
+	}
+
+	privKeyGuarded, err := encodePrivateKey(keyID, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		PublicKey:   encodePublicKey(keyID, pub),
+		PublicKeyID: binary.LittleEndian.Uint64(keyID),
+		PrivateKey:  privKeyGuarded,
+	}, nil
+}
+
+func encodePrivateKey(keyID []byte, priv ed25519.PrivateKey) (*memguard.LockedBuffer, error) {
+	var buf bytes.Buffer
+	buf.WriteString(algorithmLegacy)
+	buf.WriteString(kdfNone)
+	buf.WriteString(checksumAlgorithm)
+	buf.Write(make([]byte, 32+8+8)) // salt, ops and mem limits are unused without a kdf
+	buf.Write(keyID)
+	buf.Write(priv)
+	buf.Write(privateKeyChecksum(keyID, priv))
+
+	privKeyGuarded, err := memguard.NewImmutableFromBytes(buf.Bytes()) // This also wipes the buffer
+	if err != nil {
+		if privKeyGuarded != nil {
+			privKeyGuarded.Destroy()
+		}
+		return nil, errors.Wrap(err, "failed to protect private key")
+	}
+	return privKeyGuarded, nil
+}
+
+func privateKeyChecksum(keyID []byte, priv ed25519.PrivateKey) []byte {
+	h, _ := blake2b.New256(nil)
+	h.Write([]byte(algorithmLegacy)) // nolint: errcheck
+	h.Write(keyID)                   // nolint: errcheck
+	h.Write(priv)                    // nolint: errcheck
+	return h.Sum(nil)
+}
+
+func encodePublicKey(keyID []byte, pub ed25519.PublicKey) []byte {
+	raw := append(append([]byte(algorithmLegacy), keyID...), pub...)
+	return []byte(fmt.Sprintf("%sminisign public key %016X\n%s\n",
+		untrustedCommentPrefix,
+		binary.LittleEndian.Uint64(keyID),
+		base64.StdEncoding.EncodeToString(raw),
+	))
+}
+
+// ReadPrivateKey decodes an unencrypted minisign secret key file,
+// e.g., as created by: minisign -G -W
+func ReadPrivateKey(secretKey []byte) (*memguard.LockedBuffer, error) {
+	lines := readLines(secretKey)
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		return nil, ErrMalformedPrivateKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != privateKeySize {
+		return nil, ErrMalformedPrivateKey
+	}
+	if string(raw[2:4]) != kdfNone {
+		memguard.WipeBytes(raw)
+		return nil, ErrEncryptedPrivateKey
+	}
+	return memguard.NewImmutableFromBytes(raw)
+}
+
+func decodePrivateKey(signer *memguard.LockedBuffer) ([]byte, ed25519.PrivateKey, error) {
+	raw := signer.Buffer()
+	if len(raw) != privateKeySize || string(raw[:2]) != algorithmLegacy {
+		return nil, nil, ErrMalformedPrivateKey
+	}
+	if string(raw[2:4]) != kdfNone {
+		return nil, nil, ErrEncryptedPrivateKey
+	}
+	offset := 2 + 2 + 2 + 32 + 8 + 8
+	keyID := raw[offset : offset+keyIDSize]
+	priv := ed25519.PrivateKey(raw[offset+keyIDSize : offset+keyIDSize+ed25519.PrivateKeySize])
+	checksum := raw[offset+keyIDSize+ed25519.PrivateKeySize:]
+	if !bytes.Equal(checksum, privateKeyChecksum(keyID, priv)) {
+		return nil, nil, ErrMalformedPrivateKey
+	}
+	return keyID, priv, nil
+}
+
+// ReadPublicKey decodes a minisign public key, either the
+// full public key file or only the base64 encoded line
+func ReadPublicKey(publicKey []byte) (uint64, ed25519.PublicKey, error) {
+	lines := readLines(publicKey)
+	if len(lines) == 0 {
+		return 0, nil, ErrMalformedPublicKey
+	}
+	encoded := lines[0]
+	if strings.HasPrefix(encoded, untrustedCommentPrefix) {
+		if len(lines) < 2 {
+			return 0, nil, ErrMalformedPublicKey
+		}
+		encoded = lines[1]
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != publicKeySize || string(raw[:2]) != algorithmLegacy {
+		return 0, nil, ErrMalformedPublicKey
+	}
+	return binary.LittleEndian.Uint64(raw[2 : 2+keyIDSize]), ed25519.PublicKey(raw[2+keyIDSize:]), nil
+}
+
+// Sign creates a minisign signature of the provided data. If no
+// trusted comment is given a timestamp is used, like minisign does.
+func Sign(signer *memguard.LockedBuffer, sign []byte, trustedComment string) ([]byte, error) {
+	keyID, priv, err := decodePrivateKey(signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read private key")
+	}
+	if strings.ContainsAny(trustedComment, "\r\n") {
+		return nil, fmt.Errorf("trusted comment must be a single line")
+	}
+	if len(trustedComment) == 0 {
+		trustedComment = fmt.Sprintf("timestamp:%d", DefaultTime().Unix())
+	}
+
+	digest := blake2b.Sum512(sign)
+	signature := ed25519.Sign(priv, digest[:])
+	globalSignature := ed25519.Sign(priv, append(append([]byte{}, signature...), trustedComment...))
+
+	raw := append(append([]byte(algorithmPrehashed), keyID...), signature...)
+
+	var signed bytes.Buffer
+	fmt.Fprintf(&signed, "%ssignature from minisign secret key\n", untrustedCommentPrefix)
+	fmt.Fprintf(&signed, "%s\n", base64.StdEncoding.EncodeToString(raw))
+	fmt.Fprintf(&signed, "%s%s\n", trustedCommentPrefix, trustedComment)
+	fmt.Fprintf(&signed, "%s\n", base64.StdEncoding.EncodeToString(globalSignature))
+
+	return signed.Bytes(), nil
+}
+
+// Verify checks that the signature was made by the provided public key
+// and that the trusted comment hasn't been tampered with
+func Verify(signer []byte, signed []byte, signature []byte) (*Signature, error) {
+	keyID, pub, err := ReadPublicKey(signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key")
+	}
+
+	lines := readLines(signature)
+	if len(lines) < 4 ||
+		!strings.HasPrefix(lines[0], untrustedCommentPrefix) ||
+		!strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return nil, ErrMalformedSignature
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != signatureSize {
+		return nil, ErrMalformedSignature
+	}
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return nil, ErrMalformedSignature
+	}
+
+	if binary.LittleEndian.Uint64(raw[2:2+keyIDSize]) != keyID {
+		return nil, ErrKeyMismatch
+	}
+
+	message := signed
+	switch string(raw[:2]) {
+	case algorithmPrehashed:
+		digest := blake2b.Sum512(signed)
+		message = digest[:]
+	case algorithmLegacy:
+	default:
+		return nil, ErrMalformedSignature
+	}
+
+	sig := raw[2+keyIDSize:]
+	if !ed25519.Verify(pub, message, sig) {
+		return nil, ErrInvalidSignature
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], trustedCommentPrefix)
+	if !ed25519.Verify(pub, append(append([]byte{}, sig...), trustedComment...), globalSignature) {
+		return nil, ErrInvalidSignature
+	}
+
+	return &Signature{
+		KeyID:          keyID,
+		TrustedComment: trustedComment,
+	}, nil
+}
+
+func readLines(in []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
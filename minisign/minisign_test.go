@@ -0,0 +1,118 @@
+package minisign_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stoic-cli/stoic-release/minisign"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHappyPath(t *testing.T) {
+	kp, err := minisign.NewSigner()
+	assert.Nil(t, err)
+
+	msg := []byte("This is my message\n")
+	signed, err := minisign.Sign(kp.PrivateKey, msg, "file:message.txt")
+	assert.Nil(t, err)
+
+	sig, err := minisign.Verify(kp.PublicKey, msg, signed)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKeyID, sig.KeyID)
+	assert.Equal(t, "file:message.txt", sig.TrustedComment)
+
+	keyID, _, err := minisign.ReadPublicKey(kp.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKeyID, keyID)
+}
+
+func TestDefaultTrustedComment(t *testing.T) {
+	minisign.DefaultTime = func() time.Time {
+		return time.Unix(13455533, 0)
+	}
+	defer func() { minisign.DefaultTime = time.Now }()
+
+	kp, err := minisign.NewSigner()
+	assert.Nil(t, err)
+	signed, err := minisign.Sign(kp.PrivateKey, []byte("msg"), "")
+	assert.Nil(t, err)
+	sig, err := minisign.Verify(kp.PublicKey, []byte("msg"), signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "timestamp:13455533", sig.TrustedComment)
+}
+
+func TestVerify(t *testing.T) {
+	kp, err := minisign.NewSigner()
+	assert.Nil(t, err)
+	alt, err := minisign.NewSigner()
+	assert.Nil(t, err)
+
+	msg := []byte("This is my message\n")
+	signed, err := minisign.Sign(kp.PrivateKey, msg, "trusted")
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		publicKey []byte
+		signed    []byte
+		signature []byte
+		expect    error
+	}{
+		{
+			name:      "Wrong key",
+			publicKey: alt.PublicKey,
+			signed:    msg,
+			signature: signed,
+			expect:    minisign.ErrKeyMismatch,
+		},
+		{
+			name:      "Modified message",
+			publicKey: kp.PublicKey,
+			signed:    []byte("This is not my message\n"),
+			signature: signed,
+			expect:    minisign.ErrInvalidSignature,
+		},
+		{
+			name:      "Modified trusted comment",
+			publicKey: kp.PublicKey,
+			signed:    msg,
+			signature: []byte(strings.Replace(string(signed), "trusted comment: trusted", "trusted comment: untrusted", 1)),
+			expect:    minisign.ErrInvalidSignature,
+		},
+		{
+			name:      "Malformed signature",
+			publicKey: kp.PublicKey,
+			signed:    msg,
+			signature: []byte("not a signature"),
+			expect:    minisign.ErrMalformedSignature,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := minisign.Verify(tc.publicKey, tc.signed, tc.signature)
+		assert.Equal(t, tc.expect, err, tc.name)
+	}
+}
+
+func TestReadPrivateKey(t *testing.T) {
+	encode := func(raw []byte) []byte {
+		return []byte("untrusted comment: minisign secret key\n" + base64.StdEncoding.EncodeToString(raw) + "\n")
+	}
+
+	_, err := minisign.ReadPrivateKey(encode([]byte("EdScB2")))
+	assert.Equal(t, minisign.ErrMalformedPrivateKey, err)
+
+	// The scrypt encrypted secret key format created by: minisign -G
+	encrypted := append([]byte("EdScB2"), make([]byte, 152)...)
+	_, err = minisign.ReadPrivateKey(encode(encrypted))
+	assert.Equal(t, minisign.ErrEncryptedPrivateKey, err)
+
+	// An unencrypted secret key with a bad checksum, created by: minisign -G -W
+	unencrypted := append([]byte("Ed\x00\x00B2"), make([]byte, 152)...)
+	pk, err := minisign.ReadPrivateKey(encode(unencrypted))
+	assert.Nil(t, err)
+	_, err = minisign.Sign(pk, []byte("msg"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package pgp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// armorStart starts every armored block
+var armorStart = []byte("-----BEGIN PGP ")
+
+// nolint
+var (
+	ErrNoPublicKeys = errors.New("no public keys found")
+	ErrKeyNotFound  = errors.New("public key not found")
+)
+
+// binary returns true if the data starts with a packet tag,
+// which always has the most significant bit set, see:
+// https://tools.ietf.org/html/rfc4880#section-4.2
+func binary(data []byte) bool {
+	return len(data) > 0 && data[0]&0x80 != 0
+}
+
+// armoredBlocks splits the data into its armored blocks,
+// anything before the first block is ignored
+func armoredBlocks(data []byte) [][]byte {
+	var blocks [][]byte
+	start := bytes.Index(data, armorStart)
+	for start != -1 {
+		data = data[start:]
+		next := bytes.Index(data[len(armorStart):], armorStart)
+		if next == -1 {
+			blocks = append(blocks, data)
+			break
+		}
+		start = next + len(armorStart)
+		blocks = append(blocks, data[:start])
+	}
+	return blocks
+}
+
+// ReadKeyRing reads the public keys into a keyring, each of them may be
+// armored or binary, and may hold several keys, e.g., a keyring file or
+// a number of concatenated armored blocks
+func ReadKeyRing(keys ...[]byte) (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for _, key := range keys {
+		if binary(key) {
+			entities, err := openpgp.ReadKeyRing(bytes.NewReader(key))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read keyring")
+			}
+			keyring = append(keyring, entities...)
+			continue
+		}
+
+		for _, block := range armoredBlocks(key) {
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(block))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read armored keyring")
+			}
+			keyring = append(keyring, entities...)
+		}
+	}
+
+	if len(keyring) == 0 {
+		return nil, ErrNoPublicKeys
+	}
+	return keyring, nil
+}
+
+// IsPublicKey returns true if the data holds an armored or starts with a
+// binary public key, rather than, e.g., a gpg keybox or trust database
+func IsPublicKey(data []byte) bool {
+	if !binary(data) {
+		return bytes.Contains(data, []byte("-----BEGIN "+openpgp.PublicKeyType+"-----"))
+	}
+	p, err := packet.Read(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	_, ok := p.(*packet.PublicKey)
+	return ok
+}
+
+// normaliseID returns the hex key ID or fingerprint in lower
+// case without the spaces gpg uses when printing fingerprints
+func normaliseID(id string) string {
+	return strings.ToLower(strings.Replace(id, " ", "", -1))
+}
+
+// matches returns true if the id is the 16 hex digit key ID
+// or the 40 hex digit fingerprint of the key
+func matches(key *packet.PublicKey, id string) bool {
+	return id == fmt.Sprintf("%016x", key.KeyId) || id == fmt.Sprintf("%x", key.Fingerprint)
+}
+
+// FindKey returns the armored public key in the keyring with the key ID
+// or fingerprint, either of its primary key or one of its subkeys
+func FindKey(keyring openpgp.EntityList, id string) ([]byte, error) {
+	id = normaliseID(id)
+	for _, entity := range keyring {
+		if matches(entity.PrimaryKey, id) {
+			return encodePublicKey(entity)
+		}
+		for _, subkey := range entity.Subkeys {
+			if matches(subkey.PublicKey, id) {
+				return encodePublicKey(entity)
+			}
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Fingerprint returns the hex fingerprint of the primary
+// key, the public key must contain exactly one key
+func Fingerprint(publicKey []byte) (string, error) {
+	entity, err := readPublicEntity(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", entity.PrimaryKey.Fingerprint), nil
+}
+
+// Emails returns the email addresses of the identities
+// of the public key, which must contain exactly one key
+func Emails(publicKey []byte) ([]string, error) {
+	entity, err := readPublicEntity(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && identity.UserId.Email != "" {
+			emails = append(emails, identity.UserId.Email)
+		}
+	}
+	sort.Strings(emails)
+	return emails, nil
+}
+
+// SameFingerprint returns true if the fingerprints are equal,
+// ignoring case and spaces
+func SameFingerprint(a, b string) bool {
+	return normaliseID(a) == normaliseID(b)
+}
+
+// readSignature returns the binary form of an armored
+// or binary detached signature and its first packet
+func readSignature(signature []byte) ([]byte, *packet.Signature, error) {
+	body := signature
+	if !binary(signature) {
+		block, err := armor.Decode(bytes.NewReader(signature))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read armored signature")
+		}
+		if block.Type != openpgp.SignatureType {
+			return nil, nil, fmt.Errorf("unexpected armor type: %s", block.Type)
+		}
+		body, err = ioutil.ReadAll(block.Body)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read armored signature")
+		}
+	}
+
+	p, err := packet.Read(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read signature")
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected signature packet, got: %T", p)
+	}
+	return body, sig, nil
+}
@@ -0,0 +1,116 @@
+package pgp_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindKey(t *testing.T) {
+	keyring, err := pgp.ReadKeyRing(mock.AltSignerPub, mock.SignerPub)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		id        string
+		expectErr bool
+	}{
+		{
+			name: "Key ID",
+			id:   mock.SignerKeyID,
+		},
+		{
+			name: "Fingerprint",
+			id:   mock.SignerFingerPrint,
+		},
+		{
+			name: "Upper case fingerprint with spaces",
+			id:   "7051 A5DC 925D BD7B E8B7  5DB5 1B8C 02D3 4159 D26C",
+		},
+		{
+			name:      "Unknown key",
+			id:        "0000000000000000",
+			expectErr: true,
+		},
+		{
+			name:      "Partial fingerprint",
+			id:        mock.SignerFingerPrint[:20],
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		key, err := pgp.FindKey(keyring, tc.id)
+		if tc.expectErr {
+			assert.Equal(t, pgp.ErrKeyNotFound, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		fingerprint, err := pgp.Fingerprint(key)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, mock.SignerFingerPrint, fingerprint, tc.name)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint, err := pgp.Fingerprint(mock.SignerPub)
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerFingerPrint, fingerprint)
+	assert.True(t, pgp.SameFingerprint(strings.ToUpper(fingerprint), mock.SignerFingerPrint))
+
+	// Several keys can't be pinned to a single fingerprint
+	_, err = pgp.Fingerprint(append(append([]byte{}, mock.AltSignerPub...), mock.SignerPub...))
+	assert.Error(t, err)
+}
+
+func TestEmails(t *testing.T) {
+	emails, err := pgp.Emails(mock.SignerPub)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob@builder.com"}, emails)
+}
+
+func TestIsPublicKey(t *testing.T) {
+	block, err := armor.Decode(bytes.NewReader(mock.SignerPub))
+	assert.Nil(t, err)
+	binaryKey, err := ioutil.ReadAll(block.Body)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name   string
+		data   []byte
+		expect bool
+	}{
+		{
+			name:   "Armored key",
+			data:   mock.SignerPub,
+			expect: true,
+		},
+		{
+			name:   "Binary key",
+			data:   binaryKey,
+			expect: true,
+		},
+		{
+			name: "Signature",
+			data: mock.Signature,
+		},
+		{
+			name: "Keybox",
+			data: []byte("\x00\x00\x00\x20\x01\x01\x00\x02KBXf"),
+		},
+		{
+			name: "Trust database",
+			data: []byte("\x01gpg\x03\x03\x01\x05\x01\x00\x00\x00"),
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, pgp.IsPublicKey(tc.data), tc.name)
+	}
+}
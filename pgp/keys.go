@@ -0,0 +1,454 @@
+package pgp
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+)
+
+// nolint
+var (
+	ErrSubkeyNotFound  = errors.New("subkey not found")
+	ErrNoSigningSubkey = errors.New("no valid signing subkey")
+	ErrInvalidExpiry   = errors.New("key would expire before it was created")
+)
+
+func readPrivateEntity(privateKey *memguard.LockedBuffer) (*openpgp.Entity, error) {
+	entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(privateKey.Buffer())))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read entity")
+	}
+	return entity, nil
+}
+
+func readPublicEntity(publicKey []byte) (*openpgp.Entity, error) {
+	keyring, err := ReadKeyRing(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyring) != 1 {
+		return nil, fmt.Errorf("expected one public key, got: %d", len(keyring))
+	}
+	return keyring[0], nil
+}
+
+func findSubkey(entity *openpgp.Entity, keyID uint64) (*openpgp.Subkey, error) {
+	for i := range entity.Subkeys {
+		if entity.Subkeys[i].PublicKey.KeyId == keyID {
+			return &entity.Subkeys[i], nil
+		}
+	}
+	return nil, ErrSubkeyNotFound
+}
+
+// lifetime returns the key lifetime in seconds for a key
+// expiring at the provided time, zero means never
+func lifetime(key *packet.PublicKey, expires time.Time) (uint32, error) {
+	if expires.IsZero() {
+		return 0, nil
+	}
+	if !expires.After(key.CreationTime) {
+		return 0, ErrInvalidExpiry
+	}
+	return uint32(expires.Sub(key.CreationTime) / time.Second), nil
+}
+
+// AddSigningSubkey adds a signing subkey that expires after the lifetime,
+// zero meaning never, new signatures are made with the newest valid
+// signing subkey. The subkey is an Ed25519 key if the primary key is,
+// unless config.Algorithm is set
+func AddSigningSubkey(privateKey *memguard.LockedBuffer, lifetime time.Duration, config *packet.Config) (*KeyPair, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addSigningSubkey(entity, lifetime, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeKeyPair(entity, config)
+}
+
+func addSigningSubkey(entity *openpgp.Entity, lifetime time.Duration, config *packet.Config) error {
+	c := &packet.Config{}
+	if config != nil {
+		*c = *config
+	}
+	if c.Algorithm == 0 && entity.PrimaryKey.PubKeyAlgo == packet.PubKeyAlgoEdDSA {
+		var err error
+		c, err = Ed25519.config(c)
+		if err != nil {
+			return err
+		}
+	}
+	c.KeyLifetimeSecs = uint32(lifetime / time.Second)
+
+	err := entity.AddSigningSubkey(c)
+	if err != nil {
+		return errors.Wrap(err, "failed to add signing subkey")
+	}
+	return nil
+}
+
+// SetExpiry signs the key with the key ID again so it expires at the
+// provided time, a zero time means it never expires. The key ID is
+// either that of the primary key or of one of its subkeys
+func SetExpiry(privateKey *memguard.LockedBuffer, keyID uint64, expires time.Time, config *packet.Config) (*KeyPair, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyID == entity.PrimaryKey.KeyId {
+		err = setPrimaryExpiry(entity, expires, config)
+	} else {
+		err = setSubkeyExpiry(entity, keyID, expires, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeKeyPair(entity, config)
+}
+
+// The expiry of the primary key is part of the self-signatures
+// of its identities, which replace the previous ones
+func setPrimaryExpiry(entity *openpgp.Entity, expires time.Time, config *packet.Config) error {
+	secs, err := lifetime(entity.PrimaryKey, expires)
+	if err != nil {
+		return err
+	}
+
+	for _, identity := range entity.Identities {
+		sig := *identity.SelfSignature
+		sig.CreationTime = config.Now()
+		sig.KeyLifetimeSecs = &secs
+		err = sig.SignUserId(identity.UserId.Id, entity.PrimaryKey, entity.PrivateKey, config)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign identity")
+		}
+
+		signatures := []*packet.Signature{&sig}
+		for _, s := range identity.Signatures {
+			if s != identity.SelfSignature {
+				signatures = append(signatures, s)
+			}
+		}
+		identity.SelfSignature = &sig
+		identity.Signatures = signatures
+	}
+
+	return nil
+}
+
+// The expiry of a subkey is part of its binding signature,
+// the embedded back signature stays valid as it is
+func setSubkeyExpiry(entity *openpgp.Entity, keyID uint64, expires time.Time, config *packet.Config) error {
+	subkey, err := findSubkey(entity, keyID)
+	if err != nil {
+		return err
+	}
+
+	secs, err := lifetime(subkey.PublicKey, expires)
+	if err != nil {
+		return err
+	}
+
+	sig := *subkey.Sig
+	sig.CreationTime = config.Now()
+	sig.KeyLifetimeSecs = &secs
+	err = sig.SignKey(subkey.PublicKey, entity.PrivateKey, config)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign subkey")
+	}
+	subkey.Sig = &sig
+
+	return nil
+}
+
+// RevokeSubkey revokes the subkey with the key ID
+func RevokeSubkey(privateKey *memguard.LockedBuffer, keyID uint64, reason packet.ReasonForRevocation, text string, config *packet.Config) (*KeyPair, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subkey, err := findSubkey(entity, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = entity.RevokeSubkey(subkey, reason, text, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to revoke subkey")
+	}
+
+	return encodeKeyPair(entity, config)
+}
+
+// RotateSigningSubkey adds a new signing subkey and revokes the existing
+// ones as superseded, signatures made before the revocation remain valid
+func RotateSigningSubkey(privateKey *memguard.LockedBuffer, lifetime time.Duration, config *packet.Config) (*KeyPair, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := config.Now()
+	for i := range entity.Subkeys {
+		subkey := &entity.Subkeys[i]
+		if !subkey.Sig.FlagSign || subkey.Revoked(now) {
+			continue
+		}
+		err = entity.RevokeSubkey(subkey, packet.KeySuperseded, "rotated", config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to revoke subkey")
+		}
+	}
+
+	err = addSigningSubkey(entity, lifetime, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeKeyPair(entity, config)
+}
+
+// RevocationCertificate returns an armored revocation certificate for the
+// primary key, it should be created along with the key and stored safely,
+// so the key can be revoked with ApplyRevocation when it is lost
+func RevocationCertificate(privateKey *memguard.LockedBuffer, reason packet.ReasonForRevocation, text string, config *packet.Config) ([]byte, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = entity.RevokeKey(reason, text, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to revoke key")
+	}
+
+	var certificate bytes.Buffer
+	w, err := armor.Encode(&certificate, openpgp.PublicKeyType, map[string]string{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to armor revocation certificate")
+	}
+	err = entity.Revocations[len(entity.Revocations)-1].Serialize(w)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialise revocation certificate")
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to close armored revocation certificate")
+	}
+
+	return certificate.Bytes(), nil
+}
+
+// ApplyRevocation adds the revocation certificate to the armored
+// public key and returns the revoked public key for publishing
+func ApplyRevocation(publicKey, certificate []byte) ([]byte, error) {
+	entity, err := readPublicEntity(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := armor.Decode(bytes.NewReader(certificate))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read armored revocation certificate")
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read revocation certificate")
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok || sig.SigType != packet.SigTypeKeyRevocation {
+		return nil, fmt.Errorf("not a revocation certificate")
+	}
+	err = entity.PrimaryKey.VerifyRevocationSignature(sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify revocation certificate")
+	}
+	entity.Revocations = append(entity.Revocations, sig)
+
+	return encodePublicKey(entity)
+}
+
+// MergeKeys adds the subkeys, identities and signatures of the fetched
+// copy of a public key that the pinned copy lacks to the pinned copy, and
+// returns whether anything was added. Nothing is ever removed, so a copy
+// that leaves out a revocation or subkey can't undo it
+func MergeKeys(pinned, fetched []byte) ([]byte, bool, error) {
+	entity, err := readPublicEntity(pinned)
+	if err != nil {
+		return nil, false, err
+	}
+	other, err := readPublicEntity(fetched)
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.Equal(entity.PrimaryKey.Fingerprint, other.PrimaryKey.Fingerprint) {
+		return nil, false, fmt.Errorf("expected public key: %x, got: %x", entity.PrimaryKey.Fingerprint, other.PrimaryKey.Fingerprint)
+	}
+
+	var added, changed bool
+	entity.Revocations, added = addSignatures(entity.Revocations, other.Revocations)
+	changed = changed || added
+	entity.Signatures, added = addSignatures(entity.Signatures, other.Signatures)
+	changed = changed || added
+
+	for name, identity := range other.Identities {
+		pinnedIdentity, ok := entity.Identities[name]
+		if !ok {
+			entity.Identities[name] = identity
+			changed = true
+			continue
+		}
+		pinnedIdentity.Signatures, added = addSignatures(pinnedIdentity.Signatures, identity.Signatures)
+		changed = changed || added
+		pinnedIdentity.Revocations, added = addSignatures(pinnedIdentity.Revocations, identity.Revocations)
+		changed = changed || added
+		if identity.SelfSignature != nil && (pinnedIdentity.SelfSignature == nil ||
+			identity.SelfSignature.CreationTime.After(pinnedIdentity.SelfSignature.CreationTime)) {
+			pinnedIdentity.SelfSignature = identity.SelfSignature
+			changed = true
+		}
+	}
+
+	for _, subkey := range other.Subkeys {
+		pinnedSubkey, err := findSubkey(entity, subkey.PublicKey.KeyId)
+		if err != nil {
+			entity.Subkeys = append(entity.Subkeys, subkey)
+			changed = true
+			continue
+		}
+		pinnedSubkey.Revocations, added = addSignatures(pinnedSubkey.Revocations, subkey.Revocations)
+		changed = changed || added
+		// Only the newest binding is kept, e.g., one extending the expiry
+		if subkey.Sig.CreationTime.After(pinnedSubkey.Sig.CreationTime) {
+			pinnedSubkey.Sig = subkey.Sig
+			changed = true
+		}
+	}
+
+	if !changed {
+		return pinned, false, nil
+	}
+	merged, err := encodePublicKey(entity)
+	if err != nil {
+		return nil, false, err
+	}
+	return merged, true, nil
+}
+
+// addSignatures appends the signatures that aren't in the
+// list yet, and returns whether any was appended
+func addSignatures(signatures, others []*packet.Signature) ([]*packet.Signature, bool) {
+	seen := map[string]struct{}{}
+	for _, sig := range signatures {
+		var b bytes.Buffer
+		if sig.Serialize(&b) == nil {
+			seen[b.String()] = struct{}{}
+		}
+	}
+
+	added := false
+	for _, sig := range others {
+		var b bytes.Buffer
+		if sig.Serialize(&b) != nil {
+			continue
+		}
+		if _, ok := seen[b.String()]; ok {
+			continue
+		}
+		seen[b.String()] = struct{}{}
+		signatures = append(signatures, sig)
+		added = true
+	}
+	return signatures, added
+}
+
+// StripPrimaryKey removes the secret of the primary key, leaving only the
+// subkeys, so the primary key can be kept offline while the subkeys are
+// used for signing. The primary key is replaced by a GNU dummy key, which
+// gpg understands as well, see:
+// https://git.gnupg.org/cgi-bin/gitweb.cgi?p=gnupg.git;a=blob;f=doc/DETAILS
+func StripPrimaryKey(privateKey *memguard.LockedBuffer, config *packet.Config) (*memguard.LockedBuffer, error) {
+	entity, err := readPrivateEntity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := entity.SigningKey(config.Now())
+	if !ok || key.PublicKey == entity.PrimaryKey {
+		return nil, ErrNoSigningSubkey
+	}
+
+	entity.PrivateKey, err = dummyPrivateKey(entity.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePrivateKey(entity, config)
+}
+
+// dummyPrivateKey returns a secret key packet for the public key
+// that marks the secret as missing with the GNU extension of S2K
+func dummyPrivateKey(pub *packet.PublicKey) (*packet.PrivateKey, error) {
+	var buf bytes.Buffer
+	err := pub.Serialize(&buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialise public key")
+	}
+
+	// Public keys are written with a new format header, see:
+	// https://tools.ietf.org/html/rfc4880#section-4.2.2
+	header := buf.Bytes()
+	if len(header) < 2 || header[0]&0x40 == 0 {
+		return nil, fmt.Errorf("unexpected public key packet header")
+	}
+	var body []byte
+	switch {
+	case header[1] < 192:
+		body = header[2:]
+	case header[1] < 224:
+		body = header[3:]
+	case header[1] == 255:
+		body = header[6:]
+	default:
+		return nil, fmt.Errorf("unexpected public key packet length")
+	}
+
+	// S2K usage, cipher, S2K type, hash and the GNU dummy mode
+	body = append(body, 254, byte(packet.CipherAES256), 101, 2, 'G', 'N', 'U', 1)
+
+	p, err := readPacket(5, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dummy private key")
+	}
+	privateKey, ok := p.(*packet.PrivateKey)
+	if !ok || !privateKey.Dummy() {
+		return nil, fmt.Errorf("failed to create dummy private key")
+	}
+	return privateKey, nil
+}
+
+// readPacket reads a packet with the tag and body
+// from its serialised form with a new format header
+func readPacket(tag byte, body []byte) (packet.Packet, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{
+		0xc0 | tag, 255,
+		byte(len(body) >> 24), byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body)),
+	})
+	buf.Write(body)
+	return packet.Read(&buf)
+}
@@ -0,0 +1,323 @@
+package pgp_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func testConfig() (*packet.Config, *clock) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	config := *pgp.DefaultConfig
+	config.Algorithm = packet.PubKeyAlgoEdDSA
+	config.Time = c.Now
+	return &config, c
+}
+
+var msg = []byte("This is my message\n")
+
+func TestSigningSubkey(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKeyID, kp.SigningKeyID)
+
+	c.Advance(time.Hour)
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 24*time.Hour, config)
+	assert.Nil(t, err)
+	assert.NotEqual(t, kp.PublicKeyID, kp.SigningKeyID)
+
+	c.Advance(time.Hour)
+	signed, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	verification, err := pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+	assert.True(t, verification.Subkey())
+	assert.Equal(t, kp.PublicKeyID, verification.PrimaryKeyID)
+	assert.Equal(t, kp.SigningKeyID, verification.KeyID)
+	assert.Equal(t, c.Now(), verification.CreationTime)
+
+	// The subkey has expired, so new signatures are made with the
+	// primary key, and neither are its earlier signatures valid
+	c.Advance(48 * time.Hour)
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Error(t, err)
+	signed, err = pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+	verification, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+	assert.False(t, verification.Subkey())
+
+	kp, err = pgp.SetExpiry(kp.PrivateKey, kp.SigningKeyID, c.Now().Add(24*time.Hour), config)
+	assert.Nil(t, err)
+	signed, err = pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+
+	_, err = pgp.SetExpiry(kp.PrivateKey, 1, time.Time{}, config)
+	assert.Equal(t, pgp.ErrSubkeyNotFound, err)
+	_, err = pgp.SetExpiry(kp.PrivateKey, kp.PublicKeyID, c.Now().Add(-365*24*time.Hour), config)
+	assert.Equal(t, pgp.ErrInvalidExpiry, err)
+}
+
+func TestPrimaryKeyExpiry(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	kp, err = pgp.SetExpiry(kp.PrivateKey, kp.PublicKeyID, c.Now().Add(time.Hour), config)
+	assert.Nil(t, err)
+
+	signed, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	c.Advance(2 * time.Hour)
+	_, err = pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Error(t, err)
+
+	// Expiry is checked at the time of verification, as
+	// the signer chooses the creation time of a signature
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Error(t, err)
+
+	// A signature backdated to before the expiry isn't valid either
+	c.Advance(-2 * time.Hour)
+	backdated, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+	c.Advance(2 * time.Hour)
+	_, err = pgp.Verify(kp.PublicKey, msg, backdated, config)
+	assert.Error(t, err)
+
+	// Extending the expiry makes the key usable again
+	kp, err = pgp.SetExpiry(kp.PrivateKey, kp.PublicKeyID, time.Time{}, config)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+	signed, err = pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+
+	// Signatures from the future are rejected
+	c.Advance(time.Hour)
+	signed, err = pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+	c.Advance(-time.Hour)
+	_, err = pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Equal(t, pgp.ErrFutureSignature, err)
+}
+
+func TestRotateSigningSubkey(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 0, config)
+	assert.Nil(t, err)
+	old := kp.SigningKeyID
+
+	c.Advance(time.Hour)
+	signedOld, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	kp, err = pgp.RotateSigningSubkey(kp.PrivateKey, 0, config)
+	assert.Nil(t, err)
+	assert.NotEqual(t, old, kp.SigningKeyID)
+
+	c.Advance(time.Hour)
+	signedNew, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	verification, err := pgp.Verify(kp.PublicKey, msg, signedOld, config)
+	assert.Nil(t, err)
+	assert.Equal(t, old, verification.KeyID)
+
+	verification, err = pgp.Verify(kp.PublicKey, msg, signedNew, config)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.SigningKeyID, verification.KeyID)
+
+	// A compromised subkey isn't trusted, whenever it signed
+	kp, err = pgp.RevokeSubkey(kp.PrivateKey, kp.SigningKeyID, packet.KeyCompromised, "", config)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(kp.PublicKey, msg, signedNew, config)
+	assert.Error(t, err)
+
+	_, err = pgp.RevokeSubkey(kp.PrivateKey, 1, packet.KeyRetired, "", config)
+	assert.Equal(t, pgp.ErrSubkeyNotFound, err)
+}
+
+func TestRevocationCertificate(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	signed, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	retired, err := pgp.RevocationCertificate(kp.PrivateKey, packet.KeyRetired, "retired", config)
+	assert.Nil(t, err)
+	compromised, err := pgp.RevocationCertificate(kp.PrivateKey, packet.KeyCompromised, "compromised", config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	signedLate, err := pgp.Sign(kp.PrivateKey, msg, config)
+	assert.Nil(t, err)
+
+	publicKey, err := pgp.ApplyRevocation(kp.PublicKey, retired)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(publicKey, msg, signed, config)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(publicKey, msg, signedLate, config)
+	assert.Error(t, err)
+
+	publicKey, err = pgp.ApplyRevocation(kp.PublicKey, compromised)
+	assert.Nil(t, err)
+	_, err = pgp.Verify(publicKey, msg, signed, config)
+	assert.Error(t, err)
+
+	other, err := pgp.NewSigner("Alice", "", "alice@example.com", config)
+	assert.Nil(t, err)
+	_, err = pgp.ApplyRevocation(other.PublicKey, compromised)
+	assert.Error(t, err)
+	_, err = pgp.ApplyRevocation(kp.PublicKey, signed)
+	assert.Error(t, err)
+}
+
+func TestStripPrimaryKey(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+
+	_, err = pgp.StripPrimaryKey(kp.PrivateKey, config)
+	assert.Equal(t, pgp.ErrNoSigningSubkey, err)
+
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 0, config)
+	assert.Nil(t, err)
+	stripped, err := pgp.StripPrimaryKey(kp.PrivateKey, config)
+	assert.Nil(t, err)
+
+	c.Advance(time.Hour)
+	signed, err := pgp.Sign(stripped, msg, config)
+	assert.Nil(t, err)
+	verification, err := pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+	assert.True(t, verification.Subkey())
+
+	// Changing the key requires the primary key
+	_, err = pgp.AddSigningSubkey(stripped, 0, config)
+	assert.Error(t, err)
+}
+
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestSignWithECDSASigner(t *testing.T) {
+	config, c := testConfig()
+	config.Algorithm = packet.PubKeyAlgoECDSA
+	config.Curve = packet.CurveNistP256
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+	entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(kp.PrivateKey.Buffer())))
+	assert.Nil(t, err)
+
+	// Any crypto.Signer will do, e.g., a key kept in an HSM
+	priv := entity.PrivateKey.PrivateKey.(*pgpecdsa.PrivateKey)
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: priv.X, Y: priv.Y},
+		D:         priv.D,
+	}
+
+	c.Advance(time.Hour)
+	signed, err := pgp.SignWithSigner(&opaqueSigner{signer: key}, entity.PrimaryKey.CreationTime, msg, config)
+	assert.Nil(t, err)
+	verification, err := pgp.Verify(kp.PublicKey, msg, signed, config)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKeyID, verification.KeyID)
+}
+
+func TestMergeKeys(t *testing.T) {
+	config, c := testConfig()
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", config)
+	assert.Nil(t, err)
+
+	_, changed, err := pgp.MergeKeys(kp.PublicKey, kp.PublicKey)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	// Revoke the identity on its own, the rest of the key is unchanged
+	c.Advance(time.Hour)
+	keyring, err := pgp.ReadKeyRing(kp.PrivateKey.Buffer())
+	assert.Nil(t, err)
+	entity := keyring[0]
+	identity := entity.PrimaryIdentity()
+	revocation := &packet.Signature{
+		Version:      entity.PrimaryKey.Version,
+		SigType:      packet.SigTypeCertificationRevocation,
+		PubKeyAlgo:   entity.PrimaryKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: c.Now(),
+		IssuerKeyId:  &entity.PrimaryKey.KeyId,
+	}
+	err = revocation.SignUserId(identity.Name, entity.PrimaryKey, entity.PrivateKey, config)
+	assert.Nil(t, err)
+	// Signatures holds every signature of the identity and is what
+	// gets serialised, Revocations only the revocations
+	identity.Signatures = append(identity.Signatures, revocation)
+	identity.Revocations = append(identity.Revocations, revocation)
+	var revoked bytes.Buffer
+	assert.Nil(t, entity.Serialize(&revoked))
+
+	merged, changed, err := pgp.MergeKeys(kp.PublicKey, revoked.Bytes())
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	keyring, err = pgp.ReadKeyRing(merged)
+	assert.Nil(t, err)
+	assert.True(t, keyring[0].PrimaryIdentity().Revoked(c.Now()))
+
+	_, changed, err = pgp.MergeKeys(merged, kp.PublicKey)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+}
@@ -3,13 +3,27 @@ package pgp
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"sort"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/awnumar/memguard"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/openpgp"
-	"golang.org/x/crypto/openpgp/armor"
-	"golang.org/x/crypto/openpgp/packet"
+
+	// Encrypting for keys without hash preferences requires
+	// RIPEMD-160 to be available, even when not signing
+	_ "golang.org/x/crypto/ripemd160" // nolint: gosec
 )
 
 // DefaultBits is the default size used when
@@ -27,6 +41,38 @@ var DefaultTime = func() time.Time {
 	return time.Now()
 }
 
+// Algorithm is the public key algorithm of a new key
+type Algorithm string
+
+// nolint
+const (
+	RSA     Algorithm = "rsa"
+	Ed25519 Algorithm = "ed25519"
+)
+
+// config returns a copy of the config for creating keys with the algorithm
+func (a Algorithm) config(config *packet.Config) (*packet.Config, error) {
+	c := packet.Config{}
+	if config != nil {
+		c = *config
+	}
+
+	switch a {
+	case RSA:
+		c.Algorithm = packet.PubKeyAlgoRSA
+		if c.RSABits == 0 {
+			c.RSABits = DefaultBits
+		}
+	case Ed25519:
+		c.Algorithm = packet.PubKeyAlgoEdDSA
+		c.Curve = packet.Curve25519
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm: %s", a)
+	}
+
+	return &c, nil
+}
+
 // DefaultConfig is the default config used
 // creating a new key
 var DefaultConfig = &packet.Config{
@@ -43,13 +89,41 @@ type KeyPair struct {
 	PublicKeyFingerPrint [20]byte
 	PublicKeyID          uint64
 	PrivateKey           *memguard.LockedBuffer
+
+	// SigningKeyID is the key ID of the key new signatures are
+	// made with, the newest valid signing subkey if there is one
+	// and the primary key otherwise
+	SigningKeyID uint64
 }
 
+// Verification contains the details of a verified signature,
+// fingerprints are lower case hex
+type Verification struct {
+	Identities   []string
+	Fingerprint  string
+	PrimaryKeyID uint64
+
+	// KeyFingerprint and KeyID are those of the key that made the
+	// signature, they differ from the primary key's when a subkey signed
+	KeyFingerprint string
+	KeyID          uint64
+
+	CreationTime time.Time
+	Hash         crypto.Hash
+}
+
+// Subkey returns true if the signature was made by a subkey
+func (v *Verification) Subkey() bool {
+	return v.KeyID != v.PrimaryKeyID
+}
+
+// The signatures have been made when the entity was created or changed,
+// so they are serialised as they are instead of being signed again
 func encodePrivateKey(e *openpgp.Entity, cfg *packet.Config) (*memguard.LockedBuffer, error) {
 	var privKey bytes.Buffer
 	var privKeyGuarded *memguard.LockedBuffer
 
-	err := e.SerializePrivate(&privKey, cfg)
+	err := e.SerializePrivateWithoutSigning(&privKey, cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to serialise private key")
 	}
@@ -88,32 +162,59 @@ func encodePublicKey(e *openpgp.Entity) ([]byte, error) {
 	return pubKeyArmored.Bytes(), nil
 }
 
-// NewSigner creates a new pgp keypair capable of signing artifacts
-// using the provided input parameters
-func NewSigner(name, comment, email string, config *packet.Config) (*KeyPair, error) {
-	entity, err := openpgp.NewEntity(name, comment, email, config)
+func encodeKeyPair(e *openpgp.Entity, config *packet.Config) (*KeyPair, error) {
+	privKeyGuarded, err := encodePrivateKey(e, config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create new entity")
+		return nil, err
 	}
 
-	privKeyGuarded, err := encodePrivateKey(entity, DefaultConfig)
+	pubKeyArmored, err := encodePublicKey(e)
 	if err != nil {
+		privKeyGuarded.Destroy()
 		return nil, err
 	}
 
-	pubKeyArmored, err := encodePublicKey(entity)
-	if err != nil {
-		return nil, err
+	var fingerprint [20]byte
+	copy(fingerprint[:], e.PrimaryKey.Fingerprint)
+
+	signingKeyID := e.PrimaryKey.KeyId
+	if key, ok := e.SigningKey(config.Now()); ok {
+		signingKeyID = key.PublicKey.KeyId
 	}
 
 	return &KeyPair{
 		PublicKey:            pubKeyArmored,
-		PublicKeyFingerPrint: entity.PrimaryKey.Fingerprint,
-		PublicKeyID:          entity.PrimaryKey.KeyId,
+		PublicKeyFingerPrint: fingerprint,
+		PublicKeyID:          e.PrimaryKey.KeyId,
 		PrivateKey:           privKeyGuarded,
+		SigningKeyID:         signingKeyID,
 	}, nil
 }
 
+// NewSigner creates a new pgp keypair capable of signing artifacts
+// using the provided input parameters, the key expires after
+// config.KeyLifetimeSecs unless it is zero
+func NewSigner(name, comment, email string, config *packet.Config) (*KeyPair, error) {
+	entity, err := openpgp.NewEntity(name, comment, email, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new entity")
+	}
+
+	return encodeKeyPair(entity, config)
+}
+
+// NewSignerWithAlgorithm creates a new pgp keypair like NewSigner using the
+// provided algorithm, Ed25519 keys get a Curve25519 encryption subkey and
+// are much faster to create than RSA keys
+func NewSignerWithAlgorithm(name, comment, email string, algorithm Algorithm, config *packet.Config) (*KeyPair, error) {
+	c, err := algorithm.config(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigner(name, comment, email, c)
+}
+
 // Sign loads a signing entity from the provided signer and uses it
 // to create an armored detached signature
 func Sign(signer *memguard.LockedBuffer, sign []byte, config *packet.Config) ([]byte, error) {
@@ -131,25 +232,317 @@ func Sign(signer *memguard.LockedBuffer, sign []byte, config *packet.Config) ([]
 	return signed.Bytes(), nil
 }
 
-// Verify loads a signers public key and verify the signed object using
-// the provided signature
-// FIXME: We probably want to structure this part to be more flexible, e.g.,
-// by making it possible to read unarmored key rings and signatures, etc.
-func Verify(signer []byte, signed []byte, signature []byte, config *packet.Config) ([]string, error) {
-	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(signer))
+// SignWithSigner creates an armored detached signature using a signer that
+// keeps its private key to itself, e.g., a key stored in an HSM. The creation
+// time must match that of the published public key, since it is part of the
+// key's fingerprint.
+func SignWithSigner(signer crypto.Signer, creationTime time.Time, sign []byte, config *packet.Config) ([]byte, error) {
+	privateKey := &packet.PrivateKey{PrivateKey: signer}
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		privateKey.PublicKey = *packet.NewRSAPublicKey(creationTime, pub)
+	case *ecdsa.PublicKey:
+		publicKey, err := ecdsaPublicKey(creationTime, pub)
+		if err != nil {
+			return nil, err
+		}
+		privateKey.PublicKey = *publicKey
+	default:
+		return nil, fmt.Errorf("unsupported public key type: %T", signer.Public())
+	}
+
+	sig := &packet.Signature{
+		Version:           privateKey.PublicKey.Version,
+		SigType:           packet.SigTypeBinary,
+		PubKeyAlgo:        privateKey.PublicKey.PubKeyAlgo,
+		Hash:              config.Hash(),
+		CreationTime:      config.Now(),
+		IssuerKeyId:       &privateKey.PublicKey.KeyId,
+		IssuerFingerprint: privateKey.PublicKey.Fingerprint,
+	}
+
+	var err error
+	if sig.PubKeyAlgo == packet.PubKeyAlgoECDSA {
+		err = signECDSA(sig, privateKey, signer, sign, config)
+	} else {
+		h := sig.Hash.New()
+		h.Write(sign) // nolint: errcheck, gosec
+		err = sig.Sign(h, privateKey, config)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+
+	var signed bytes.Buffer
+	w, err := armor.Encode(&signed, openpgp.SignatureType, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to armor signature")
+	}
+	err = sig.Serialize(w)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialise signature")
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to close armored signature")
+	}
+
+	return signed.Bytes(), nil
+}
+
+// ecdsaOIDs are the curve OIDs, see:
+// https://tools.ietf.org/html/rfc6637#section-11
+var ecdsaOIDs = map[string][]byte{
+	"P-256": {0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07},
+	"P-384": {0x2b, 0x81, 0x04, 0x00, 0x22},
+	"P-521": {0x2b, 0x81, 0x04, 0x00, 0x23},
+}
+
+var ecdsaCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// SignerPublicKey returns the key of the packet as the public key of
+// a crypto.Signer, only RSA and ECDSA keys are supported as those are
+// the keys SignWithSigner signs with
+func SignerPublicKey(publicKey *packet.PublicKey) (crypto.PublicKey, error) {
+	switch pub := publicKey.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return pub, nil
+	case *pgpecdsa.PublicKey:
+		curve, ok := ecdsaCurves[pub.GetCurve().GetCurveName()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve: %s", pub.GetCurve().GetCurveName())
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: pub.X, Y: pub.Y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type: %T", publicKey.PublicKey)
+	}
+}
+
+// ecdsaPublicKey returns the public key packet of an ECDSA key, openpgp
+// keeps ECDSA keys in its own types so the packet is read from its
+// serialised form, see: https://tools.ietf.org/html/rfc6637#section-9
+func ecdsaPublicKey(creationTime time.Time, pub *ecdsa.PublicKey) (*packet.PublicKey, error) {
+	oid, ok := ecdsaOIDs[pub.Curve.Params().Name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve: %s", pub.Curve.Params().Name)
+	}
+
+	created := uint32(creationTime.Unix())
+	body := []byte{
+		4,
+		byte(created >> 24), byte(created >> 16), byte(created >> 8), byte(created),
+		byte(packet.PubKeyAlgoECDSA),
+		byte(len(oid)),
+	}
+	body = append(body, oid...)
+	body = append(body, mpi(elliptic.Marshal(pub.Curve, pub.X, pub.Y)).EncodedBytes()...) // nolint: staticcheck
+
+	p, err := readPacket(6, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key")
+	}
+	publicKey, ok := p.(*packet.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to create public key")
+	}
+	return publicKey, nil
+}
+
+// signECDSA signs with an ECDSA signer, since openpgp only signs with
+// its own ECDSA keys the signature is made with a throwaway key on the
+// same curve to build the hashed part, whose digest is then signed
+// again by the signer
+func signECDSA(sig *packet.Signature, privateKey *packet.PrivateKey, signer crypto.Signer, sign []byte, config *packet.Config) error {
+	curve := privateKey.PublicKey.PublicKey.(*pgpecdsa.PublicKey).GetCurve()
+	throwaway, err := pgpecdsa.GenerateKey(config.Random(), curve)
+	if err != nil {
+		return err
+	}
+
+	h := &digestHash{Hash: sig.Hash.New()}
+	h.Write(sign) // nolint: errcheck, gosec
+	err = sig.Sign(h, &packet.PrivateKey{PublicKey: privateKey.PublicKey, PrivateKey: throwaway}, config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read armored keyring")
+		return err
 	}
 
-	entity, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(signature))
+	der, err := signer.Sign(config.Random(), h.digest, sig.Hash)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to check armored detached signature")
+		return err
 	}
+	var values struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &values)
+	if err != nil || len(rest) != 0 {
+		return fmt.Errorf("invalid ECDSA signature")
+	}
+	if !ecdsa.Verify(signer.Public().(*ecdsa.PublicKey), h.digest, values.R, values.S) {
+		return fmt.Errorf("invalid ECDSA signature")
+	}
+
+	sig.ECDSASigR = mpi(values.R.Bytes())
+	sig.ECDSASigS = mpi(values.S.Bytes())
+	return nil
+}
+
+// digestHash keeps the digest it returned last
+type digestHash struct {
+	hash.Hash
+	digest []byte
+}
+
+func (h *digestHash) Sum(b []byte) []byte {
+	h.digest = h.Hash.Sum(nil)
+	return append(b, h.digest...)
+}
 
-	var identities []string
+// mpi is an OpenPGP multiprecision integer, for
+// setting values computed outside of openpgp
+type mpi []byte
+
+func (m mpi) Bytes() []byte {
+	return m
+}
+
+func (m mpi) BitLength() uint16 {
+	return uint16(new(big.Int).SetBytes(m).BitLen())
+}
+
+func (m mpi) EncodedBytes() []byte {
+	bitLength := m.BitLength()
+	return append([]byte{byte(bitLength >> 8), byte(bitLength)}, m...)
+}
+
+func (m mpi) EncodedLength() uint16 {
+	return uint16(2 + len(m))
+}
+
+func (m mpi) ReadFrom(r io.Reader) (int64, error) {
+	return 0, fmt.Errorf("reading is not supported")
+}
+
+// Encrypt returns a writer that encrypts everything written to it for the
+// public keys, see ReadKeyRing, the writer must be closed to finish the message
+func Encrypt(w io.Writer, recipients [][]byte, config *packet.Config) (io.WriteCloser, error) {
+	to, err := ReadKeyRing(recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := openpgp.Encrypt(w, to, nil, nil, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt message")
+	}
+	return encrypted, nil
+}
+
+// Decrypt loads a private key and returns a reader of the decrypted message,
+// the integrity of the message is checked when the end is reached
+func Decrypt(recipient *memguard.LockedBuffer, r io.Reader, config *packet.Config) (io.Reader, error) {
+	entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(recipient.Buffer())))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read entity")
+	}
+
+	md, err := openpgp.ReadMessage(r, openpgp.EntityList{entity}, nil, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt message")
+	}
+	return md.UnverifiedBody, nil
+}
+
+// ErrFutureSignature indicates that a signature claims to
+// have been made after the time of verification
+var ErrFutureSignature = errors.New("signature created in the future")
+
+// Verify checks the detached signature of the signed object using the
+// public keys of the signer, which are read with ReadKeyRing, the signature
+// may be armored or binary. Expiry and revocations are checked as of the
+// time of the config, since the creation time is chosen by the signer.
+// Only a key superseded or retired after the signature was made is still
+// trusted for it, so rotating keys doesn't invalidate earlier signatures
+func Verify(signer []byte, signed []byte, signature []byte, config *packet.Config) (*Verification, error) {
+	keyring, err := ReadKeyRing(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return VerifyKeyRing(keyring, signed, signature, config)
+}
+
+// VerifyKeyRing checks the detached signature like Verify using any
+// of the keys in the keyring
+func VerifyKeyRing(keyring openpgp.EntityList, signed []byte, signature []byte, config *packet.Config) (*Verification, error) {
+	body, sig, err := readSignature(signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check detached signature")
+	}
+
+	if sig.CreationTime.After(config.Now()) {
+		return nil, ErrFutureSignature
+	}
+
+	keyring = withoutLaterRetirement(keyring, sig.CreationTime)
+	sig, entity, err := openpgp.VerifyDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(body), config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check detached signature")
+	}
+
+	key := entity.PrimaryKey
+	for _, subkey := range entity.Subkeys {
+		if subkey.PublicKey.KeyId == *sig.IssuerKeyId {
+			key = subkey.PublicKey
+		}
+	}
+
+	verification := &Verification{
+		Fingerprint:    fmt.Sprintf("%x", entity.PrimaryKey.Fingerprint),
+		PrimaryKeyID:   entity.PrimaryKey.KeyId,
+		KeyFingerprint: fmt.Sprintf("%x", key.Fingerprint),
+		KeyID:          key.KeyId,
+		CreationTime:   sig.CreationTime,
+		Hash:           sig.Hash,
+	}
 	for _, identity := range entity.Identities {
-		identities = append(identities, identity.Name)
+		verification.Identities = append(verification.Identities, identity.Name)
 	}
+	sort.Strings(verification.Identities)
+
+	return verification, nil
+}
 
-	return identities, nil
+// withoutLaterRetirement returns a copy of the keyring without the
+// revocations of keys that were superseded or retired after the time
+func withoutLaterRetirement(keyring openpgp.EntityList, t time.Time) openpgp.EntityList {
+	var filtered openpgp.EntityList
+	for _, entity := range keyring {
+		e := *entity
+		e.Revocations = revokedBefore(entity.Revocations, t)
+		e.Subkeys = make([]openpgp.Subkey, len(entity.Subkeys))
+		for i, subkey := range entity.Subkeys {
+			subkey.Revocations = revokedBefore(subkey.Revocations, t)
+			e.Subkeys[i] = subkey
+		}
+		filtered = append(filtered, &e)
+	}
+	return filtered
+}
+
+func revokedBefore(revocations []*packet.Signature, t time.Time) []*packet.Signature {
+	var kept []*packet.Signature
+	for _, revocation := range revocations {
+		if revocation.RevocationReason != nil && revocation.CreationTime.After(t) {
+			switch *revocation.RevocationReason {
+			case packet.KeySuperseded, packet.KeyRetired:
+				continue
+			}
+		}
+		kept = append(kept, revocation)
+	}
+	return kept
 }
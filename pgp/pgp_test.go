@@ -1,8 +1,13 @@
 package pgp_test
 
 import (
+	"bytes"
+	"crypto"
+	"fmt"
 	"testing"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/stoic-cli/stoic-release/mock"
 	"github.com/stoic-cli/stoic-release/pgp"
 	"github.com/stretchr/testify/assert"
@@ -19,10 +24,38 @@ func TestHappyPath(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Verify
-	identities, err := pgp.Verify(kp.PublicKey, msg, signed, pgp.DefaultConfig)
+	verification, err := pgp.Verify(kp.PublicKey, msg, signed, pgp.DefaultConfig)
 	assert.Nil(t, err)
-	assert.Len(t, identities, 1)
-	assert.Equal(t, identities[0], "Bob the Builder (I build stuff) <bob@builder.com>")
+	assert.Len(t, verification.Identities, 1)
+	assert.Equal(t, verification.Identities[0], "Bob the Builder (I build stuff) <bob@builder.com>")
+}
+
+func TestNewSignerWithAlgorithm(t *testing.T) {
+	kp, err := pgp.NewSignerWithAlgorithm("Bob the Builder", "I build stuff", "bob@builder.com", pgp.Ed25519, pgp.DefaultConfig)
+	assert.Nil(t, err)
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(kp.PublicKey))
+	assert.Nil(t, err)
+	assert.Equal(t, packet.PubKeyAlgoEdDSA, keyring[0].PrimaryKey.PubKeyAlgo)
+
+	msg := []byte("This is my message\n")
+	signed, err := pgp.Sign(kp.PrivateKey, msg, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	verification, err := pgp.Verify(kp.PublicKey, msg, signed, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, verification.Identities)
+
+	// Subkeys follow the algorithm of the primary key
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 0, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(kp.PublicKey))
+	assert.Nil(t, err)
+	for _, subkey := range keyring[0].Subkeys {
+		assert.NotEqual(t, packet.PubKeyAlgoRSA, subkey.PublicKey.PubKeyAlgo)
+	}
+
+	_, err = pgp.NewSignerWithAlgorithm("Bob the Builder", "I build stuff", "bob@builder.com", pgp.Algorithm("dsa"), pgp.DefaultConfig)
+	assert.Error(t, err)
 }
 
 func TestVerifySignature(t *testing.T) {
@@ -33,6 +66,100 @@ func TestVerifySignature(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestVerifyFormats(t *testing.T) {
+	binaryPub, err := mock.ArmoredToByte(mock.SignerPub)
+	assert.Nil(t, err)
+	binarySignature, err := mock.ArmoredToByte(mock.Signature)
+	assert.Nil(t, err)
+	binaryAltPub, err := mock.ArmoredToByte(mock.AltSignerPub)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		signer    []byte
+		signature []byte
+		expectErr bool
+	}{
+		{
+			name:      "Armored key and signature",
+			signer:    mock.SignerPub,
+			signature: mock.Signature,
+		},
+		{
+			name:      "Binary key and signature",
+			signer:    binaryPub,
+			signature: binarySignature,
+		},
+		{
+			name:      "Binary key and armored signature",
+			signer:    binaryPub,
+			signature: mock.Signature,
+		},
+		{
+			name:      "Concatenated armored keys",
+			signer:    append(append([]byte{}, mock.AltSignerPub...), mock.SignerPub...),
+			signature: binarySignature,
+		},
+		{
+			name:      "Binary keyring",
+			signer:    append(append([]byte{}, binaryAltPub...), binaryPub...),
+			signature: mock.Signature,
+		},
+		{
+			name:      "Wrong key",
+			signer:    binaryAltPub,
+			signature: binarySignature,
+			expectErr: true,
+		},
+		{
+			name:      "No keys",
+			signer:    []byte("not a key"),
+			signature: mock.Signature,
+			expectErr: true,
+		},
+		{
+			name:      "Not a signature",
+			signer:    mock.SignerPub,
+			signature: mock.SignerPub,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		verification, err := pgp.Verify(tc.signer, mock.Signed, tc.signature, pgp.DefaultConfig)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, mock.SignerFingerPrint, verification.Fingerprint, tc.name)
+		assert.Equal(t, mock.SignerKeyID, fmt.Sprintf("%x", verification.PrimaryKeyID), tc.name)
+		assert.Equal(t, crypto.SHA256, verification.Hash, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, verification.Identities, tc.name)
+	}
+}
+
+func TestVerifyKeyRing(t *testing.T) {
+	binaryPub, err := mock.ArmoredToByte(mock.SignerPub)
+	assert.Nil(t, err)
+
+	keyring, err := pgp.ReadKeyRing(mock.AltSignerPub, binaryPub)
+	assert.Nil(t, err)
+	assert.Len(t, keyring, 2)
+
+	verification, err := pgp.VerifyKeyRing(keyring, mock.Signed, mock.Signature, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, mock.SignerFingerPrint, verification.Fingerprint)
+	assert.False(t, verification.Subkey())
+
+	verification, err = pgp.VerifyKeyRing(keyring, mock.Signed, mock.AltSignature, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	assert.NotEqual(t, mock.SignerFingerPrint, verification.Fingerprint)
+
+	_, err = pgp.ReadKeyRing()
+	assert.Equal(t, pgp.ErrNoPublicKeys, err)
+}
+
 //func TestMeh(t *testing.T) {
 //	pk, _ := mock.ArmoredToByte(mock.SignerPriv)
 //	entity, _ := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(pk)))
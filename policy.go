@@ -0,0 +1,187 @@
+package release
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// TrustPolicy declares which signees are trusted to sign
+// the releases of each project, by the project name
+type TrustPolicy struct {
+	Projects map[string]ProjectPolicy `yaml:"projects"`
+}
+
+// ProjectPolicy contains the signees that are allowed to sign the
+// releases of a project, how many of them must have signed, one if
+// left out, and the signature schemes they may use, pgp if left out
+type ProjectPolicy struct {
+	Signees   []PolicySignee    `yaml:"signees"`
+	Threshold int               `yaml:"threshold,omitempty"`
+	Schemes   []SignatureScheme `yaml:"schemes,omitempty"`
+}
+
+// PolicySignee is a signee that is allowed to sign, its public
+// key must have the pgp fingerprint, if there is one
+type PolicySignee struct {
+	User        string     `yaml:"user"`
+	Key         string     `yaml:"key"`
+	Type        SigneeType `yaml:"type"`
+	Fingerprint string     `yaml:"fingerprint,omitempty"`
+}
+
+var (
+	// ErrProjectNotTrusted indicates that the trust
+	// policy has no signees for the project
+	ErrProjectNotTrusted = errors.New("project not in trust policy")
+
+	// ErrSigneeNotAuthorised indicates that a signee in the manifest
+	// isn't allowed to sign the releases of the project
+	ErrSigneeNotAuthorised = errors.New("signee not authorised")
+
+	// ErrInvalidPolicy indicates that the trust policy is malformed
+	ErrInvalidPolicy = errors.New("invalid trust policy")
+)
+
+// LoadTrustPolicy reads a trust policy in yaml, or json, as
+// that is yaml too. Unknown fields are rejected, so a typo
+// can't quietly loosen the policy
+func LoadTrustPolicy(reader io.Reader) (*TrustPolicy, error) {
+	decoder := yaml.NewDecoder(reader)
+	decoder.SetStrict(true)
+
+	policy := &TrustPolicy{}
+	err := decoder.Decode(policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read trust policy")
+	}
+
+	for name, project := range policy.Projects {
+		if len(project.Signees) == 0 {
+			return nil, errors.Wrapf(ErrInvalidPolicy, "project: %s has no signees", name)
+		}
+		if project.Threshold < 0 || project.Threshold > len(project.Signees) {
+			return nil, errors.Wrapf(ErrInvalidPolicy, "project: %s has threshold: %d, signees: %d", name, project.Threshold, len(project.Signees))
+		}
+		for _, s := range project.Signees {
+			if s.User == "" || s.Type == "" {
+				return nil, errors.Wrapf(ErrInvalidPolicy, "project: %s has a signee without user or type", name)
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// threshold returns the number of signees that must have signed
+func (p ProjectPolicy) threshold() int {
+	if p.Threshold == 0 {
+		return 1
+	}
+	return p.Threshold
+}
+
+// allows returns true if the policy allows the manifest signee
+func (p ProjectPolicy) allows(s ManifestSignee) bool {
+	scheme := s.Scheme
+	if len(scheme) == 0 {
+		scheme = SignatureSchemePGP
+	}
+	schemes := p.Schemes
+	if len(schemes) == 0 {
+		schemes = []SignatureScheme{SignatureSchemePGP}
+	}
+
+	allowed := false
+	for _, sc := range schemes {
+		if sc == scheme {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+
+	_, ok := p.find(s)
+	return ok
+}
+
+func (p ProjectPolicy) find(s ManifestSignee) (PolicySignee, bool) {
+	for _, ps := range p.Signees {
+		if ps.User == s.User && ps.Key == s.Key && ps.Type == s.Type {
+			return ps, true
+		}
+	}
+	return PolicySignee{}, false
+}
+
+// Signee returns the signee, pinned to its fingerprint if it has one
+func (s PolicySignee) Signee() Signee {
+	signee := NewSignee(s.User, s.Key, s.Type)
+	if s.Fingerprint != "" {
+		return NewPinnedSignee(signee, s.Fingerprint)
+	}
+	return signee
+}
+
+// PolicyVerifier provides the interface required for verifying
+// that a release has been signed as its trust policy requires
+type PolicyVerifier interface {
+	// VerifyRelease asserts that the trust policy has the project of
+	// the manifest, that all signees listed in the manifest are allowed
+	// to sign it, and that enough of them produced a valid signature
+	VerifyRelease(manifest Manifester, signatures [][]byte) (*ThresholdResult, error)
+}
+
+type policyVerifier struct {
+	policy   *TrustPolicy
+	verifier ManifestVerifier
+}
+
+// NewPolicyVerifier creates a verifier that only trusts the signees
+// of the policy, instead of those passed in by the caller
+func NewPolicyVerifier(policy *TrustPolicy, verifier ManifestVerifier) PolicyVerifier {
+	return &policyVerifier{
+		policy:   policy,
+		verifier: verifier,
+	}
+}
+
+// VerifyRelease using the provided input
+func (pv *policyVerifier) VerifyRelease(manifest Manifester, signatures [][]byte) (*ThresholdResult, error) {
+	project, ok := pv.policy.Projects[manifest.Name()]
+	if !ok {
+		return nil, errors.Wrap(ErrProjectNotTrusted, manifest.Name())
+	}
+
+	var signees []Signee
+	seen := map[string]struct{}{}
+	for _, s := range manifest.Signees() {
+		id := fmt.Sprintf("%s/%s/%s", s.Type, s.User, s.Key)
+		if _, ok := seen[id]; ok {
+			return nil, errors.Wrapf(ErrDuplicateSignee, "%s signee: %s, key: %s", s.Type, s.User, s.Key)
+		}
+		seen[id] = struct{}{}
+		if !project.allows(s) {
+			return nil, errors.Wrapf(ErrSigneeNotAuthorised, "%s signee: %s, key: %s", s.Type, s.User, s.Key)
+		}
+		ps, _ := project.find(s)
+		signees = append(signees, ps.Signee())
+	}
+
+	result := &ThresholdResult{
+		Threshold: project.threshold(),
+		Valid: countSignatures(signees, signatures, func(signee Signee, signature []byte) ([]string, error) {
+			return pv.verifier.VerifyManifestSignature(signee, manifest, signature)
+		}),
+	}
+
+	if len(result.Valid) < result.Threshold {
+		return result, errors.Wrapf(ErrThresholdNotMet, "got: %d, need: %d", len(result.Valid), result.Threshold)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,249 @@
+package release_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTrustPolicy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		policy    string
+		expectErr bool
+	}{
+		{
+			name: "YAML",
+			policy: `
+projects:
+  MyProject:
+    threshold: 1
+    schemes: [pgp, minisign]
+    signees:
+      - user: bob
+        key: 1b8c02d34159d26c
+        type: github
+        fingerprint: 7051a5dc925dbd7be8b75db51b8c02d34159d26c
+`,
+		},
+		{
+			name:   "JSON",
+			policy: `{"projects": {"MyProject": {"signees": [{"user": "bob", "key": "bob.asc", "type": "file"}]}}}`,
+		},
+		{
+			name: "Unknown field",
+			policy: `
+projects:
+  MyProject:
+    treshold: 1
+    signees:
+      - user: bob
+        type: github
+`,
+			expectErr: true,
+		},
+		{
+			name: "Threshold too high",
+			policy: `
+projects:
+  MyProject:
+    threshold: 2
+    signees:
+      - user: bob
+        type: github
+`,
+			expectErr: true,
+		},
+		{
+			name: "No signees",
+			policy: `
+projects:
+  MyProject:
+    threshold: 1
+`,
+			expectErr: true,
+		},
+		{
+			name: "Signee without type",
+			policy: `
+projects:
+  MyProject:
+    signees:
+      - user: bob
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		policy, err := release.LoadTrustPolicy(strings.NewReader(tc.policy))
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Len(t, policy.Projects["MyProject"].Signees, 1, tc.name)
+	}
+}
+
+func TestPolicyVerifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	bobKey := filepath.Join(dir, "bob.asc")
+	assert.Nil(t, ioutil.WriteFile(bobKey, mock.SignerPub, 0600))
+	aliceKey := filepath.Join(dir, "alice.asc")
+	assert.Nil(t, ioutil.WriteFile(aliceKey, mock.AltSignerPub, 0600))
+
+	bob := release.NewSignee("bob", bobKey, release.FileSigneeType)
+	alice := release.NewSignee("alice", aliceKey, release.FileSigneeType)
+	eve := release.NewSignee("eve", aliceKey, release.FileSigneeType)
+
+	bobSignatory, err := mock.ValidSignatory()
+	assert.Nil(t, err)
+	altPriv, err := mock.ArmoredToByte(mock.AltSignerPriv)
+	assert.Nil(t, err)
+	aliceSignatory := mock.Signatory(altPriv)
+
+	policy := func(threshold int, bobFingerprint string) *release.TrustPolicy {
+		p, err := release.LoadTrustPolicy(strings.NewReader(fmt.Sprintf(`
+projects:
+  %s:
+    threshold: %d
+    signees:
+      - user: bob
+        key: %s
+        type: file
+        fingerprint: %s
+      - user: alice
+        key: %s
+        type: file
+`, mock.ProjectName, threshold, bobKey, bobFingerprint, aliceKey)))
+		assert.Nil(t, err)
+		return p
+	}
+
+	sign := func(manifest release.Manifester, signatories ...release.Signatory) [][]byte {
+		serialised, err := manifest.Serialise()
+		assert.Nil(t, err)
+		signed, err := ioutil.ReadAll(serialised)
+		assert.Nil(t, err)
+		var signatures [][]byte
+		for _, signatory := range signatories {
+			signature, err := release.NewSigner(pgp.DefaultConfig).Sign(signatory, signed)
+			assert.Nil(t, err)
+			signatures = append(signatures, signature)
+		}
+		return signatures
+	}
+
+	// robert is another name for the key of bob
+	robert := release.NewSignee("robert", bobKey, release.FileSigneeType)
+	shared, err := release.LoadTrustPolicy(strings.NewReader(fmt.Sprintf(`
+projects:
+  %s:
+    threshold: 2
+    signees:
+      - user: bob
+        key: %s
+        type: file
+      - user: robert
+        key: %s
+        type: file
+`, mock.ProjectName, bobKey, bobKey)))
+	assert.Nil(t, err)
+
+	twice := release.NewMultiSigneeManifest(mock.ProjectName, "v1.0.0", []release.Signee{bob, bob}, mock.ValidArtifacts())
+	aliases := release.NewMultiSigneeManifest(mock.ProjectName, "v1.0.0", []release.Signee{bob, robert}, mock.ValidArtifacts())
+	both := release.NewMultiSigneeManifest(mock.ProjectName, "v1.0.0", []release.Signee{bob, alice}, mock.ValidArtifacts())
+
+	testCases := []struct {
+		name       string
+		policy     *release.TrustPolicy
+		manifest   release.Manifester
+		signatures [][]byte
+		expect     int
+		expectErr  error
+	}{
+		{
+			name:       "Single signee",
+			policy:     policy(1, mock.SignerFingerPrint),
+			manifest:   release.NewManifest(mock.ProjectName, "v1.0.0", bob, mock.ValidArtifacts()),
+			signatures: sign(release.NewManifest(mock.ProjectName, "v1.0.0", bob, mock.ValidArtifacts()), bobSignatory),
+			expect:     1,
+		},
+		{
+			name:       "Threshold met",
+			policy:     policy(2, mock.SignerFingerPrint),
+			manifest:   both,
+			signatures: sign(both, aliceSignatory, bobSignatory),
+			expect:     2,
+		},
+		{
+			name:       "Threshold not met",
+			policy:     policy(2, mock.SignerFingerPrint),
+			manifest:   both,
+			signatures: sign(both, bobSignatory, bobSignatory),
+			expect:     1,
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "Duplicate signee",
+			policy:     policy(2, mock.SignerFingerPrint),
+			manifest:   twice,
+			signatures: append(sign(twice, bobSignatory), sign(twice, bobSignatory)[0]),
+			expectErr:  release.ErrDuplicateSignee,
+		},
+		{
+			name:       "One key for two signees",
+			policy:     shared,
+			manifest:   aliases,
+			signatures: sign(aliases, bobSignatory, bobSignatory),
+			expect:     1,
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "Signing key not pinned",
+			policy:     policy(1, strings.Repeat("0", 40)),
+			manifest:   release.NewManifest(mock.ProjectName, "v1.0.0", bob, mock.ValidArtifacts()),
+			signatures: sign(release.NewManifest(mock.ProjectName, "v1.0.0", bob, mock.ValidArtifacts()), bobSignatory),
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "Signee not authorised",
+			policy:     policy(1, mock.SignerFingerPrint),
+			manifest:   release.NewManifest(mock.ProjectName, "v1.0.0", eve, mock.ValidArtifacts()),
+			signatures: sign(release.NewManifest(mock.ProjectName, "v1.0.0", eve, mock.ValidArtifacts()), aliceSignatory),
+			expectErr:  release.ErrSigneeNotAuthorised,
+		},
+		{
+			name:       "Project not trusted",
+			policy:     policy(1, mock.SignerFingerPrint),
+			manifest:   release.NewManifest("OtherProject", "v1.0.0", bob, mock.ValidArtifacts()),
+			signatures: sign(release.NewManifest("OtherProject", "v1.0.0", bob, mock.ValidArtifacts()), bobSignatory),
+			expectErr:  release.ErrProjectNotTrusted,
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := release.NewPolicyVerifier(tc.policy, release.NewManifestVerifier(nil)).VerifyRelease(tc.manifest, tc.signatures)
+		if tc.expectErr != nil {
+			assert.Equal(t, tc.expectErr, errors.Cause(err), tc.name)
+		} else {
+			assert.Nil(t, err, tc.name)
+		}
+		if result != nil {
+			assert.Len(t, result.Valid, tc.expect, tc.name)
+		}
+	}
+}
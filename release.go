@@ -1,6 +1,9 @@
 package release
 
 import (
+	"context"
+	"io/ioutil"
+
 	"github.com/pkg/errors"
 	"github.com/stoic-cli/stoic-release/pgp"
 )
@@ -22,8 +25,10 @@ type Creator interface {
 	// Create a new release by normalising all artifacts,
 	// generating digests and creating a manifest. This
 	// will return the manifest and its artifacts if successful
-	// otherwise an error.
-	Create(signee Signee) (manifest Manifester, artifacts []Artifact, err error)
+	// otherwise an error. Providing multiple signees creates
+	// a manifest that is expected to carry a signature
+	// from each of them.
+	Create(signee Signee, signees ...Signee) (manifest Manifester, artifacts []Artifact, err error)
 }
 
 // Adder provides an interface for adding artifacts
@@ -47,6 +52,8 @@ func New(name string, options ...Option) Releaser {
 		version:  NewGitHistoryVersion(".", "master", 1),
 		Signer:   NewSigner(pgp.DefaultConfig),
 		Verifier: NewVerifier(pgp.DefaultConfig),
+		before:   map[Stage][]Hook{},
+		after:    map[Stage][]Hook{},
 	}
 	for _, o := range options {
 		o(r)
@@ -72,6 +79,37 @@ func Save(saver Saver) Option {
 	}
 }
 
+// Sign replaces the default pgp signer
+func Sign(signer Signer) Option {
+	return func(args *releaser) {
+		args.Signer = signer
+	}
+}
+
+// Verify replaces the default pgp verifier
+func Verify(verifier Verifier) Option {
+	return func(args *releaser) {
+		args.Verifier = verifier
+	}
+}
+
+// SignArtifacts makes the releaser create a detached
+// signature for each artifact using the signatory
+func SignArtifacts(signatory Signatory) Option {
+	return func(args *releaser) {
+		args.artifactSignatory = signatory
+	}
+}
+
+// Encrypt makes the releaser encrypt each artifact using the
+// encrypter, the manifest records the digests of both the
+// plaintext and the ciphertext
+func Encrypt(encrypter Encrypter) Option {
+	return func(args *releaser) {
+		args.encrypter = encrypter
+	}
+}
+
 // Version adds a versioner
 func Version(versioner Versioner) Option {
 	return func(args *releaser) {
@@ -87,6 +125,13 @@ type releaser struct {
 	savers    []Saver
 	deployers []Deployer
 
+	artifactSignatory Signatory
+	encrypter         Encrypter
+
+	observers []Observer
+	before    map[Stage][]Hook
+	after     map[Stage][]Hook
+
 	// Pull in some external functionality
 	Saver
 	Deployer
@@ -107,20 +152,129 @@ func (o *releaser) Add(digester Digester, artifact Artifact, artifacts ...Artifa
 
 // Create a manifest of the release artifacts, including adding
 // information on the signing party and digests of the artifacts
-func (o *releaser) Create(signee Signee) (Manifester, []Artifact, error) {
+func (o *releaser) Create(signee Signee, signees ...Signee) (Manifester, []Artifact, error) {
+	return o.CreateContext(context.Background(), signee, signees...)
+}
+
+// CreateContext is Create where the context applies to
+// generating the version and stops between artifacts
+func (o *releaser) CreateContext(ctx context.Context, signee Signee, signees ...Signee) (Manifester, []Artifact, error) {
+	ctx = o.observe(ctx)
+	err := runHooks(ctx, o.before[StageCreate], StageCreate, nil, o.artifacts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The artifacts of the release are kept as they were added,
+	// so creating it again doesn't encrypt them twice
+	artifacts := make([]Artifact, 0, len(o.artifacts))
 	for _, artifact := range o.artifacts {
-		digests, err := o.digester.Digest(artifact.Content())
+		if err := ctx.Err(); err != nil {
+			return nil, nil, errors.Wrap(err, "create failed")
+		}
+		if o.encrypter != nil {
+			encrypted, err := encryptArtifact(ctx, o.encrypter, o.digester, artifact)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "create failed")
+			}
+			artifact = encrypted
+		}
+		artifacts = append(artifacts, artifact)
+
+		// Large artifacts can be cancelled while they are read
+		digests, err := o.digester.Digest(&contextReader{ctx: ctx, r: artifact.Content()})
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "create failed")
 		}
 		artifact.SetDigests(digests)
+		emit(ctx, Event{
+			Type:     EventArtifactDigested,
+			Artifact: artifact,
+			Digests:  digests,
+		})
+
+		if o.artifactSignatory != nil {
+			content, err := ioutil.ReadAll(&contextReader{ctx: ctx, r: artifact.Content()})
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "create failed")
+			}
+			signature, err := o.Signer.Sign(o.artifactSignatory, content)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "create failed")
+			}
+			artifact.SetSignature(signature)
+			emit(ctx, Event{
+				Type:     EventSignatureCreated,
+				Artifact: artifact,
+			})
+		}
 	}
 
-	version, err := o.version.Version()
+	version, err := NewVersionerContext(o.version).VersionContext(ctx)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "create failed")
 	}
-	manifest := NewManifest(o.name, version, signee, o.artifacts)
+	manifest := newManifest(o.name, version, o.Scheme(), append([]Signee{signee}, signees...), artifacts)
+
+	err = runHooks(ctx, o.after[StageCreate], StageCreate, manifest, artifacts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, artifacts, nil
+}
+
+// Sign the data and notify the observers
+func (o *releaser) Sign(signatory Signatory, data []byte) ([]byte, error) {
+	signature, err := o.Signer.Sign(signatory, data)
+	if err != nil {
+		return nil, err
+	}
+	emit(o.observe(context.Background()), Event{Type: EventSignatureCreated})
+	return signature, nil
+}
 
-	return manifest, o.artifacts, nil
+// Save the release with all savers
+func (o *releaser) Save(signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return o.SaveContext(context.Background(), signatures, manifest, artifacts)
+}
+
+// SaveContext saves the release with all savers until the context is done
+func (o *releaser) SaveContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	ctx = o.observe(ctx)
+	err := runHooks(ctx, o.before[StageSave], StageSave, manifest, artifacts)
+	if err != nil {
+		return err
+	}
+	err = NewSaverContext(o.Saver).SaveContext(ctx, signatures, manifest, artifacts)
+	if err != nil {
+		return err
+	}
+	return runHooks(ctx, o.after[StageSave], StageSave, manifest, artifacts)
+}
+
+// Deploy the release with all deployers
+func (o *releaser) Deploy(signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return o.DeployContext(context.Background(), signatures, manifest, artifacts)
+}
+
+// DeployContext deploys the release with all deployers until the context is done
+func (o *releaser) DeployContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	ctx = o.observe(ctx)
+	err := runHooks(ctx, o.before[StageDeploy], StageDeploy, manifest, artifacts)
+	if err != nil {
+		return err
+	}
+	err = NewDeployerContext(o.Deployer).DeployContext(ctx, signatures, manifest, artifacts)
+	if err != nil {
+		return err
+	}
+	return runHooks(ctx, o.after[StageDeploy], StageDeploy, manifest, artifacts)
+}
+
+// observe returns a context carrying the observers of the release
+func (o *releaser) observe(ctx context.Context) context.Context {
+	for _, observer := range o.observers {
+		ctx = WithObserver(ctx, observer)
+	}
+	return ctx
 }
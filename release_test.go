@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path"
 	"strings"
 	"testing"
 
 	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/minisign"
 	"github.com/stoic-cli/stoic-release/mock"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,3 +52,77 @@ func TestNew(t *testing.T) {
 	assert.Nil(t, err)
 	fmt.Println(identities)
 }
+
+func TestSignArtifacts(t *testing.T) {
+	p := "MyProject"
+	signatory, err := mock.ValidSignatory()
+	assert.Nil(t, err)
+
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("some content")), p, release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+
+	releaser := release.New(p,
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+		release.SignArtifacts(signatory),
+		release.Save(release.NewFileSystemSaver(dir)),
+	).Add(release.NewDigester(release.DigestTypeSHA256), a)
+	manifest, artifacts, err := releaser.Create(mock.ValidSignee())
+	assert.Nil(t, err)
+	assert.NotEmpty(t, artifacts[0].Signature())
+
+	err = releaser.Save([][]byte{[]byte("manifest signature")}, manifest, artifacts)
+	assert.Nil(t, err)
+
+	_, err = os.Stat(path.Join(dir, "myproject_v1.0.0.relnotes.asc"))
+	assert.Nil(t, err)
+
+	_, _, loaded, err := release.NewFileSystemLoader(dir).Load()
+	assert.Nil(t, err)
+	assert.Equal(t, artifacts[0].Signature(), loaded[0].Signature())
+	assert.Nil(t, releaser.VerifyArtifacts(mock.ValidSignee(), loaded))
+
+	loaded[0].SetSignature(mock.Signature)
+	assert.Error(t, releaser.VerifyArtifacts(mock.ValidSignee(), loaded))
+
+	// A signed artifact can't be made unsigned by deleting its signature
+	assert.True(t, manifest.Artifacts()[0].Signed)
+	assert.Nil(t, os.Remove(path.Join(dir, "myproject_v1.0.0.relnotes.asc")))
+	_, _, _, err = release.NewFileSystemLoader(dir).Load()
+	assert.Error(t, err)
+}
+
+func TestMinisignRelease(t *testing.T) {
+	kp, err := minisign.NewSigner()
+	assert.Nil(t, err)
+	signee := mock.Signee("", "bob", release.GithubSigneeType, kp.PublicKey, nil)
+
+	a, err := release.NewArtifact(ioutil.NopCloser(strings.NewReader("some content")), "MyProject", release.ArtifactTypeReleaseNotes)
+	assert.Nil(t, err)
+
+	releaser := release.New("MyProject",
+		release.Version(release.NewProvidedVersion(1, 0, 0)),
+		release.Sign(release.NewMinisignSigner("release: MyProject v1.0.0")),
+		release.Verify(release.NewMinisignVerifier()),
+	).Add(release.NewDigester(release.DigestTypeSHA256), a)
+	manifest, _, err := releaser.Create(signee)
+	assert.Nil(t, err)
+	assert.Equal(t, release.SignatureSchemeMinisign, manifest.Signees()[0].Scheme)
+
+	m, err := manifest.Serialise()
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, m)
+	assert.Nil(t, err)
+	signature, err := releaser.Sign(release.NewSignatory(kp.PrivateKey), buf.Bytes())
+	assert.Nil(t, err)
+
+	identities, err := release.NewManifestVerifier(nil).VerifyManifestSignature(signee, manifest, signature)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"release: MyProject v1.0.0"}, identities)
+
+	_, err = release.NewManifestVerifier(nil).VerifyManifestSignature(mock.ValidSignee(), manifest, signature)
+	assert.Error(t, err)
+}
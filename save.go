@@ -2,6 +2,7 @@ package release
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -13,7 +14,7 @@ import (
 
 // Saver provides an interface for storing a release
 type Saver interface {
-	Save(signature []byte, manifest Manifester, artifacts []Artifact) error
+	Save(signatures [][]byte, manifest Manifester, artifacts []Artifact) error
 }
 
 type saver struct {
@@ -28,10 +29,15 @@ func NewSavers(savers []Saver) Saver {
 	}
 }
 
-// Save the manifest, artifacts and signature
-func (s *saver) Save(signature []byte, manifest Manifester, artifacts []Artifact) error {
+// Save the manifest, artifacts and signatures
+func (s *saver) Save(signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return s.SaveContext(context.Background(), signatures, manifest, artifacts)
+}
+
+// SaveContext saves with each saver in turn until the context is done
+func (s *saver) SaveContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
 	for _, saver := range s.savers {
-		err := saver.Save(signature, manifest, artifacts)
+		err := NewSaverContext(saver).SaveContext(ctx, signatures, manifest, artifacts)
 		if err != nil {
 			return errors.Wrap(err, "failed to save")
 		}
@@ -52,7 +58,13 @@ func NewFileSystemSaver(directory string) Saver {
 }
 
 // Save the release to the file system
-func (fs *fileSystemSaver) Save(signature []byte, manifest Manifester, artifacts []Artifact) error {
+func (fs *fileSystemSaver) Save(signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
+	return fs.SaveContext(context.Background(), signatures, manifest, artifacts)
+}
+
+// SaveContext saves the release to the file system, writing
+// stops once the context is done
+func (fs *fileSystemSaver) SaveContext(ctx context.Context, signatures [][]byte, manifest Manifester, artifacts []Artifact) error {
 	absPath, err := filepath.Abs(fs.directory)
 	if err != nil {
 		return errors.Wrap(err, "failed to get absolute path")
@@ -67,19 +79,36 @@ func (fs *fileSystemSaver) Save(signature []byte, manifest Manifester, artifacts
 	if err != nil {
 		return errors.Wrap(err, "failed to serialise manifest")
 	}
-	err = createAndWriteFile(serialisedManifest, absPath, manifest.NormalisedName())
+	err = createAndWriteFile(ctx, serialisedManifest, absPath, manifest.NormalisedName())
 	if err != nil {
 		return err
 	}
 
-	err = createAndWriteFile(bytes.NewReader(signature), absPath, fmt.Sprintf("%s.asc", manifest.NormalisedName()))
+	for i, signature := range signatures {
+		err = createAndWriteFile(ctx, bytes.NewReader(signature), absPath, signatureName(manifest.NormalisedName(), i))
+		if err != nil {
+			return err
+		}
+	}
+	err = removeSignatures(absPath, manifest.NormalisedName(), len(signatures))
 	if err != nil {
 		return err
 	}
 
 	v := manifest.Version()
 	for _, artifact := range artifacts {
-		err = createAndWriteFile(artifact.Content(), absPath, artifact.NormalisedName(v))
+		err = createAndWriteFile(ctx, artifact.Content(), absPath, artifact.NormalisedName(v))
+		if err != nil {
+			return err
+		}
+		if len(artifact.Signature()) > 0 {
+			err = createAndWriteFile(ctx, bytes.NewReader(artifact.Signature()), absPath, signatureName(artifact.NormalisedName(v), 0))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = removeSignatures(absPath, artifact.NormalisedName(v), 0)
 		if err != nil {
 			return err
 		}
@@ -88,19 +117,54 @@ func (fs *fileSystemSaver) Save(signature []byte, manifest Manifester, artifacts
 	return nil
 }
 
-func createAndWriteFile(content io.Reader, basePath, name string) error {
+// removeSignatures removes the detached signatures of the given
+// file left over from an earlier save, starting at the n-th, so
+// they aren't loaded along with the ones just saved
+func removeSignatures(basePath, name string, n int) error {
+	for ; ; n++ {
+		err := os.Remove(path.Join(basePath, signatureName(name, n)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove signature: %s", signatureName(name, n))
+		}
+	}
+}
+
+// signatureName returns the file name of the n-th detached
+// signature of the given file, the first signature is
+// stored as <name>.asc and any further as <name>.<n>.asc
+func signatureName(name string, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%s.asc", name)
+	}
+	return fmt.Sprintf("%s.%d.asc", name, n)
+}
+
+func createAndWriteFile(ctx context.Context, content io.Reader, basePath, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fileName := path.Join(basePath, name)
 	file, err := os.Create(fileName)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to create file: %s", name))
 	}
-	_, err = io.Copy(file, content)
+	n, err := io.Copy(file, &contextReader{ctx: ctx, r: content})
 	if err != nil {
+		_ = file.Close()
 		return errors.Wrap(err, fmt.Sprintf("failed to write content to file: %s", name))
 	}
 	err = file.Close()
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to close file: %s", name))
 	}
+	emit(ctx, Event{
+		Type:  EventFileWritten,
+		Name:  fileName,
+		Bytes: n,
+		Total: n,
+	})
 	return nil
 }
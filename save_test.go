@@ -12,20 +12,47 @@ import (
 func TestNewRelease(t *testing.T) {
 	artifacts := mock.ValidArtifacts()
 	manifest := release.NewManifest("MyProject", "v1.0.0", mock.ValidSignee(), artifacts)
-	signature := []byte("some kind of signature")
+	signatures := [][]byte{
+		[]byte("some kind of signature"),
+		[]byte("some other kind of signature"),
+	}
 
 	dir, err := ioutil.TempDir("", "release-")
 	assert.Nil(t, err)
 	saver := release.NewFileSystemSaver(dir)
 
-	err = saver.Save(signature, manifest, artifacts)
+	err = saver.Save(signatures, manifest, artifacts)
 	assert.Nil(t, err)
 
 	loader := release.NewFileSystemLoader(dir)
-	sig, mani, arts, err := loader.Load()
+	sigs, mani, arts, err := loader.Load()
 	assert.Nil(t, err)
-	assert.Equal(t, signature, sig)
+	assert.Equal(t, signatures, sigs)
 	assert.Equal(t, manifest, mani)
 	//FIXME: improve this shit
 	assert.Equal(t, artifacts[0].Digests(), arts[0].Digests())
 }
+
+func TestSaveRemovesStaleSignatures(t *testing.T) {
+	artifacts := mock.ValidArtifacts()
+	manifest := release.NewManifest("MyProject", "v1.0.0", mock.ValidSignee(), artifacts)
+
+	dir, err := ioutil.TempDir("", "release-")
+	assert.Nil(t, err)
+	saver := release.NewFileSystemSaver(dir)
+
+	err = saver.Save([][]byte{
+		[]byte("first signature"),
+		[]byte("second signature"),
+		[]byte("third signature"),
+	}, manifest, artifacts)
+	assert.Nil(t, err)
+
+	signatures := [][]byte{[]byte("only signature")}
+	err = saver.Save(signatures, manifest, artifacts)
+	assert.Nil(t, err)
+
+	sigs, _, _, err := release.NewFileSystemLoader(dir).Load()
+	assert.Nil(t, err)
+	assert.Equal(t, signatures, sigs)
+}
@@ -1,17 +1,34 @@
 package release
 
 import (
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/keyless"
+	"github.com/stoic-cli/stoic-release/minisign"
 	"github.com/stoic-cli/stoic-release/pgp"
-	"golang.org/x/crypto/openpgp/packet"
 )
 
 // Signer provides the interface required to sign data
 type Signer interface {
 	// Sign uses the signatory to sign the provided data
 	Sign(signatory Signatory, sign []byte) (signed []byte, err error)
+
+	// Scheme returns the signature scheme used by the signer
+	Scheme() SignatureScheme
 }
 
+// SignatureScheme enumerates the supported signature schemes
+type SignatureScheme string
+
+// nolint
+const (
+	SignatureSchemePGP      SignatureScheme = "pgp"
+	SignatureSchemeMinisign SignatureScheme = "minisign"
+	SignatureSchemeKeyless  SignatureScheme = "keyless"
+)
+
 type signer struct {
 	config *packet.Config
 }
@@ -28,9 +45,90 @@ func (s *signer) Sign(signatory Signatory, sign []byte) ([]byte, error) {
 	if s.config == nil {
 		s.config = pgp.DefaultConfig
 	}
-	signed, err := pgp.Sign(signatory.PrivateKey(), sign, s.config)
+
+	var signed []byte
+	var err error
+	switch sig := signatory.(type) {
+	case DetachedSignatory:
+		signed, err = sig.SignDetached(sign)
+	case DelegatedSignatory:
+		signed, err = pgp.SignWithSigner(sig, sig.CreationTime(), sign, s.config)
+	default:
+		signed, err = pgp.Sign(signatory.PrivateKey(), sign, s.config)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign data")
+	}
+	return signed, nil
+}
+
+// Scheme returns the pgp signature scheme
+func (s *signer) Scheme() SignatureScheme {
+	return SignatureSchemePGP
+}
+
+type minisignSigner struct {
+	trustedComment string
+}
+
+// NewMinisignSigner creates a new stand-alone signer that produces
+// minisign compatible Ed25519 signatures, the trusted comment is
+// signed together with the data
+func NewMinisignSigner(trustedComment string) Signer {
+	return &minisignSigner{
+		trustedComment: trustedComment,
+	}
+}
+
+// Sign the provided artifact
+func (s *minisignSigner) Sign(signatory Signatory, sign []byte) ([]byte, error) {
+	if signatory.PrivateKey() == nil {
+		return nil, fmt.Errorf("failed to sign data: minisign requires a private key")
+	}
+	signed, err := minisign.Sign(signatory.PrivateKey(), sign, s.trustedComment)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign data")
+	}
+	return signed, nil
+}
+
+// Scheme returns the minisign signature scheme
+func (s *minisignSigner) Scheme() SignatureScheme {
+	return SignatureSchemeMinisign
+}
+
+type keylessSigner struct {
+	ca       keyless.CertificateAuthority
+	log      keyless.Log
+	identity keyless.Identity
+}
+
+// NewKeylessSigner creates a new stand-alone signer that signs with an
+// ephemeral key certified for the identity by the certificate authority,
+// and records the signature in the transparency log. The signature is
+// a serialised bundle, and the signatory is ignored.
+func NewKeylessSigner(ca keyless.CertificateAuthority, log keyless.Log, identity keyless.Identity) Signer {
+	return &keylessSigner{
+		ca:       ca,
+		log:      log,
+		identity: identity,
+	}
+}
+
+// Sign the provided artifact
+func (s *keylessSigner) Sign(_ Signatory, sign []byte) ([]byte, error) {
+	bundle, err := keyless.Sign(s.ca, s.log, s.identity, sign)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to sign data")
 	}
+	signed, err := bundle.Serialise()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialise bundle")
+	}
 	return signed, nil
 }
+
+// Scheme returns the keyless signature scheme
+func (s *keylessSigner) Scheme() SignatureScheme {
+	return SignatureSchemeKeyless
+}
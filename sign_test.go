@@ -1,34 +1,44 @@
 package release_test
 
 import (
+	"bytes"
+	"crypto"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/gpgagent"
 	"github.com/stoic-cli/stoic-release/mock"
 	"github.com/stoic-cli/stoic-release/pgp"
 	"github.com/stretchr/testify/assert"
 )
 
+// testTime is after the creation of the mock key, since
+// a key can't make signatures before it was created
 func testTime() time.Time {
-	return time.Unix(13455533, 23333)
+	return time.Unix(1527600000, 23333)
 }
 
 var testSignature = `-----BEGIN PGP SIGNATURE-----
 
-wsFcBAABCAAQBQIAzVCtCRAbjALTQVnSbAAAp3kQABwXELCPeJVo7eUGEWG6f55Z
-SiYJZNe44ib6A3/YWGf9iC3T0kuXVVf0NE/Jj0JU1Koyvp+vCeD+Ubk/Q+uG5WJH
-+a60aoWfxCxFb/XkfB3cNq+5/9sojHYyUnpbYdsBvh0w2qDwRhDe5dZhE2hMqel3
-bFMK20v9vIw1sLaAJL/h0cXcDxImDrj6LQBvFQFeVNDUQSXq68fKkVfEFeBr7ygT
-VGup7ijFQPA9yqJwzuWEbusD0Nj9dKF81LtUw11rutrLbABGTRZFAoit6Sq5rJcn
-w7WL9ojiuO05ETxBLVuAfzoYeh+oYZjMnJ8OxzSu8EEg7Uwey04GCBZM0VTe8oUM
-8khf+g3QIGW7DOgCmrFVcwuONoh83S4F+0fY5MJGqJzegen1JufUD3ymVWR6ssvc
-mWqpgimkAomoZ24r7x5zcXLAl0kOLE+PbWhivI47ensDn7MIISWojPn3y1wpnUxg
-qijspDrJbmL3xKEWj3RIAT5wA/uZbcoe/sETClrsXSzOE+5f6OHPF+T0NrW2fPI+
-QVfDLdfuHhOBNkmDYT/WZZ2xffq5nMkn1Bs3s++gLTtqJ0BktTQpE2JE4WE91xOX
-VKpFLFIUhdBOPBkwEtcMF1m/V6hgwKf9xS7kHWMgO1yGHZM5DuKj5MW3x9kIrAUt
-akckybeawRp5/eFv12z3
-=O0Zl
+wsFzBAABCAAnBYJbDVOACZAbjALTQVnSbBYhBHBRpdySXb176LddtRuMAtNBWdJs
+AADiZg//QgsP1XED2LBw90WloE0jmH6fP/X9f+tr7uS4m21S43PXLvV0J5VoZ7kr
+CyRYES80Cmi6fEUwAPsDk1d7YLA6MRKjkr5YJvm8mq4vf9i3gCvlb0dUUKH4AVqR
+03VFMuRdfwTfWQIE0r6Dg3jZogvj1KE9ttvru/G36BEOn99Fbh74qcnOpwnevkud
+abbMKasq7xxMRSTcOpgNLptCxzloEC/McAzFYLkxvXlMGjNiZTGq4vHtXjAkTJ4J
+811X/N3ved6elBhRZakpdZhu+Vu8mjnAnNShIGuk4ueKhGAXTaDTNJPHxOE2zJPC
+brX9Qv+OSUCibK9C7AnjVtl0vtmcQwNABhQg0MWgVPb2WeApdAWPSt01kzbwRHVd
+1bhXXPB9Vha55GajPp6HTPIFYr+6+kc7ejkOp34y0/QpWV33Wn/aobJct/Z1DKMc
+ACs0a0j09wRtQzYirzvQ46uyiZdGUWLKCgvXHQfZ6ZOO4sbDITuBmZnas4/YdvyD
+MFfZ42Rs/9aPXORbuOJGk/+LEAY/KS+kO3BcMdZzYFcTxonh86Q3bEKThWaVSR8/
+B6CtFz3uPD5zWGy+0d9fqiZJu8UQHcYNYMwgMUWZZQkQ6FTjnqyWx5pYlnE7bXNG
+3s+4oCQmIsIgFIQwSNaFOFsLM1CsXCfZpoGS6gTKPRIKsgULQGI=
+=LwDZ
 -----END PGP SIGNATURE-----`
 
 func TestNewSigner(t *testing.T) {
@@ -57,10 +67,12 @@ func TestNewSigner(t *testing.T) {
 		},
 	}
 
-	config := pgp.DefaultConfig
+	config := *pgp.DefaultConfig
 	config.Time = testTime
+	deterministic := false
+	config.NonDeterministicSignaturesViaNotation = &deterministic
 	for _, tc := range testCases {
-		got, err := release.NewSigner(config).Sign(tc.signatory, tc.sign)
+		got, err := release.NewSigner(&config).Sign(tc.signatory, tc.sign)
 		if tc.expectErr {
 			assert.Error(t, err, tc.name)
 			assert.Equal(t, err.Error(), tc.expect, tc.name)
@@ -71,3 +83,93 @@ func TestNewSigner(t *testing.T) {
 		}
 	}
 }
+
+func TestDelegatedSignatory(t *testing.T) {
+	pk, err := mock.ArmoredToByte(mock.SignerPriv)
+	assert.Nil(t, err)
+	entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(pk)))
+	assert.Nil(t, err)
+
+	// Any crypto.Signer will do, e.g., a key kept in an HSM
+	signatory := release.NewDelegatedSignatory(entity.PrivateKey.PrivateKey.(crypto.Signer), entity.PrimaryKey.CreationTime)
+	assert.Nil(t, signatory.PrivateKey())
+
+	signature, err := release.NewSigner(pgp.DefaultConfig).Sign(signatory, mock.Signed)
+	assert.Nil(t, err)
+	_, err = release.NewVerifier(pgp.DefaultConfig).VerifySignature(mock.ValidSignee(), mock.Signed, signature)
+	assert.Nil(t, err)
+}
+
+func TestCommandSignatory(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not found, skipping")
+	}
+
+	dir, err := ioutil.TempDir("", "gpg-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	cmd := exec.Command("gpg", "--homedir", dir, "--batch", "--import")
+	cmd.Stdin = bytes.NewReader(mock.SignerPriv)
+	out, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(out))
+	defer exec.Command("gpgconf", "--homedir", dir, "--kill", "gpg-agent").Run() // nolint: errcheck
+
+	signatory := release.NewGPGSignatory(mock.SignerFingerPrint, "--homedir", dir)
+	signature, err := release.NewSigner(pgp.DefaultConfig).Sign(signatory, mock.Signed)
+	assert.Nil(t, err)
+	_, err = release.NewVerifier(pgp.DefaultConfig).VerifySignature(mock.ValidSignee(), mock.Signed, signature)
+	assert.Nil(t, err)
+
+	_, err = release.NewSigner(pgp.DefaultConfig).Sign(release.NewGPGSignatory("0000000000000000", "--homedir", dir), mock.Signed)
+	assert.Error(t, err)
+}
+
+func TestGPGAgentSignatory(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not found, skipping")
+	}
+
+	dir, err := ioutil.TempDir("", "gpg")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	cmd := exec.Command("gpg", "--homedir", dir, "--batch", "--import")
+	cmd.Stdin = bytes.NewReader(mock.SignerPriv)
+	out, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(out))
+	defer exec.Command("gpgconf", "--homedir", dir, "--kill", "gpg-agent").Run() // nolint: errcheck
+
+	socket, err := gpgagent.DefaultSocket(dir)
+	assert.Nil(t, err)
+	signatory, err := release.NewGPGAgentSignatory(socket, mock.SignerPub)
+	assert.Nil(t, err)
+
+	signature, err := release.NewSigner(pgp.DefaultConfig).Sign(signatory, mock.Signed)
+	assert.Nil(t, err)
+	_, err = release.NewVerifier(pgp.DefaultConfig).VerifySignature(mock.ValidSignee(), mock.Signed, signature)
+	assert.Nil(t, err)
+
+	// Signatures are made with the signing subkey once there is one
+	out, err = exec.Command("gpg", "--homedir", dir, "--batch", "--pinentry-mode", "loopback", "--passphrase", "",
+		"--quick-add-key", mock.SignerFingerPrint, "nistp256/ecdsa", "sign").CombinedOutput()
+	assert.Nil(t, err, string(out))
+	publicKey, err := exec.Command("gpg", "--homedir", dir, "--armor", "--export", mock.SignerFingerPrint).Output()
+	assert.Nil(t, err)
+	signatory, err = release.NewGPGAgentSignatory(socket, publicKey)
+	assert.Nil(t, err)
+	signature, err = release.NewSigner(pgp.DefaultConfig).Sign(signatory, mock.Signed)
+	assert.Nil(t, err)
+	verification, err := pgp.Verify(publicKey, mock.Signed, signature, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	assert.NotEqual(t, verification.PrimaryKeyID, verification.KeyID)
+
+	// EdDSA keys can't be used by a delegated signatory
+	out, err = exec.Command("gpg", "--homedir", dir, "--batch", "--pinentry-mode", "loopback", "--passphrase", "",
+		"--quick-gen-key", "EdDSA <eddsa@example.com>", "ed25519", "sign", "never").CombinedOutput()
+	assert.Nil(t, err, string(out))
+	publicKey, err = exec.Command("gpg", "--homedir", dir, "--armor", "--export", "eddsa@example.com").Output()
+	assert.Nil(t, err)
+	_, err = release.NewGPGAgentSignatory(socket, publicKey)
+	assert.EqualError(t, err, "failed to create gpg-agent signer: unsupported public key type: *eddsa.PublicKey")
+}
@@ -1,13 +1,53 @@
 package release
 
-import "github.com/awnumar/memguard"
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/gpgagent"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
 
 // Signatory provides the interface required
 // to sign a release
 type Signatory interface {
+	// PrivateKey returns the private key of the signatory, it
+	// is nil for signatories that delegate signing and never
+	// expose their key
 	PrivateKey() *memguard.LockedBuffer
 }
 
+// DelegatedSignatory provides the interface required for
+// a signatory that keeps its private key to itself, e.g.,
+// in an HSM, and only signs digests on request
+type DelegatedSignatory interface {
+	Signatory
+	crypto.Signer
+
+	// CreationTime returns the creation time of the public
+	// key, since it is part of an OpenPGP key's fingerprint
+	CreationTime() time.Time
+}
+
+// DetachedSignatory provides the interface required for a
+// signatory that creates complete armored detached
+// signatures itself, e.g., an external gpg
+type DetachedSignatory interface {
+	Signatory
+
+	// SignDetached returns an armored detached
+	// signature of the data
+	SignDetached(data []byte) ([]byte, error)
+}
+
 type signatory struct {
 	privateKey *memguard.LockedBuffer
 }
@@ -23,3 +63,106 @@ func NewSignatory(privateKey *memguard.LockedBuffer) Signatory {
 func (s *signatory) PrivateKey() *memguard.LockedBuffer {
 	return s.privateKey
 }
+
+type delegatedSignatory struct {
+	crypto.Signer
+	creationTime time.Time
+}
+
+// NewDelegatedSignatory creates a signatory that delegates
+// signing to the provided signer
+func NewDelegatedSignatory(signer crypto.Signer, creationTime time.Time) DelegatedSignatory {
+	return &delegatedSignatory{
+		Signer:       signer,
+		creationTime: creationTime,
+	}
+}
+
+// PrivateKey returns nil, the key is kept by the signer
+func (s *delegatedSignatory) PrivateKey() *memguard.LockedBuffer {
+	return nil
+}
+
+// CreationTime returns the creation time of the public key
+func (s *delegatedSignatory) CreationTime() time.Time {
+	return s.creationTime
+}
+
+// NewGPGAgentSignatory creates a signatory that signs using the secret key
+// held by gpg-agent, e.g., on a smartcard. The armored public key is
+// used for finding the key in the agent, signatures are made with its
+// newest valid signing subkey, or the primary key if there is none.
+// Only RSA and ECDSA keys are supported.
+func NewGPGAgentSignatory(socket string, publicKey []byte) (DelegatedSignatory, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read armored keyring")
+	}
+	if len(keyring) != 1 {
+		return nil, fmt.Errorf("expected one public key, got: %d", len(keyring))
+	}
+	key, ok := keyring[0].SigningKey(time.Now())
+	if !ok {
+		return nil, fmt.Errorf("no valid signing key found")
+	}
+
+	signerKey, err := pgp.SignerPublicKey(key.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gpg-agent signer")
+	}
+	signer, err := gpgagent.NewSigner(socket, signerKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gpg-agent signer")
+	}
+
+	return NewDelegatedSignatory(signer, key.PublicKey.CreationTime), nil
+}
+
+type commandSignatory struct {
+	name string
+	args []string
+}
+
+// NewCommandSignatory creates a signatory that runs an external command,
+// which is given the data on stdin and must write an armored detached
+// signature to stdout
+func NewCommandSignatory(name string, args ...string) DetachedSignatory {
+	return &commandSignatory{
+		name: name,
+		args: args,
+	}
+}
+
+// NewGPGSignatory creates a signatory that signs using the
+// gpg command with the given key, any additional arguments,
+// e.g., --homedir, are passed on to gpg
+func NewGPGSignatory(key string, args ...string) DetachedSignatory {
+	return NewCommandSignatory("gpg", append(args,
+		"--batch",
+		"--armor",
+		"--local-user", key,
+		"--detach-sign",
+	)...)
+}
+
+// PrivateKey returns nil, the key is kept by the command
+func (s *commandSignatory) PrivateKey() *memguard.LockedBuffer {
+	return nil
+}
+
+// SignDetached runs the command to sign the data
+func (s *commandSignatory) SignDetached(data []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.name, s.args...) // nolint: gosec
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run: %s: %s", s.name, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return stdout.Bytes(), nil
+}
@@ -1,12 +1,16 @@
 package release
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/hex"
 	"fmt"
-	"net/http"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/pgp"
 )
 
 // Signee provides the available operations
@@ -36,24 +40,84 @@ var DefaultGithubAPIEndpoint = "https://api.github.com"
 // point is required
 var DefaultKeybaseEndpoint = "https://keybase.io/"
 
+// DefaultKeyringDirectory is searched by keyring signees created
+// with NewSignee, use NewKeyringSignee for another directory
+const DefaultKeyringDirectory = "keyring"
+
+var (
+	// ErrFingerprintMismatch indicates that the fetched public
+	// key isn't the one with the pinned fingerprint
+	ErrFingerprintMismatch = errors.New("public key fingerprint mismatch")
+
+	// ErrNoEmbeddedKey indicates that a pinned signee
+	// was created without a public key
+	ErrNoEmbeddedKey = errors.New("no embedded public key")
+
+	// ErrKeylessSignee indicates that the signee signs with short
+	// lived certificates, so it has no public key to fetch
+	ErrKeylessSignee = errors.New("keyless signee has no public key")
+)
+
 // SigneeType enumerates the available
 // default sources for fetching a signee
 type SigneeType string
 
 const (
-	// GithubSigneeType will interact with the
-	// keys of a github user
+	// GithubSigneeType will interact with the keys of a github
+	// user, the key is the key ID or fingerprint of a signing
+	// key or subkey
 	GithubSigneeType SigneeType = "github"
 
 	// KeybaseSigneeType will interact with the
 	// keys of a keybase user
 	KeybaseSigneeType SigneeType = "keybase"
+
+	// GitlabSigneeType will interact with the keys of a
+	// gitlab user, the user is the username or numeric ID
+	GitlabSigneeType SigneeType = "gitlab"
+
+	// GiteaSigneeType will interact with the
+	// keys of a gitea user
+	GiteaSigneeType SigneeType = "gitea"
+
+	// WKDSigneeType will fetch the keys of the user, an email
+	// address, from the web key directory of its domain, the key
+	// may be left out if the user has only one
+	WKDSigneeType SigneeType = "wkd"
+
+	// HKPSigneeType will look up the key ID or fingerprint
+	// on the DefaultHKPEndpoint keyserver
+	HKPSigneeType SigneeType = "hkp"
+
+	// FileSigneeType will read the key from a
+	// local file, the key is the path of the file
+	FileSigneeType SigneeType = "file"
+
+	// KeyringSigneeType will find the key with the key ID or
+	// fingerprint in the key files of the keyring directory
+	KeyringSigneeType SigneeType = "keyring"
+
+	// PinnedSigneeType will use the embedded public
+	// key, the key is its fingerprint
+	PinnedSigneeType SigneeType = "pinned"
+
+	// KeylessSigneeType signs with short lived certificates, the
+	// user is the subject of the certificate and the key the
+	// issuer of the identity, it has no public key
+	KeylessSigneeType SigneeType = "keyless"
 )
 
 type signee struct {
 	user       string
 	key        string
 	signeeType SigneeType
+	publicKey  []byte
+	github     GithubClient
+	gitlab     *gitlabClient
+	gitea      *giteaClient
+	wkd        *wkdClient
+	hkp        *hkpClient
+	keyring    string
 }
 
 // NewSignee can fetch a public key of a signee for verification purposes
@@ -62,90 +126,262 @@ func NewSignee(user string, key string, signeeType SigneeType) Signee {
 		user:       user,
 		key:        key,
 		signeeType: signeeType,
+		keyring:    DefaultKeyringDirectory,
+	}
+}
+
+// NewKeyringSignee finds the public key of the signee in the
+// key files of the directory instead of DefaultKeyringDirectory
+func NewKeyringSignee(dir string, user string, key string) Signee {
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: KeyringSigneeType,
+		keyring:    dir,
+	}
+}
+
+// NewGithubSignee fetches the public key of the signee with the
+// provided client instead of DefaultGithubClient
+func NewGithubSignee(client GithubClient, user string, key string) Signee {
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: GithubSigneeType,
+		github:     client,
+	}
+}
+
+// NewEmbeddedSignee uses the provided public key, e.g., one compiled
+// into the binary, which must have the pinned fingerprint
+func NewEmbeddedSignee(user string, fingerprint string, publicKey []byte) Signee {
+	return &signee{
+		user:       user,
+		key:        fingerprint,
+		signeeType: PinnedSigneeType,
+		publicKey:  publicKey,
+	}
+}
+
+// NewKeylessSignee expects keyless signatures with a certificate for
+// the subject, e.g., an email address or a workflow URI, whose
+// identity was issued by the issuer
+func NewKeylessSignee(subject string, issuer string) Signee {
+	return &signee{
+		user:       subject,
+		key:        issuer,
+		signeeType: KeylessSigneeType,
 	}
 }
 
 // PublicKey returns the public key of the signee
 // or an error if it isn't able to fetch it
 func (s *signee) PublicKey() ([]byte, error) {
+	return s.PublicKeyContext(context.Background())
+}
+
+// PublicKeyContext returns the public key of the signee, the
+// context applies to all requests made for fetching it
+func (s *signee) PublicKeyContext(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	switch s.signeeType {
 	case GithubSigneeType:
-		return s.githubPublicKey()
+		return s.githubPublicKey(ctx)
 	case KeybaseSigneeType:
 		return s.keybasePublicKey()
+	case GitlabSigneeType:
+		return s.gitlabPublicKey(ctx)
+	case GiteaSigneeType:
+		return s.giteaPublicKey(ctx)
+	case WKDSigneeType:
+		return s.wkdPublicKey(ctx)
+	case HKPSigneeType:
+		return s.hkpPublicKey(ctx)
+	case FileSigneeType:
+		return s.filePublicKey()
+	case KeyringSigneeType:
+		return s.keyringPublicKey()
+	case PinnedSigneeType:
+		return s.embeddedPublicKey()
+	case KeylessSigneeType:
+		return nil, ErrKeylessSignee
 	default:
 		return nil, fmt.Errorf("unknown signee type: %s", s.signeeType)
 	}
 }
 
-// GPGKey unmarshals a github response for gpg keys
-// Proudly stolen from: https://github.com/google/go-github
-// They currently don't support the `RawKey` field.
-type GPGKey struct {
-	ID                int64      `json:"id,omitempty"`
-	PrimaryKeyID      int64      `json:"primary_key_id,omitempty"`
-	KeyID             string     `json:"key_id,omitempty"`
-	PublicKey         string     `json:"public_key,omitempty"`
-	RawKey            string     `json:"raw_key,omitempty"`
-	Emails            []GPGEmail `json:"emails,omitempty"`
-	Subkeys           []GPGKey   `json:"subkeys,omitempty"`
-	CanSign           bool       `json:"can_sign,omitempty"`
-	CanEncryptComms   bool       `json:"can_encrypt_comms,omitempty"`
-	CanEncryptStorage bool       `json:"can_encrypt_storage,omitempty"`
-	CanCertify        bool       `json:"can_certify,omitempty"`
-	CreatedAt         time.Time  `json:"created_at,omitempty"`
-	ExpiresAt         time.Time  `json:"expires_at,omitempty"`
-}
-
-// GPGEmail represents the gpg email section
-type GPGEmail struct {
-	Email    string `json:"email,omitempty"`
-	Verified bool   `json:"verified,omitempty"`
-}
-
-func (s *signee) githubPublicKey() ([]byte, error) {
-	//FIXME: This is a horrible mess isn't it.
-	client := &http.Client{}
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/%s/gpg_keys", DefaultGithubAPIEndpoint, s.user), nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create gpg keys request")
+func (s *signee) githubPublicKey(ctx context.Context) ([]byte, error) {
+	client := s.github
+	if client == nil {
+		client = DefaultGithubClient
 	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	res, err := client.Do(req)
+	keys, err := client.GPGKeys(ctx, s.user)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list users gpg keys")
 	}
 
-	var keys []GPGKey
-	err = json.NewDecoder(res.Body).Decode(&keys)
+	key, err := s.findGPGKey(keys)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode users gpg keys")
+		return nil, err
 	}
+	err = s.checkFingerprint([]byte(key.RawKey))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(key.RawKey), nil
+}
 
-	for _, key := range keys {
-		if key.KeyID == s.key {
-			verified := false
-			for _, email := range key.Emails {
-				if email.Verified {
-					verified = true
-					break
-				}
-			}
-			if !verified {
-				return nil, fmt.Errorf("gpg key: %s is not verified for user: %s", s.key, s.user)
-			}
+// findGPGKey returns the listed key that has the key ID of the signee,
+// itself or one of its subkeys, if it has a verified email and can sign
+func (s *signee) findGPGKey(keys []GPGKey) (*GPGKey, error) {
+	keyID, _, err := parseKeyID(s.key)
+	if err != nil {
+		return nil, err
+	}
 
-			return []byte(key.RawKey), nil
+	for i := range keys {
+		key := &keys[i]
+		match := githubKey(*key, keyID)
+		if match == nil {
+			continue
+		}
+
+		verified := false
+		for _, email := range key.Emails {
+			if email.Verified {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, fmt.Errorf("gpg key: %s is not verified for user: %s", s.key, s.user)
 		}
+		if !match.CanSign {
+			return nil, fmt.Errorf("gpg key: %s can't sign for user: %s", s.key, s.user)
+		}
+		now := time.Now()
+		if expired(*key, now) || expired(*match, now) {
+			return nil, fmt.Errorf("gpg key: %s has expired for user: %s", s.key, s.user)
+		}
+		return key, nil
 	}
 
 	return nil, fmt.Errorf("gpg key: %s not found for user: %s", s.key, s.user)
 }
 
+// checkFingerprint checks that the public key has the key of the
+// signee, if it is a fingerprint, since the forges only list key IDs
+func (s *signee) checkFingerprint(publicKey []byte) error {
+	_, fingerprint, err := parseKeyID(s.key)
+	if err != nil || fingerprint == "" {
+		return err
+	}
+	keyring, err := pgp.ReadKeyRing(publicKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read gpg key: %s", s.key)
+	}
+	_, err = pgp.FindKey(keyring, fingerprint)
+	if err != nil {
+		return errors.Wrapf(err, "gpg key: %s", s.key)
+	}
+	return nil
+}
+
+// githubKey returns the key or subkey with the key ID
+func githubKey(key GPGKey, keyID string) *GPGKey {
+	if strings.ToLower(key.KeyID) == keyID {
+		return &key
+	}
+	for i := range key.Subkeys {
+		if strings.ToLower(key.Subkeys[i].KeyID) == keyID {
+			return &key.Subkeys[i]
+		}
+	}
+	return nil
+}
+
+func expired(key GPGKey, now time.Time) bool {
+	return !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(now)
+}
+
+// parseKeyID returns the 16 hex digit key ID of the 16 hex digit key ID
+// or 40 hex digit fingerprint, and the fingerprint if it was one. The
+// key ID of a v4 key is the low 64 bits of its fingerprint, see:
+// https://tools.ietf.org/html/rfc4880#section-12.2
+func parseKeyID(id string) (keyID string, fingerprint string, err error) {
+	id = strings.ToLower(strings.TrimPrefix(strings.Replace(id, " ", "", -1), "0x"))
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", "", fmt.Errorf("invalid gpg key: %s", id)
+	}
+	switch len(id) {
+	case 16:
+		return id, "", nil
+	case 40:
+		return id[24:], id, nil
+	default:
+		return "", "", fmt.Errorf("gpg key: %s is neither a 16 hex digit key ID nor a 40 hex digit fingerprint", id)
+	}
+}
+
 func (s *signee) keybasePublicKey() ([]byte, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (s *signee) filePublicKey() ([]byte, error) {
+	key, err := ioutil.ReadFile(s.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read public key file")
+	}
+	return key, nil
+}
+
+// keyringPublicKey reads the key files of the keyring directory,
+// skipping anything else gpg may keep there, e.g., pubring.kbx
+// or trustdb.gpg
+func (s *signee) keyringPublicKey() ([]byte, error) {
+	files, err := ioutil.ReadDir(s.keyring)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keyring directory")
+	}
+
+	var keys [][]byte
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		key, err := ioutil.ReadFile(filepath.Join(s.keyring, file.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read keyring file")
+		}
+		if pgp.IsPublicKey(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	keyring, err := pgp.ReadKeyRing(keys...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read keyring: %s", s.keyring)
+	}
+	key, err := pgp.FindKey(keyring, s.key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "gpg key: %s", s.key)
+	}
+	return key, nil
+}
+
+func (s *signee) embeddedPublicKey() ([]byte, error) {
+	if len(s.publicKey) == 0 {
+		return nil, ErrNoEmbeddedKey
+	}
+	err := checkFingerprint(s.publicKey, s.key)
+	if err != nil {
+		return nil, err
+	}
+	return s.publicKey, nil
+}
+
 // Key returns the public key
 func (s *signee) Key() string {
 	return s.key
@@ -160,3 +396,49 @@ func (s *signee) User() string {
 func (s *signee) Type() SigneeType {
 	return s.signeeType
 }
+
+type pinnedSignee struct {
+	Signee
+	fingerprint string
+}
+
+// NewPinnedSignee wraps a signee so its public key is only returned
+// if it is a single key with the pinned fingerprint, whatever the
+// source. The user, key and type are those of the wrapped signee
+func NewPinnedSignee(signee Signee, fingerprint string) Signee {
+	return &pinnedSignee{
+		Signee:      signee,
+		fingerprint: fingerprint,
+	}
+}
+
+// PublicKey returns the public key of the wrapped signee
+// if it has the pinned fingerprint
+func (s *pinnedSignee) PublicKey() ([]byte, error) {
+	return s.PublicKeyContext(context.Background())
+}
+
+// PublicKeyContext returns the public key of the wrapped
+// signee if it has the pinned fingerprint
+func (s *pinnedSignee) PublicKeyContext(ctx context.Context) ([]byte, error) {
+	key, err := NewSigneeContext(s.Signee).PublicKeyContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFingerprint(key, s.fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func checkFingerprint(publicKey []byte, fingerprint string) error {
+	got, err := pgp.Fingerprint(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to read public key fingerprint")
+	}
+	if !pgp.SameFingerprint(got, fingerprint) {
+		return errors.Wrapf(ErrFingerprintMismatch, "expected: %s, got: %s", fingerprint, got)
+	}
+	return nil
+}
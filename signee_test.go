@@ -1,15 +1,29 @@
 package release_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stoic-cli/stoic-release"
 	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewSignee(t *testing.T) {
+	server := githubServer(githubKeys, nil)
+	defer server.Close()
+
+	defaultGithubAPIEndpoint := release.DefaultGithubAPIEndpoint
+	release.DefaultGithubAPIEndpoint = server.URL
+	defer func() { release.DefaultGithubAPIEndpoint = defaultGithubAPIEndpoint }()
+
 	testCases := []struct {
 		name       string
 		user       string
@@ -18,7 +32,6 @@ func TestNewSignee(t *testing.T) {
 		expect     string
 		expectErr  bool
 	}{
-		//FIXME: Do this with mocks instead of towards actual server..
 		{
 			name:       "Github with valid user and key",
 			user:       mock.GithubPublicKeyUser,
@@ -26,6 +39,27 @@ func TestNewSignee(t *testing.T) {
 			signeeType: release.GithubSigneeType,
 			expect:     mock.GithubPublicKey,
 		},
+		{
+			name:       "Github with unverified key",
+			user:       mock.GithubPublicKeyUser,
+			key:        "0123456789ABCDEF",
+			signeeType: release.GithubSigneeType,
+			expectErr:  true,
+		},
+		{
+			name:       "Github with unknown key",
+			user:       mock.GithubPublicKeyUser,
+			key:        "FEDCBA9876543210",
+			signeeType: release.GithubSigneeType,
+			expectErr:  true,
+		},
+		{
+			name:       "Github with unknown user",
+			user:       "nobody",
+			key:        mock.GithubPublicKeyID,
+			signeeType: release.GithubSigneeType,
+			expectErr:  true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -39,4 +73,214 @@ func TestNewSignee(t *testing.T) {
 			assert.Nil(t, err, tc.name)
 		}
 	}
+
+	// A signee can use its own client
+	s := release.NewGithubSignee(release.NewGithubClient(release.GithubEndpoint(server.URL)), mock.GithubPublicKeyUser, mock.GithubPublicKeyID)
+	_, err := s.PublicKey()
+	assert.Nil(t, err)
+}
+
+func TestGithubSigneeSubkeys(t *testing.T) {
+	kp, err := pgp.NewSignerWithAlgorithm("Bob the Builder", "I build stuff", "bob@builder.com", pgp.Ed25519, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 0, pgp.DefaultConfig)
+	assert.Nil(t, err)
+	signature, err := pgp.Sign(kp.PrivateKey, mock.Signed, pgp.DefaultConfig)
+	assert.Nil(t, err)
+
+	keyring, err := pgp.ReadKeyRing(kp.PublicKey)
+	assert.Nil(t, err)
+	primary := fmt.Sprintf("%x", keyring[0].PrimaryKey.Fingerprint)
+	subkey := fmt.Sprintf("%x", keyring[0].Subkeys[len(keyring[0].Subkeys)-1].PublicKey.Fingerprint)
+
+	keys := []release.GPGKey{
+		{
+			KeyID:      strings.ToUpper(primary[24:]),
+			RawKey:     string(kp.PublicKey),
+			CanSign:    true,
+			CanCertify: true,
+			Emails:     []release.GPGEmail{{Email: "bob@builder.com", Verified: true}},
+			Subkeys: []release.GPGKey{
+				{
+					KeyID:           "1111111111111111",
+					CanEncryptComms: true,
+				},
+				{
+					KeyID:     "2222222222222222",
+					CanSign:   true,
+					ExpiresAt: time.Now().Add(-time.Hour),
+				},
+				{
+					KeyID:   strings.ToUpper(subkey[24:]),
+					CanSign: true,
+				},
+			},
+		},
+	}
+	server := githubServer(keys, nil)
+	defer server.Close()
+	client := release.NewGithubClient(release.GithubEndpoint(server.URL))
+
+	testCases := []struct {
+		name      string
+		key       string
+		expectErr bool
+	}{
+		{
+			name: "Subkey ID",
+			key:  strings.ToUpper(subkey[24:]),
+		},
+		{
+			name: "Subkey fingerprint",
+			key:  subkey,
+		},
+		{
+			name: "Primary key fingerprint",
+			key:  "0x" + strings.ToUpper(primary),
+		},
+		{
+			name:      "Fingerprint with colliding key ID",
+			key:       strings.Repeat("0", 24) + subkey[24:],
+			expectErr: true,
+		},
+		{
+			name:      "Subkey that can't sign",
+			key:       "1111111111111111",
+			expectErr: true,
+		},
+		{
+			name:      "Expired subkey",
+			key:       "2222222222222222",
+			expectErr: true,
+		},
+		{
+			name:      "Short key ID",
+			key:       subkey[32:],
+			expectErr: true,
+		},
+		{
+			name:      "Not a key ID",
+			key:       "not a key ID",
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		s := release.NewGithubSignee(client, mock.GithubPublicKeyUser, tc.key)
+		_, err := verifier.VerifySignature(s, mock.Signed, signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+	}
+}
+
+func TestLocalSignees(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signee")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "bob.asc")
+	err = ioutil.WriteFile(keyFile, mock.SignerPub, 0600)
+	assert.Nil(t, err)
+
+	keyring := filepath.Join(dir, "keyring")
+	err = os.Mkdir(keyring, 0700)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(filepath.Join(keyring, "keys.asc"), append(append([]byte{}, mock.AltSignerPub...), mock.SignerPub...), 0600)
+	assert.Nil(t, err)
+
+	// gpg keeps more than keys in its home directory
+	err = ioutil.WriteFile(filepath.Join(keyring, "pubring.kbx"), []byte("\x00\x00\x00\x20\x01\x01\x00\x02KBXf"), 0600)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(filepath.Join(keyring, "trustdb.gpg"), []byte("\x01gpg\x03\x03\x01\x05\x01\x00\x00\x00"), 0600)
+	assert.Nil(t, err)
+	err = os.Mkdir(filepath.Join(keyring, "private-keys-v1.d"), 0700)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		signee    release.Signee
+		expectErr bool
+	}{
+		{
+			name:   "Key file",
+			signee: release.NewSignee("bob", keyFile, release.FileSigneeType),
+		},
+		{
+			name:      "Missing key file",
+			signee:    release.NewSignee("bob", filepath.Join(dir, "missing.asc"), release.FileSigneeType),
+			expectErr: true,
+		},
+		{
+			name:   "Keyring with key ID",
+			signee: release.NewKeyringSignee(keyring, "bob", mock.SignerKeyID),
+		},
+		{
+			name:   "Keyring with fingerprint",
+			signee: release.NewKeyringSignee(keyring, "bob", strings.ToUpper(mock.SignerFingerPrint)),
+		},
+		{
+			name:      "Keyring without key",
+			signee:    release.NewKeyringSignee(keyring, "bob", "0000000000000000"),
+			expectErr: true,
+		},
+		{
+			name:      "Missing default keyring",
+			signee:    release.NewSignee("bob", mock.SignerKeyID, release.KeyringSigneeType),
+			expectErr: true,
+		},
+		{
+			name:   "Embedded key",
+			signee: release.NewEmbeddedSignee("bob", mock.SignerFingerPrint, mock.SignerPub),
+		},
+		{
+			name:      "Embedded key with wrong fingerprint",
+			signee:    release.NewEmbeddedSignee("bob", mock.SignerFingerPrint, mock.AltSignerPub),
+			expectErr: true,
+		},
+		{
+			name:      "Pinned without embedded key",
+			signee:    release.NewSignee("bob", mock.SignerFingerPrint, release.PinnedSigneeType),
+			expectErr: true,
+		},
+		{
+			name:   "Pinned key file",
+			signee: release.NewPinnedSignee(release.NewSignee("bob", keyFile, release.FileSigneeType), mock.SignerFingerPrint),
+		},
+		{
+			name:      "Pinned keyring with several keys",
+			signee:    release.NewPinnedSignee(release.NewSignee("bob", filepath.Join(keyring, "keys.asc"), release.FileSigneeType), mock.SignerFingerPrint),
+			expectErr: true,
+		},
+		{
+			name:      "Pinned signee with another key",
+			signee:    release.NewPinnedSignee(mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.AltSignerPub, nil), mock.SignerFingerPrint),
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		identities, err := verifier.VerifySignature(tc.signee, mock.Signed, mock.Signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, identities, tc.name)
+	}
+}
+
+func TestPinnedSignee(t *testing.T) {
+	s := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.AltSignerPub, nil)
+	pinned := release.NewPinnedSignee(s, mock.SignerFingerPrint)
+	assert.Equal(t, s.User(), pinned.User())
+	assert.Equal(t, s.Key(), pinned.Key())
+	assert.Equal(t, s.Type(), pinned.Type())
+
+	_, err := pinned.PublicKey()
+	assert.Equal(t, release.ErrFingerprintMismatch, errors.Cause(err))
 }
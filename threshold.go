@@ -0,0 +1,175 @@
+package release
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/keyless"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
+
+// ThresholdVerifier provides the interface required for verifying
+// that a release has been signed by enough of a set of trusted
+// signees, i.e., an M-of-N signature policy
+type ThresholdVerifier interface {
+	// VerifySignatures asserts that at least the threshold number of
+	// trusted signees have produced a valid signature for the signed
+	// data. The result contains the signees that were found valid,
+	// also when the threshold is not met.
+	VerifySignatures(signed []byte, signatures [][]byte) (*ThresholdResult, error)
+}
+
+// ThresholdResult contains the outcome of verifying
+// a set of signatures against a threshold
+type ThresholdResult struct {
+	Threshold int
+	Valid     []ValidSignature
+}
+
+// ValidSignature contains a trusted signee that
+// produced a valid signature and the identities
+// found in its key
+type ValidSignature struct {
+	Signee     Signee
+	Identities []string
+}
+
+var (
+	// ErrThresholdNotMet indicates that too few of the trusted
+	// signees produced a valid signature
+	ErrThresholdNotMet = errors.New("signature threshold not met")
+
+	// ErrInvalidThreshold indicates that the threshold can
+	// never be met by the trusted signees
+	ErrInvalidThreshold = errors.New("invalid signature threshold")
+
+	// ErrDuplicateSignee indicates that a signee was listed more
+	// than once, which could let it count towards a threshold twice
+	ErrDuplicateSignee = errors.New("duplicate signee")
+)
+
+type thresholdVerifier struct {
+	verifier  Verifier
+	threshold int
+	signees   []Signee
+}
+
+// NewThresholdVerifier creates a verifier that requires valid
+// signatures from at least threshold of the provided signees
+func NewThresholdVerifier(verifier Verifier, threshold int, signee Signee, signees ...Signee) ThresholdVerifier {
+	return &thresholdVerifier{
+		verifier:  verifier,
+		threshold: threshold,
+		signees:   append([]Signee{signee}, signees...),
+	}
+}
+
+// VerifySignatures using the provided input
+func (tv *thresholdVerifier) VerifySignatures(signed []byte, signatures [][]byte) (*ThresholdResult, error) {
+	if tv.threshold < 1 || tv.threshold > len(tv.signees) {
+		return nil, errors.Wrapf(ErrInvalidThreshold, "need: %d, trusted signees: %d", tv.threshold, len(tv.signees))
+	}
+
+	err := uniqueSignees(tv.signees)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ThresholdResult{
+		Threshold: tv.threshold,
+		Valid: countSignatures(tv.signees, signatures, func(signee Signee, signature []byte) ([]string, error) {
+			return tv.verifier.VerifySignature(signee, signed, signature)
+		}),
+	}
+
+	if len(result.Valid) < tv.threshold {
+		return result, errors.Wrapf(ErrThresholdNotMet, "got: %d, need: %d", len(result.Valid), tv.threshold)
+	}
+
+	return result, nil
+}
+
+// uniqueSignees returns an error if a signee is listed twice
+func uniqueSignees(signees []Signee) error {
+	seen := map[string]struct{}{}
+	for _, signee := range signees {
+		id := fmt.Sprintf("%s/%s/%s", signee.Type(), signee.User(), signee.Key())
+		if _, ok := seen[id]; ok {
+			return errors.Wrapf(ErrDuplicateSignee, "%s signee: %s, key: %s", signee.Type(), signee.User(), signee.Key())
+		}
+		seen[id] = struct{}{}
+	}
+	return nil
+}
+
+// countSignatures returns the signees that produced a valid signature. Each
+// signature and each signing key is only counted once, so the same signature
+// passed twice, or two signees sharing a key, can't meet a threshold together
+func countSignatures(signees []Signee, signatures [][]byte, verify func(Signee, []byte) ([]string, error)) []ValidSignature {
+	var valid []ValidSignature
+	usedSignatures := map[[sha256.Size]byte]struct{}{}
+	usedKeys := map[string]struct{}{}
+	for _, signee := range signees {
+		for _, signature := range signatures {
+			sum := sha256.Sum256(signature)
+			if _, ok := usedSignatures[sum]; ok {
+				continue
+			}
+			identities, err := verify(signee, signature)
+			if err != nil {
+				continue
+			}
+			key := signingKey(signee, signature)
+			if _, ok := usedKeys[key]; ok {
+				continue
+			}
+			usedSignatures[sum] = struct{}{}
+			usedKeys[key] = struct{}{}
+			valid = append(valid, ValidSignature{
+				Signee:     signee,
+				Identities: identities,
+			})
+			break
+		}
+	}
+	return valid
+}
+
+// signingKey identifies the key of the signee by its primary fingerprint,
+// or the digest of the key if it isn't a pgp key. A keyless signature is
+// identified by the identity of its certificate, as the signee has no key
+func signingKey(signee Signee, signature []byte) string {
+	if signee.Type() == KeylessSigneeType {
+		if identity, ok := certificateIdentity(signature); ok {
+			return identity
+		}
+	}
+	publicKey, err := signee.PublicKey()
+	if err != nil || len(publicKey) == 0 {
+		return fmt.Sprintf("signee:%s/%s/%s", signee.Type(), signee.User(), signee.Key())
+	}
+	fingerprint, err := pgp.Fingerprint(publicKey)
+	if err == nil {
+		return "pgp:" + fingerprint
+	}
+	sum := sha256.Sum256(publicKey)
+	return "key:" + hex.EncodeToString(sum[:])
+}
+
+// certificateIdentity returns the subjects and issuer of the
+// signing certificate of a keyless signature bundle
+func certificateIdentity(signature []byte) (string, bool) {
+	bundle, err := keyless.ReadBundle(signature)
+	if err != nil || len(bundle.CertificateChain) == 0 {
+		return "", false
+	}
+	cert, err := x509.ParseCertificate(bundle.CertificateChain[0])
+	if err != nil {
+		return "", false
+	}
+	subjects, issuer := keyless.CertificateIdentity(cert)
+	return fmt.Sprintf("keyless:%q/%q", subjects, issuer), true
+}
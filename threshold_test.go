@@ -0,0 +1,133 @@
+package release_test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/keyless"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdVerifier(t *testing.T) {
+	bob := mock.Signee(mock.SignerKeyID, "bob", release.GithubSigneeType, mock.SignerPub, nil)
+	alice := mock.Signee("", "alice", release.GithubSigneeType, mock.AltSignerPub, nil)
+	eve := mock.Signee("", "eve", release.GithubSigneeType, nil, fmt.Errorf("no key"))
+	carol := mock.Signee(mock.SignerKeyID, "carol", release.GithubSigneeType, mock.SignerPub, nil)
+
+	testCases := []struct {
+		name       string
+		threshold  int
+		signees    []release.Signee
+		signatures [][]byte
+		expectUser []string
+		expectErr  error
+	}{
+		{
+			name:       "Two of three",
+			threshold:  2,
+			signees:    []release.Signee{bob, alice, eve},
+			signatures: [][]byte{mock.AltSignature, mock.Signature},
+			expectUser: []string{"bob", "alice"},
+		},
+		{
+			name:       "Duplicate signature counted once",
+			threshold:  2,
+			signees:    []release.Signee{bob, alice, eve},
+			signatures: [][]byte{mock.Signature, mock.Signature},
+			expectUser: []string{"bob"},
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "One key for two signees",
+			threshold:  2,
+			signees:    []release.Signee{bob, carol},
+			signatures: [][]byte{mock.Signature, mock.Signature},
+			expectUser: []string{"bob"},
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "Duplicate signee",
+			threshold:  2,
+			signees:    []release.Signee{bob, bob},
+			signatures: [][]byte{mock.Signature, mock.Signature},
+			expectErr:  release.ErrDuplicateSignee,
+		},
+		{
+			name:       "Untrusted signature",
+			threshold:  1,
+			signees:    []release.Signee{alice},
+			signatures: [][]byte{mock.Signature},
+			expectErr:  release.ErrThresholdNotMet,
+		},
+		{
+			name:       "Threshold larger than signees",
+			threshold:  3,
+			signees:    []release.Signee{bob, alice},
+			signatures: [][]byte{mock.Signature, mock.AltSignature},
+			expectErr:  release.ErrInvalidThreshold,
+		},
+	}
+
+	for _, tc := range testCases {
+		v := release.NewThresholdVerifier(release.NewVerifier(pgp.DefaultConfig), tc.threshold, tc.signees[0], tc.signees[1:]...)
+		got, err := v.VerifySignatures(mock.Signed, tc.signatures)
+		if tc.expectErr != nil {
+			assert.Equal(t, tc.expectErr, errors.Cause(err), tc.name)
+		} else {
+			assert.Nil(t, err, tc.name)
+		}
+		if got != nil {
+			var users []string
+			for _, v := range got.Valid {
+				users = append(users, v.Signee.User())
+			}
+			assert.Equal(t, tc.expectUser, users, tc.name)
+		}
+	}
+}
+
+// acceptingVerifier accepts every signature of every signee
+type acceptingVerifier struct {
+	release.Verifier
+}
+
+func (v *acceptingVerifier) VerifySignature(_ release.Signee, _ []byte, _ []byte) ([]string, error) {
+	return nil, nil
+}
+
+func TestKeylessThreshold(t *testing.T) {
+	ca, root, err := keyless.NewLocalCA()
+	assert.Nil(t, err)
+	log, logKey, err := keyless.NewLocalLog()
+	assert.Nil(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	identity := keyless.Identity{Subject: "bob@builder.com", Issuer: "https://accounts.example.com"}
+	signer := release.NewKeylessSigner(ca, log, identity)
+	first, err := signer.Sign(nil, mock.Signed)
+	assert.Nil(t, err)
+	second, err := signer.Sign(nil, mock.Signed)
+	assert.Nil(t, err)
+
+	bob := release.NewKeylessSignee(identity.Subject, identity.Issuer)
+	alice := release.NewKeylessSignee("alice@example.com", identity.Issuer)
+	verifier := release.NewKeylessVerifier(keyless.Policy{Roots: roots, LogPublicKey: logKey})
+
+	// Bob's bundles aren't alice's
+	result, err := release.NewThresholdVerifier(verifier, 2, bob, alice).VerifySignatures(mock.Signed, [][]byte{first, second})
+	assert.Equal(t, release.ErrThresholdNotMet, errors.Cause(err))
+	if assert.Len(t, result.Valid, 1) {
+		assert.Equal(t, bob, result.Valid[0].Signee)
+	}
+
+	// Nor does a certificate identity count twice, even if it is accepted for both
+	result, err = release.NewThresholdVerifier(&acceptingVerifier{}, 2, bob, alice).VerifySignatures(mock.Signed, [][]byte{first, second})
+	assert.Equal(t, release.ErrThresholdNotMet, errors.Cause(err))
+	assert.Len(t, result.Valid, 1)
+}
@@ -0,0 +1,241 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF identifies the key derivation function
+// used for deriving a key from a passphrase
+type KDF byte
+
+// nolint
+const (
+	KDFScrypt   KDF = 1
+	KDFArgon2id KDF = 2
+)
+
+// Params contains the key derivation function and its
+// parameters, they are stored in the header of a sealed
+// message so they can be changed without breaking
+// existing messages
+type Params struct {
+	KDF KDF
+
+	// Scrypt parameters
+	N uint32
+	R uint32
+	P uint32
+
+	// Argon2id parameters, memory is in KiB
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// ScryptParams returns the parameters for deriving a key with scrypt
+func ScryptParams(n, r, p uint32) Params {
+	return Params{KDF: KDFScrypt, N: n, R: r, P: p}
+}
+
+// Argon2idParams returns the parameters for deriving a key with Argon2id
+func Argon2idParams(time, memory uint32, threads uint8) Params {
+	return Params{KDF: KDFArgon2id, Time: time, Memory: memory, Threads: threads}
+}
+
+// nolint
+var (
+	// DefaultParams are the scrypt parameters used
+	// before the header was introduced
+	DefaultParams = ScryptParams(1048576, 8, 1)
+
+	// DefaultArgon2idParams are the parameters recommended by:
+	// https://tools.ietf.org/html/rfc9106#section-4
+	DefaultArgon2idParams = Argon2idParams(3, 64*1024, 4)
+)
+
+// nolint
+var (
+	ErrInvalidParams      = errors.New("invalid key derivation parameters")
+	ErrUnsupportedVersion = errors.New("unsupported vault format version")
+)
+
+const (
+	version0 byte = 0
+	version1 byte = 1
+	version2 byte = 2
+	version3 byte = 3
+
+	// The parameters are read from untrusted headers, so they
+	// are capped to keep deriving a key to at most 1 GiB of
+	// memory and a bounded amount of work, the defaults are
+	// within the caps
+	maxMemory       = 1024 * 1024 * 1024
+	maxScryptN      = 1 << 20
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxArgon2idTime = 10
+
+	// A message may carry several passphrase stanzas, which are
+	// each tried when opening it, so their number and the total
+	// cost of deriving their keys are capped as well, the total
+	// allows the most expensive single stanza
+	maxPassphraseStanzas = 4
+	maxKDFCost           = maxMemory * maxScryptP
+)
+
+// magic prefixes every message that has a header, messages
+// sealed before the header was introduced start with the salt
+var magic = []byte("SVLT")
+
+// Validate returns an error if the parameters can't be used
+func (p Params) Validate() error {
+	switch p.KDF {
+	case KDFScrypt:
+		if p.N < 2 || p.N&(p.N-1) != 0 || p.R == 0 || p.P == 0 {
+			return ErrInvalidParams
+		}
+		if p.N > maxScryptN || p.R > maxScryptR || p.P > maxScryptP {
+			return ErrInvalidParams
+		}
+		if uint64(p.N)*uint64(p.R)*128 > maxMemory {
+			return ErrInvalidParams
+		}
+	case KDFArgon2id:
+		if p.Time == 0 || p.Threads == 0 || p.Memory < 8*uint32(p.Threads) {
+			return ErrInvalidParams
+		}
+		if p.Time > maxArgon2idTime {
+			return ErrInvalidParams
+		}
+		if uint64(p.Memory)*1024 > maxMemory {
+			return ErrInvalidParams
+		}
+	default:
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// cost returns the memory in bytes times the number of passes
+// over it, a rough measure of the work of deriving a key
+func (p Params) cost() uint64 {
+	switch p.KDF {
+	case KDFScrypt:
+		return uint64(p.N) * uint64(p.R) * 128 * uint64(p.P)
+	case KDFArgon2id:
+		return uint64(p.Memory) * 1024 * uint64(p.Time)
+	default:
+		return 0
+	}
+}
+
+func (p Params) derive(pass, salt []byte) ([]byte, error) {
+	switch p.KDF {
+	case KDFScrypt:
+		return scrypt.Key(pass, salt, int(p.N), int(p.R), int(p.P), keySize)
+	case KDFArgon2id:
+		return argon2.IDKey(pass, salt, p.Time, p.Memory, p.Threads, keySize), nil
+	default:
+		return nil, ErrInvalidParams
+	}
+}
+
+// encode writes the KDF and its parameters
+func (p Params) encode(buf *bytes.Buffer) {
+	buf.WriteByte(byte(p.KDF))
+	switch p.KDF {
+	case KDFScrypt:
+		binary.Write(buf, binary.BigEndian, [3]uint32{p.N, p.R, p.P}) // nolint: errcheck, gosec
+	case KDFArgon2id:
+		binary.Write(buf, binary.BigEndian, [2]uint32{p.Time, p.Memory}) // nolint: errcheck, gosec
+		buf.WriteByte(p.Threads)
+	}
+}
+
+// decodeParams reads the KDF and its parameters
+func decodeParams(r *bytes.Reader) (Params, error) {
+	kdf, err := r.ReadByte()
+	if err != nil {
+		return Params{}, ErrDecrypt
+	}
+
+	p := Params{KDF: KDF(kdf)}
+	switch p.KDF {
+	case KDFScrypt:
+		var values [3]uint32
+		err = binary.Read(r, binary.BigEndian, &values)
+		p.N, p.R, p.P = values[0], values[1], values[2]
+	case KDFArgon2id:
+		var values [2]uint32
+		err = binary.Read(r, binary.BigEndian, &values)
+		p.Time, p.Memory = values[0], values[1]
+		if err == nil {
+			p.Threads, err = r.ReadByte()
+		}
+	default:
+		return Params{}, ErrInvalidParams
+	}
+	if err != nil {
+		return Params{}, ErrDecrypt
+	}
+	if p.Validate() != nil {
+		return Params{}, ErrInvalidParams
+	}
+	return p, nil
+}
+
+// marshal encodes the header: magic, version, KDF and its parameters
+func (p Params) marshal() []byte {
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(version1)
+	p.encode(&buf)
+	return buf.Bytes()
+}
+
+// version returns the format version of the message and the
+// remainder after the version, messages without a header
+// are version 0
+func version(message []byte) (byte, []byte, error) {
+	if !bytes.HasPrefix(message, magic) {
+		return version0, message, nil
+	}
+	if len(message) == len(magic) {
+		return 0, nil, ErrDecrypt
+	}
+	return message[len(magic)], message[len(magic)+1:], nil
+}
+
+// unmarshal decodes the header and returns the remainder of
+// the message, messages without a header get the legacy parameters
+func unmarshal(message []byte) (Params, []byte, error) {
+	v, rest, err := version(message)
+	if err != nil {
+		return Params{}, nil, err
+	}
+	switch v {
+	case version0:
+		return DefaultParams, message, nil
+	case version1:
+		r := bytes.NewReader(rest)
+		p, err := decodeParams(r)
+		if err != nil {
+			return Params{}, nil, err
+		}
+		return p, rest[len(rest)-r.Len():], nil
+	default:
+		return Params{}, nil, ErrUnsupportedVersion
+	}
+}
+
+// ReadParams returns the key derivation parameters a
+// message sealed with a single passphrase was sealed with
+func ReadParams(message []byte) (Params, error) {
+	p, _, err := unmarshal(message)
+	return p, err
+}
@@ -0,0 +1,580 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// A message sealed for recipients is encrypted with a random data key,
+// which is wrapped for each recipient in a stanza, similar to:
+// https://age-encryption.org/v1
+//
+// The format is: magic, version, number of stanzas, the stanzas
+// and the nonce and ciphertext of the message. Recipients can be
+// added and removed by changing the stanzas only, while the
+// message itself is left untouched. Streams encrypted for recipients
+// use the same header, followed by the stream instead of the message.
+
+// nolint
+var (
+	ErrNoRecipients       = errors.New("no recipients")
+	ErrTooManyRecipients  = errors.New("too many recipients")
+	ErrRecipientNotFound  = errors.New("recipient not found")
+	ErrLastRecipient      = errors.New("can't remove the last recipient")
+	ErrInvalidX25519Key   = errors.New("invalid x25519 key")
+	ErrNoMatchingIdentity = errors.New("no identity matches a recipient")
+	ErrTooManyPassphrases = errors.New("too many passphrase recipients")
+	ErrKDFCostTooHigh     = errors.New("passphrase key derivation too expensive")
+)
+
+const (
+	stanzaPassphrase byte = 1
+	stanzaX25519     byte = 2
+
+	x25519Info = "stoic-release/vault/x25519"
+)
+
+type stanza struct {
+	kind byte
+	body []byte
+}
+
+// Recipient can wrap the data key of a message
+type Recipient interface {
+	wrap(dataKey *memguard.LockedBuffer) (*stanza, error)
+	matches(s *stanza) bool
+}
+
+// Identity can unwrap the data key of a message
+type Identity interface {
+	unwrap(s *stanza) (*memguard.LockedBuffer, error)
+}
+
+// Passphrase is both a recipient and an identity, the
+// parameters are only used when wrapping a data key
+type Passphrase struct {
+	pass   *memguard.LockedBuffer
+	params Params
+}
+
+// NewPassphrase creates a passphrase recipient and identity
+func NewPassphrase(pass *memguard.LockedBuffer, params Params) *Passphrase {
+	return &Passphrase{
+		pass:   pass,
+		params: params,
+	}
+}
+
+func (p *Passphrase) wrap(dataKey *memguard.LockedBuffer) (*stanza, error) {
+	err := p.params.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := memguard.NewImmutableRandom(saltSize)
+	if salt != nil {
+		defer salt.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	key, err := deriveKey(p.pass, salt, p.params)
+	if key != nil {
+		defer key.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	wrapped, err := encrypt(key, dataKey)
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	var body bytes.Buffer
+	p.params.encode(&body)
+	body.Write(salt.Buffer())
+	body.Write(wrapped)
+	return &stanza{kind: stanzaPassphrase, body: body.Bytes()}, nil
+}
+
+func (p *Passphrase) unwrap(s *stanza) (*memguard.LockedBuffer, error) {
+	if s.kind != stanzaPassphrase {
+		return nil, ErrDecrypt
+	}
+
+	r := bytes.NewReader(s.body)
+	params, err := decodeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	rest := s.body[len(s.body)-r.Len():]
+	if len(rest) < overhead {
+		return nil, ErrDecrypt
+	}
+
+	salt, err := memguard.NewImmutableFromBytes(append([]byte{}, rest[:saltSize]...))
+	if salt != nil {
+		defer salt.Destroy()
+	}
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	key, err := deriveKey(p.pass, salt, params)
+	if key != nil {
+		defer key.Destroy()
+	}
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return decrypt(key, rest[saltSize:])
+}
+
+func (p *Passphrase) matches(s *stanza) bool {
+	dataKey, err := p.unwrap(s)
+	if err != nil {
+		return false
+	}
+	dataKey.Destroy()
+	return true
+}
+
+// X25519Recipient wraps the data key for the
+// holder of the X25519 private key
+type X25519Recipient struct {
+	publicKey [curve25519.PointSize]byte
+}
+
+// NewX25519Recipient creates a recipient from a public key
+func NewX25519Recipient(publicKey []byte) (*X25519Recipient, error) {
+	if len(publicKey) != curve25519.PointSize {
+		return nil, ErrInvalidX25519Key
+	}
+	r := &X25519Recipient{}
+	copy(r.publicKey[:], publicKey)
+	return r, nil
+}
+
+// PublicKey returns the public key of the recipient
+func (r *X25519Recipient) PublicKey() []byte {
+	return append([]byte{}, r.publicKey[:]...)
+}
+
+// The stanza contains: the public key of the recipient, so the
+// stanza can be found when removing the recipient, the ephemeral
+// public key and the wrapped data key
+func (r *X25519Recipient) wrap(dataKey *memguard.LockedBuffer) (*stanza, error) {
+	ephemeral, err := memguard.NewImmutableRandom(curve25519.ScalarSize)
+	if ephemeral != nil {
+		defer ephemeral.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	ephemeralPublic, err := curve25519.X25519(ephemeral.Buffer(), curve25519.Basepoint)
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	shared, err := curve25519.X25519(ephemeral.Buffer(), r.publicKey[:])
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+	defer memguard.WipeBytes(shared)
+
+	key, err := x25519WrapKey(shared, ephemeralPublic, r.publicKey[:])
+	if key != nil {
+		defer key.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	wrapped, err := encrypt(key, dataKey)
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	var body bytes.Buffer
+	body.Write(r.publicKey[:])
+	body.Write(ephemeralPublic)
+	body.Write(wrapped)
+	return &stanza{kind: stanzaX25519, body: body.Bytes()}, nil
+}
+
+func (r *X25519Recipient) matches(s *stanza) bool {
+	return s.kind == stanzaX25519 && bytes.HasPrefix(s.body, r.publicKey[:])
+}
+
+// X25519Identity unwraps data keys wrapped for its recipient
+type X25519Identity struct {
+	privateKey *memguard.LockedBuffer
+	recipient  *X25519Recipient
+}
+
+// GenerateX25519Identity creates a new random identity
+func GenerateX25519Identity() (*X25519Identity, error) {
+	privateKey, err := memguard.NewImmutableRandom(curve25519.ScalarSize)
+	if err != nil {
+		if privateKey != nil {
+			privateKey.Destroy()
+		}
+		return nil, ErrEncrypt
+	}
+	return NewX25519Identity(privateKey)
+}
+
+// NewX25519Identity creates an identity from a private key
+func NewX25519Identity(privateKey *memguard.LockedBuffer) (*X25519Identity, error) {
+	if privateKey.Size() != curve25519.ScalarSize {
+		return nil, ErrInvalidX25519Key
+	}
+	publicKey, err := curve25519.X25519(privateKey.Buffer(), curve25519.Basepoint)
+	if err != nil {
+		return nil, ErrInvalidX25519Key
+	}
+	recipient, err := NewX25519Recipient(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{
+		privateKey: privateKey,
+		recipient:  recipient,
+	}, nil
+}
+
+// PrivateKey returns the private key of the identity
+func (i *X25519Identity) PrivateKey() *memguard.LockedBuffer {
+	return i.privateKey
+}
+
+// Recipient returns the recipient of the identity
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return i.recipient
+}
+
+func (i *X25519Identity) unwrap(s *stanza) (*memguard.LockedBuffer, error) {
+	if !i.recipient.matches(s) {
+		return nil, ErrDecrypt
+	}
+	body := s.body[curve25519.PointSize:]
+	if len(body) < curve25519.PointSize {
+		return nil, ErrDecrypt
+	}
+	ephemeralPublic := body[:curve25519.PointSize]
+
+	shared, err := curve25519.X25519(i.privateKey.Buffer(), ephemeralPublic)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer memguard.WipeBytes(shared)
+
+	key, err := x25519WrapKey(shared, ephemeralPublic, i.recipient.publicKey[:])
+	if key != nil {
+		defer key.Destroy()
+	}
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return decrypt(key, body[curve25519.PointSize:])
+}
+
+// x25519WrapKey derives the key used for wrapping the data key from
+// the shared secret, bound to the ephemeral and recipient public keys
+func x25519WrapKey(shared, ephemeralPublic, recipientPublic []byte) (*memguard.LockedBuffer, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+	key := make([]byte, keySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), key)
+	if err != nil {
+		return nil, err
+	}
+	return memguard.NewImmutableFromBytes(key) // This also wipes the key slice
+}
+
+// SealFor encrypts the message with a random data key,
+// which is wrapped for each of the recipients
+func SealFor(message *memguard.LockedBuffer, recipients ...Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	dataKey, err := memguard.NewImmutableRandom(keySize)
+	if dataKey != nil {
+		defer dataKey.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	var stanzas []*stanza
+	for _, recipient := range recipients {
+		s, err := recipient.wrap(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, s)
+	}
+
+	payload, err := encrypt(dataKey, message)
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	return marshalStanzas(version2, stanzas, payload)
+}
+
+// OpenWith decrypts a message sealed for recipients using the first
+// identity that can unwrap the data key, a passphrase can also
+// open messages sealed with a single passphrase
+func OpenWith(message []byte, identities ...Identity) (*memguard.LockedBuffer, error) {
+	v, _, err := version(message)
+	if err != nil {
+		return nil, err
+	}
+	var stanzas []*stanza
+	var payload []byte
+	switch v {
+	case version2:
+		_, stanzas, payload, err = unmarshalStanzas(message)
+	case version3:
+		err = ErrUnsupportedVersion
+	}
+	// Messages sealed with a single passphrase, including legacy
+	// messages whose salt starts with the magic, are opened by Open
+	if v != version2 || err != nil {
+		for _, identity := range identities {
+			if p, ok := identity.(*Passphrase); ok {
+				return Open(p.pass, message)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNoMatchingIdentity
+	}
+
+	dataKey, err := unwrap(stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+	defer dataKey.Destroy()
+
+	return decrypt(dataKey, payload)
+}
+
+// AddRecipient wraps the data key for another recipient, the identity
+// must be able to unwrap the data key of the message, this works for
+// both sealed messages and encrypted streams
+func AddRecipient(message []byte, identity Identity, recipient Recipient) ([]byte, error) {
+	v, stanzas, payload, err := unmarshalStanzas(message)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := unwrap(stanzas, []Identity{identity})
+	if err != nil {
+		return nil, err
+	}
+	defer dataKey.Destroy()
+
+	// Make sure the data key belongs to the payload before handing it out
+	err = authenticate(v, dataKey, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := recipient.wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return marshalStanzas(v, append(stanzas, s), payload)
+}
+
+// RemoveRecipient removes the stanzas of the recipient, note that the
+// recipient might have kept the data key, use Rekey to prevent it from
+// opening future versions of the message
+func RemoveRecipient(message []byte, recipient Recipient) ([]byte, error) {
+	v, stanzas, payload, err := unmarshalStanzas(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []*stanza
+	for _, s := range stanzas {
+		if !recipient.matches(s) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == len(stanzas) {
+		return nil, ErrRecipientNotFound
+	}
+	if len(kept) == 0 {
+		return nil, ErrLastRecipient
+	}
+	return marshalStanzas(v, kept, payload)
+}
+
+// Rekey opens the message with the identity and seals it with a
+// new data key for the recipients, it can also be used to move
+// a message sealed with a single passphrase to recipients
+func Rekey(message []byte, identity Identity, recipients ...Recipient) ([]byte, error) {
+	opened, err := OpenWith(message, identity)
+	if err != nil {
+		return nil, err
+	}
+	defer opened.Destroy()
+
+	return SealFor(opened, recipients...)
+}
+
+// authenticate checks that the payload was encrypted with the data key
+func authenticate(v byte, dataKey *memguard.LockedBuffer, payload []byte) error {
+	if v == version3 {
+		r, err := NewStreamReader(dataKey, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(ioutil.Discard, r)
+		return err
+	}
+
+	opened, err := decrypt(dataKey, payload)
+	if err != nil {
+		return ErrDecrypt
+	}
+	opened.Destroy()
+	return nil
+}
+
+func unwrap(stanzas []*stanza, identities []Identity) (*memguard.LockedBuffer, error) {
+	for _, identity := range identities {
+		for _, s := range stanzas {
+			dataKey, err := identity.unwrap(s)
+			if err == nil {
+				return dataKey, nil
+			}
+		}
+	}
+	return nil, ErrNoMatchingIdentity
+}
+
+func marshalStanzas(v byte, stanzas []*stanza, payload []byte) ([]byte, error) {
+	header, err := marshalHeader(v, stanzas)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, payload...), nil
+}
+
+// marshalHeader encodes the magic, version and stanzas
+func marshalHeader(v byte, stanzas []*stanza) ([]byte, error) {
+	err := checkPassphrases(stanzas)
+	if err != nil {
+		return nil, err
+	}
+	if len(stanzas) > 0xffff {
+		return nil, ErrTooManyRecipients
+	}
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(v)
+	binary.Write(&buf, binary.BigEndian, uint16(len(stanzas))) // nolint: errcheck, gosec
+	for _, s := range stanzas {
+		if len(s.body) > 0xffff {
+			return nil, ErrEncrypt
+		}
+		buf.WriteByte(s.kind)
+		binary.Write(&buf, binary.BigEndian, uint16(len(s.body))) // nolint: errcheck, gosec
+		buf.Write(s.body)
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalStanzas(message []byte) (byte, []*stanza, []byte, error) {
+	v, rest, err := version(message)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if v != version2 && v != version3 {
+		return 0, nil, nil, ErrUnsupportedVersion
+	}
+
+	r := bytes.NewReader(rest)
+	stanzas, err := readStanzas(r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return v, stanzas, rest[len(rest)-r.Len():], nil
+}
+
+// readStanzas reads the number of stanzas and the stanzas
+func readStanzas(r io.Reader) ([]*stanza, error) {
+	var count uint16
+	err := binary.Read(r, binary.BigEndian, &count)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var stanzas []*stanza
+	for i := 0; i < int(count); i++ {
+		var header struct {
+			Kind   byte
+			Length uint16
+		}
+		err = binary.Read(r, binary.BigEndian, &header)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		body := make([]byte, header.Length)
+		_, err = io.ReadFull(r, body)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		stanzas = append(stanzas, &stanza{kind: header.Kind, body: body})
+	}
+	err = checkPassphrases(stanzas)
+	if err != nil {
+		return nil, err
+	}
+	return stanzas, nil
+}
+
+// checkPassphrases caps the number of passphrase stanzas and the
+// total cost of deriving their keys, so a crafted header can't
+// make opening the message derive key after key
+func checkPassphrases(stanzas []*stanza) error {
+	var count int
+	var cost uint64
+	for _, s := range stanzas {
+		if s.kind != stanzaPassphrase {
+			continue
+		}
+		count++
+		if count > maxPassphraseStanzas {
+			return ErrTooManyPassphrases
+		}
+		params, err := decodeParams(bytes.NewReader(s.body))
+		if err != nil {
+			return err
+		}
+		cost += params.cost()
+		if cost > maxKDFCost {
+			return ErrKDFCostTooHigh
+		}
+	}
+	return nil
+}
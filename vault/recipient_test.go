@@ -0,0 +1,166 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipients(t *testing.T) {
+	params := Argon2idParams(1, 1024, 1)
+	alice := NewPassphrase(LockedBuffer(t, "alice"), params)
+	bob, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+	carol, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+	mallory, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	sealed, err := SealFor(LockedBuffer(t, "release key"), alice, bob.Recipient())
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		message   func() []byte
+		open      []Identity
+		expectErr error
+	}{
+		{
+			name:    "Passphrase",
+			message: func() []byte { return sealed },
+			open:    []Identity{alice},
+		},
+		{
+			name:    "X25519",
+			message: func() []byte { return sealed },
+			open:    []Identity{bob},
+		},
+		{
+			name:      "Wrong passphrase",
+			message:   func() []byte { return sealed },
+			open:      []Identity{NewPassphrase(LockedBuffer(t, "eve"), params)},
+			expectErr: ErrNoMatchingIdentity,
+		},
+		{
+			name:      "Wrong X25519",
+			message:   func() []byte { return sealed },
+			open:      []Identity{mallory},
+			expectErr: ErrNoMatchingIdentity,
+		},
+		{
+			name: "Added recipient",
+			message: func() []byte {
+				added, err := AddRecipient(sealed, bob, carol.Recipient())
+				assert.Nil(t, err)
+				return added
+			},
+			open: []Identity{mallory, carol},
+		},
+		{
+			name: "Removed recipient",
+			message: func() []byte {
+				removed, err := RemoveRecipient(sealed, bob.Recipient())
+				assert.Nil(t, err)
+				return removed
+			},
+			open:      []Identity{bob},
+			expectErr: ErrNoMatchingIdentity,
+		},
+		{
+			name: "Rotated passphrase",
+			message: func() []byte {
+				added, err := AddRecipient(sealed, alice, NewPassphrase(LockedBuffer(t, "new"), params))
+				assert.Nil(t, err)
+				rotated, err := RemoveRecipient(added, alice)
+				assert.Nil(t, err)
+				_, err = OpenWith(rotated, alice)
+				assert.Equal(t, ErrNoMatchingIdentity, err)
+				return rotated
+			},
+			open: []Identity{NewPassphrase(LockedBuffer(t, "new"), Params{})},
+		},
+		{
+			name: "Rekeyed",
+			message: func() []byte {
+				rekeyed, err := Rekey(sealed, bob, NewPassphrase(LockedBuffer(t, "new"), params))
+				assert.Nil(t, err)
+				return rekeyed
+			},
+			open: []Identity{NewPassphrase(LockedBuffer(t, "new"), Params{})},
+		},
+		{
+			name: "Rekeyed single passphrase",
+			message: func() []byte {
+				single, err := SealWithParams(LockedBuffer(t, "alice"), LockedBuffer(t, "release key"), params)
+				assert.Nil(t, err)
+				rekeyed, err := Rekey(single, alice, carol.Recipient())
+				assert.Nil(t, err)
+				return rekeyed
+			},
+			open: []Identity{carol},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := OpenWith(tc.message(), tc.open...)
+			if tc.expectErr != nil {
+				assert.Equal(t, tc.expectErr, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, "release key", string(got.Buffer()))
+			}
+		})
+	}
+
+	got, err := Open(LockedBuffer(t, "alice"), sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, "release key", string(got.Buffer()))
+
+	_, err = AddRecipient(sealed, mallory, mallory.Recipient())
+	assert.Equal(t, ErrNoMatchingIdentity, err)
+
+	_, err = RemoveRecipient(sealed, mallory.Recipient())
+	assert.Equal(t, ErrRecipientNotFound, err)
+
+	only, err := RemoveRecipient(sealed, alice)
+	assert.Nil(t, err)
+	_, err = RemoveRecipient(only, bob.Recipient())
+	assert.Equal(t, ErrLastRecipient, err)
+
+	_, err = SealFor(LockedBuffer(t, "release key"))
+	assert.Equal(t, ErrNoRecipients, err)
+}
+
+func TestPassphraseCaps(t *testing.T) {
+	params := Argon2idParams(1, 1024, 1)
+	bob, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	recipients := []Recipient{bob.Recipient()}
+	for i := 0; i < maxPassphraseStanzas; i++ {
+		recipients = append(recipients, NewPassphrase(LockedBuffer(t, "alice"), params))
+	}
+	sealed, err := SealFor(LockedBuffer(t, "release key"), recipients...)
+	assert.Nil(t, err)
+	_, err = AddRecipient(sealed, bob, NewPassphrase(LockedBuffer(t, "eve"), params))
+	assert.Equal(t, ErrTooManyPassphrases, err)
+
+	// Stanzas are checked before any key is derived
+	expensive := ScryptParams(maxScryptN, maxScryptR/4, maxScryptP)
+	var body bytes.Buffer
+	expensive.encode(&body)
+	body.Write(make([]byte, saltSize+overhead))
+	_, stanzas, payload, err := unmarshalStanzas(sealed)
+	assert.Nil(t, err)
+	header, err := marshalHeader(version2, stanzas[:1])
+	assert.Nil(t, err)
+	header[len(magic)+2] = 3
+	for i := 0; i < 2; i++ {
+		header = append(header, stanzaPassphrase, byte(body.Len()>>8), byte(body.Len()))
+		header = append(header, body.Bytes()...)
+	}
+	_, err = OpenWith(append(header, payload...), bob)
+	assert.Equal(t, ErrKDFCostTooHigh, err)
+}
@@ -0,0 +1,353 @@
+package vault
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Implements the STREAM construction with XChaCha20-Poly1305, see:
+// https://eprint.iacr.org/2015/189.pdf
+//
+// The stream starts with a random nonce prefix, followed by chunks
+// of at most StreamChunkSize bytes of plaintext that are sealed
+// individually. The nonce of each chunk is the prefix, a counter
+// and a flag marking the last chunk, which makes it impossible to
+// reorder or drop chunks, or to truncate the stream, unnoticed.
+
+// StreamChunkSize is the size of the plaintext in a chunk
+const StreamChunkSize = 64 * 1024
+
+const (
+	streamPrefixSize = chacha20poly1305.NonceSizeX - 5
+	streamLastChunk  = 1
+)
+
+// nolint
+var (
+	ErrStreamTruncated = errors.New("stream truncated")
+	ErrStreamTooLarge  = errors.New("stream too large")
+	ErrStreamClosed    = errors.New("stream closed")
+)
+
+// GenerateKey returns a random key for use with a stream
+func GenerateKey() (*memguard.LockedBuffer, error) {
+	key, err := memguard.NewImmutableRandom(keySize)
+	if err != nil {
+		if key != nil {
+			key.Destroy()
+		}
+		return nil, ErrEncrypt
+	}
+	return key, nil
+}
+
+// newAEAD creates the cipher for a single chunk, so the only
+// long lived copy of the key is the one in guarded memory
+func newAEAD(key *memguard.LockedBuffer) (cipher.AEAD, error) {
+	if key.Size() != keySize {
+		return nil, ErrEncrypt
+	}
+	return chacha20poly1305.NewX(key.Buffer())
+}
+
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = streamLastChunk
+	}
+	return nonce
+}
+
+type streamWriter struct {
+	key     *memguard.LockedBuffer
+	w       io.Writer
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewStreamWriter returns a writer that encrypts everything written to
+// it with the key, the stream must be closed to write the last chunk,
+// closing it doesn't close the underlying writer
+func NewStreamWriter(key *memguard.LockedBuffer, w io.Writer) (io.WriteCloser, error) {
+	prefix, err := memguard.NewImmutableRandom(streamPrefixSize)
+	if prefix != nil {
+		defer prefix.Destroy()
+	}
+	if err != nil {
+		return nil, ErrEncrypt
+	}
+
+	s := &streamWriter{
+		key:    key,
+		w:      w,
+		prefix: append([]byte{}, prefix.Buffer()...),
+		buf:    make([]byte, 0, StreamChunkSize),
+	}
+	_, err = w.Write(s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write buffers the data and seals every complete chunk, a full chunk
+// is only sealed once more data arrives, since it might be the last
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == StreamChunkSize {
+			err := s.flush(false)
+			if err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return ErrStreamClosed
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *streamWriter) flush(last bool) error {
+	if !last && s.counter == ^uint32(0) {
+		return ErrStreamTooLarge
+	}
+
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return ErrEncrypt
+	}
+	out := aead.Seal(nil, streamNonce(s.prefix, s.counter, last), s.buf, nil)
+	memguard.WipeBytes(s.buf)
+	s.buf = s.buf[:0]
+	s.counter++
+
+	_, err = s.w.Write(out)
+	return err
+}
+
+type streamReader struct {
+	key     *memguard.LockedBuffer
+	r       *bufio.Reader
+	prefix  []byte
+	counter uint32
+	chunk   []byte
+	buf     []byte
+	done    bool
+	err     error
+}
+
+// NewStreamReader returns a reader that decrypts a stream written by
+// NewStreamWriter, an error is returned if the stream has been
+// tampered with or truncated
+func NewStreamReader(key *memguard.LockedBuffer, r io.Reader) (io.Reader, error) {
+	s := &streamReader{
+		key:    key,
+		r:      bufio.NewReaderSize(r, StreamChunkSize+chacha20poly1305.Overhead),
+		prefix: make([]byte, streamPrefixSize),
+		chunk:  make([]byte, StreamChunkSize+chacha20poly1305.Overhead),
+	}
+	_, err := io.ReadFull(s.r, s.prefix)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrStreamTruncated
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Read returns the decrypted content of the stream
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// next opens the next chunk, a chunk is the last one if
+// it is short or if it is followed by the end of the stream
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	last := false
+	switch err {
+	case nil:
+		_, err = s.r.Peek(1)
+		if err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return ErrStreamTruncated
+	default:
+		return err
+	}
+
+	if n < chacha20poly1305.Overhead {
+		return ErrStreamTruncated
+	}
+	if !last && s.counter == ^uint32(0) {
+		return ErrStreamTooLarge
+	}
+
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return ErrDecrypt
+	}
+	out, err := aead.Open(nil, streamNonce(s.prefix, s.counter, last), s.chunk[:n], nil)
+	if err != nil {
+		// A chunk that opens without the flag means
+		// the stream was cut at a chunk boundary
+		if _, e := aead.Open(nil, streamNonce(s.prefix, s.counter, false), s.chunk[:n], nil); last && e == nil {
+			return ErrStreamTruncated
+		}
+		return ErrDecrypt
+	}
+	// Only an empty stream ends with an empty chunk
+	if last && len(out) == 0 && s.counter > 0 {
+		return ErrDecrypt
+	}
+
+	s.buf = out
+	s.counter++
+	s.done = last
+	return nil
+}
+
+type recipientWriter struct {
+	io.WriteCloser
+	dataKey *memguard.LockedBuffer
+}
+
+// Close closes the stream and destroys the data key
+func (w *recipientWriter) Close() error {
+	defer w.dataKey.Destroy()
+	return w.WriteCloser.Close()
+}
+
+// EncryptFor returns a writer that encrypts everything written to it
+// as a stream with a random data key, which is wrapped for each of
+// the recipients like SealFor does
+func EncryptFor(w io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	dataKey, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var stanzas []*stanza
+	for _, recipient := range recipients {
+		s, err := recipient.wrap(dataKey)
+		if err != nil {
+			dataKey.Destroy()
+			return nil, err
+		}
+		stanzas = append(stanzas, s)
+	}
+
+	header, err := marshalHeader(version3, stanzas)
+	if err == nil {
+		_, err = w.Write(header)
+	}
+	if err != nil {
+		dataKey.Destroy()
+		return nil, err
+	}
+
+	stream, err := NewStreamWriter(dataKey, w)
+	if err != nil {
+		dataKey.Destroy()
+		return nil, err
+	}
+	return &recipientWriter{
+		WriteCloser: stream,
+		dataKey:     dataKey,
+	}, nil
+}
+
+type recipientReader struct {
+	io.Reader
+	dataKey *memguard.LockedBuffer
+}
+
+// Close destroys the data key
+func (r *recipientReader) Close() error {
+	r.dataKey.Destroy()
+	return nil
+}
+
+// DecryptWith returns a reader of a stream written by EncryptFor,
+// using the first identity that can unwrap the data key. The reader
+// must be closed when done, which destroys the data key
+func DecryptWith(r io.Reader, identities ...Identity) (io.ReadCloser, error) {
+	header := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, ErrStreamTruncated
+	}
+	v, _, err := version(header)
+	if err != nil {
+		return nil, err
+	}
+	if v != version3 {
+		return nil, ErrUnsupportedVersion
+	}
+
+	stanzas, err := readStanzas(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := unwrap(stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := NewStreamReader(dataKey, r)
+	if err != nil {
+		dataKey.Destroy()
+		return nil, err
+	}
+	return &recipientReader{
+		Reader:  stream,
+		dataKey: dataKey,
+	}, nil
+}
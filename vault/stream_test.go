@@ -0,0 +1,196 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestStream(t *testing.T) {
+	key, err := GenerateKey()
+	assert.Nil(t, err)
+	otherKey, err := GenerateKey()
+	assert.Nil(t, err)
+
+	seal := func(size int) ([]byte, []byte) {
+		plain := make([]byte, size)
+		_, err := rand.Read(plain)
+		assert.Nil(t, err)
+
+		var buf bytes.Buffer
+		w, err := NewStreamWriter(key, &buf)
+		assert.Nil(t, err)
+		// Write in odd sizes to cross the chunk boundaries
+		for p := plain; len(p) > 0; {
+			n := 1000
+			if n > len(p) {
+				n = len(p)
+			}
+			_, err = w.Write(p[:n])
+			assert.Nil(t, err)
+			p = p[n:]
+		}
+		assert.Nil(t, w.Close())
+		_, err = w.Write([]byte("more"))
+		assert.Equal(t, ErrStreamClosed, err)
+		return plain, buf.Bytes()
+	}
+
+	encChunk := StreamChunkSize + chacha20poly1305.Overhead
+
+	testCases := []struct {
+		name      string
+		size      int
+		tamper    func(sealed []byte) []byte
+		key       bool
+		expectErr error
+	}{
+		{
+			name: "Empty",
+			size: 0,
+		},
+		{
+			name: "Single chunk",
+			size: 100,
+		},
+		{
+			name: "Exact chunk",
+			size: StreamChunkSize,
+		},
+		{
+			name: "Multiple chunks",
+			size: 3*StreamChunkSize + 17,
+		},
+		{
+			name: "Wrong key",
+			size: 100,
+			key:  true,
+			tamper: func(sealed []byte) []byte {
+				return sealed
+			},
+			expectErr: ErrDecrypt,
+		},
+		{
+			name: "Truncated at chunk boundary",
+			size: 3*StreamChunkSize + 17,
+			tamper: func(sealed []byte) []byte {
+				return sealed[:streamPrefixSize+2*encChunk]
+			},
+			expectErr: ErrStreamTruncated,
+		},
+		{
+			name: "Truncated in chunk",
+			size: 3*StreamChunkSize + 17,
+			tamper: func(sealed []byte) []byte {
+				return sealed[:streamPrefixSize+encChunk+100]
+			},
+			expectErr: ErrDecrypt,
+		},
+		{
+			name: "Truncated prefix",
+			size: 100,
+			tamper: func(sealed []byte) []byte {
+				return sealed[:streamPrefixSize-1]
+			},
+			expectErr: ErrStreamTruncated,
+		},
+		{
+			name: "Missing last chunk",
+			size: 0,
+			tamper: func(sealed []byte) []byte {
+				return sealed[:streamPrefixSize]
+			},
+			expectErr: ErrStreamTruncated,
+		},
+		{
+			name: "Reordered chunks",
+			size: 3*StreamChunkSize + 17,
+			tamper: func(sealed []byte) []byte {
+				first := streamPrefixSize
+				second := first + encChunk
+				var out []byte
+				out = append(out, sealed[:first]...)
+				out = append(out, sealed[second:second+encChunk]...)
+				out = append(out, sealed[first:second]...)
+				return append(out, sealed[second+encChunk:]...)
+			},
+			expectErr: ErrDecrypt,
+		},
+		{
+			name: "Modified chunk",
+			size: 100,
+			tamper: func(sealed []byte) []byte {
+				sealed[streamPrefixSize+10] ^= 1
+				return sealed
+			},
+			expectErr: ErrDecrypt,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plain, sealed := seal(tc.size)
+			if tc.tamper != nil {
+				sealed = tc.tamper(sealed)
+			}
+			k := key
+			if tc.key {
+				k = otherKey
+			}
+
+			r, err := NewStreamReader(k, bytes.NewReader(sealed))
+			var got []byte
+			if err == nil {
+				got, err = ioutil.ReadAll(r)
+			}
+			if tc.expectErr != nil {
+				assert.Equal(t, tc.expectErr, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, len(plain), len(got))
+				assert.True(t, bytes.Equal(plain, got))
+				_, err = r.Read(make([]byte, 1))
+				assert.Equal(t, io.EOF, err)
+			}
+		})
+	}
+}
+
+func TestEncryptFor(t *testing.T) {
+	alice, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+	bob, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	plain := make([]byte, 2*StreamChunkSize+5)
+	_, err = rand.Read(plain)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	w, err := EncryptFor(&buf, alice.Recipient())
+	assert.Nil(t, err)
+	_, err = w.Write(plain)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	_, err = DecryptWith(bytes.NewReader(buf.Bytes()), bob)
+	assert.Equal(t, ErrNoMatchingIdentity, err)
+
+	added, err := AddRecipient(buf.Bytes(), alice, bob.Recipient())
+	assert.Nil(t, err)
+
+	r, err := DecryptWith(bytes.NewReader(added), bob)
+	assert.Nil(t, err)
+	got, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(plain, got))
+	assert.Nil(t, r.Close())
+
+	_, err = OpenWith(added, bob)
+	assert.Equal(t, ErrUnsupportedVersion, err)
+}
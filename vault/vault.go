@@ -1,22 +1,21 @@
 package vault
 
 import (
+	"bytes"
 	"errors"
 	"unsafe"
 
 	"github.com/awnumar/memguard"
 	"golang.org/x/crypto/nacl/secretbox"
-	"golang.org/x/crypto/scrypt"
 )
 
 // Based on the code from: https://leanpub.com/gocrypto/read
 // and modified to use mempage.LockedBuffer
 
 const (
-	keySize    = 32
-	nonceSize  = 24
-	saltSize   = 32
-	iterations = 1048576
+	keySize   = 32
+	nonceSize = 24
+	saltSize  = 32
 )
 
 // nolint
@@ -73,7 +72,7 @@ func decrypt(key *memguard.LockedBuffer, message []byte) (*memguard.LockedBuffer
 	return guarded, nil
 }
 
-func deriveKey(pass, salt *memguard.LockedBuffer) (*memguard.LockedBuffer, error) {
+func deriveKey(pass, salt *memguard.LockedBuffer, params Params) (*memguard.LockedBuffer, error) {
 	// scrypt will segfault due to the memory
 	// guards provided by memguard unless we
 	// create a copy here
@@ -83,8 +82,11 @@ func deriveKey(pass, salt *memguard.LockedBuffer) (*memguard.LockedBuffer, error
 		return nil, ErrCopy
 	}
 
-	key, _ := scrypt.Key(passBuff, salt.Buffer(), iterations, 8, 1, keySize)
+	key, err := params.derive(passBuff, salt.Buffer())
 	memguard.WipeBytes(passBuff)
+	if err != nil {
+		return nil, err
+	}
 
 	return memguard.NewImmutableFromBytes(key) // This also wipes the key slice
 }
@@ -92,6 +94,17 @@ func deriveKey(pass, salt *memguard.LockedBuffer) (*memguard.LockedBuffer, error
 // Seal encrypts the provided message using NaCl, we accept a LockedBuffer
 // because we expect the object to be sealed should be kept as safe as possible
 func Seal(pass *memguard.LockedBuffer, message *memguard.LockedBuffer) ([]byte, error) {
+	return SealWithParams(pass, message, DefaultParams)
+}
+
+// SealWithParams encrypts the provided message like Seal, deriving
+// the key with the provided parameters, which are stored in the header
+func SealWithParams(pass *memguard.LockedBuffer, message *memguard.LockedBuffer, params Params) ([]byte, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	salt, err := memguard.NewImmutableRandom(saltSize)
 	if salt != nil {
 		defer salt.Destroy()
@@ -100,7 +113,7 @@ func Seal(pass *memguard.LockedBuffer, message *memguard.LockedBuffer) ([]byte,
 		return nil, ErrEncrypt
 	}
 
-	key, err := deriveKey(pass, salt)
+	key, err := deriveKey(pass, salt, params)
 	if key != nil {
 		defer key.Destroy()
 	}
@@ -113,7 +126,7 @@ func Seal(pass *memguard.LockedBuffer, message *memguard.LockedBuffer) ([]byte,
 		return nil, ErrEncrypt
 	}
 
-	out = append(salt.Buffer(), out...)
+	out = append(append(params.marshal(), salt.Buffer()...), out...)
 	return out, nil
 }
 
@@ -121,13 +134,47 @@ const overhead = saltSize + secretbox.Overhead + nonceSize
 
 // Open decrypts the provided message using NaCl, we return a LockedBuffer
 // because we expect anything that is stored in an encrypted fashion
-// should be kept as secure as possible
+// should be kept as secure as possible. Messages sealed before the
+// header was introduced are opened with the legacy parameters and
+// messages sealed for recipients are opened as a passphrase recipient
 func Open(pass *memguard.LockedBuffer, message []byte) (*memguard.LockedBuffer, error) {
+	if v, _, err := version(message); err == nil && v == version2 {
+		_, _, _, err = unmarshalStanzas(message)
+		if err != nil {
+			return openLegacy(pass, message, err)
+		}
+		return OpenWith(message, NewPassphrase(pass, Params{}))
+	}
+
+	params, rest, err := unmarshal(message)
+	if err != nil {
+		return openLegacy(pass, message, err)
+	}
+	return openParams(pass, params, rest)
+}
+
+// openLegacy opens a message whose header can't be parsed as a
+// legacy message, since the random salt of a legacy message can
+// start with the magic, the header error is returned if that fails
+func openLegacy(pass *memguard.LockedBuffer, message []byte, headerErr error) (*memguard.LockedBuffer, error) {
+	if !bytes.HasPrefix(message, magic) {
+		return nil, headerErr
+	}
+	out, err := openParams(pass, DefaultParams, message)
+	if err != nil {
+		return nil, headerErr
+	}
+	return out, nil
+}
+
+// openParams decrypts the salt and ciphertext of a message
+// using a key derived with the provided parameters
+func openParams(pass *memguard.LockedBuffer, params Params, message []byte) (*memguard.LockedBuffer, error) {
 	if len(message) < overhead {
 		return nil, ErrDecrypt
 	}
 
-	salt, err := memguard.NewImmutableFromBytes(message[:saltSize])
+	salt, err := memguard.NewImmutableFromBytes(append([]byte{}, message[:saltSize]...))
 	if salt != nil {
 		defer salt.Destroy()
 	}
@@ -135,7 +182,7 @@ func Open(pass *memguard.LockedBuffer, message []byte) (*memguard.LockedBuffer,
 		return nil, ErrDecrypt
 	}
 
-	key, err := deriveKey(pass, salt)
+	key, err := deriveKey(pass, salt, params)
 	if key != nil {
 		defer key.Destroy()
 	}
@@ -151,3 +198,17 @@ func Open(pass *memguard.LockedBuffer, message []byte) (*memguard.LockedBuffer,
 
 	return out, nil
 }
+
+// Reseal opens the message and seals it again using the provided
+// parameters, e.g., to upgrade a legacy message or to make the
+// key derivation cheaper or more expensive. The result is always
+// sealed with the single passphrase, use Rekey for recipients
+func Reseal(pass *memguard.LockedBuffer, message []byte, params Params) ([]byte, error) {
+	opened, err := Open(pass, message)
+	if err != nil {
+		return nil, err
+	}
+	defer opened.Destroy()
+
+	return SealWithParams(pass, opened, params)
+}
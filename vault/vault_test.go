@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"crypto/rand"
 	"testing"
 
 	"github.com/awnumar/memguard"
@@ -47,3 +48,142 @@ func TestVault(t *testing.T) {
 		}
 	}
 }
+
+func TestVaultParams(t *testing.T) {
+	cheapScrypt := ScryptParams(1024, 8, 1)
+	cheapArgon2id := Argon2idParams(1, 1024, 1)
+
+	legacy, err := Seal(LockedBuffer(t, "secret1"), LockedBuffer(t, "legacy message"))
+	assert.Nil(t, err)
+	legacy = legacy[len(DefaultParams.marshal()):]
+
+	testCases := []struct {
+		name      string
+		message   func() []byte
+		expect    Params
+		expectErr error
+	}{
+		{
+			name: "Scrypt",
+			message: func() []byte {
+				sealed, err := SealWithParams(LockedBuffer(t, "secret1"), LockedBuffer(t, "message"), cheapScrypt)
+				assert.Nil(t, err)
+				return sealed
+			},
+			expect: cheapScrypt,
+		},
+		{
+			name: "Argon2id",
+			message: func() []byte {
+				sealed, err := SealWithParams(LockedBuffer(t, "secret1"), LockedBuffer(t, "message"), cheapArgon2id)
+				assert.Nil(t, err)
+				return sealed
+			},
+			expect: cheapArgon2id,
+		},
+		{
+			name: "Legacy",
+			message: func() []byte {
+				return legacy
+			},
+			expect: DefaultParams,
+		},
+		{
+			name: "Resealed legacy",
+			message: func() []byte {
+				sealed, err := Reseal(LockedBuffer(t, "secret1"), legacy, cheapArgon2id)
+				assert.Nil(t, err)
+				return sealed
+			},
+			expect: cheapArgon2id,
+		},
+		{
+			name: "Unsupported version",
+			message: func() []byte {
+				sealed, err := SealWithParams(LockedBuffer(t, "secret1"), LockedBuffer(t, "message"), cheapScrypt)
+				assert.Nil(t, err)
+				sealed[len(magic)] = 99
+				return sealed
+			},
+			expectErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "Invalid params",
+			message: func() []byte {
+				return ScryptParams(1000, 8, 1).marshal()
+			},
+			expectErr: ErrInvalidParams,
+		},
+		{
+			name: "Oversized scrypt params",
+			message: func() []byte {
+				return ScryptParams(1<<20, 8, 1<<20).marshal()
+			},
+			expectErr: ErrInvalidParams,
+		},
+		{
+			name: "Oversized argon2id memory",
+			message: func() []byte {
+				return Argon2idParams(1, 4*1024*1024, 1).marshal()
+			},
+			expectErr: ErrInvalidParams,
+		},
+		{
+			name: "Oversized argon2id time",
+			message: func() []byte {
+				return Argon2idParams(1<<31, 1024, 1).marshal()
+			},
+			expectErr: ErrInvalidParams,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message := tc.message()
+			params, err := ReadParams(message)
+			if tc.expectErr != nil {
+				assert.Equal(t, tc.expectErr, err)
+				_, err = Open(LockedBuffer(t, "secret1"), message)
+				assert.Equal(t, tc.expectErr, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expect, params)
+			got, err := Open(LockedBuffer(t, "secret1"), message)
+			assert.Nil(t, err)
+			assert.NotEmpty(t, got.Buffer())
+		})
+	}
+
+	_, err = SealWithParams(LockedBuffer(t, "secret1"), LockedBuffer(t, "message"), Params{})
+	assert.Equal(t, ErrInvalidParams, err)
+}
+
+func TestLegacySaltWithMagic(t *testing.T) {
+	// Deriving the legacy key is expensive, so only the versions
+	// with a header of their own are covered
+	for _, v := range []byte{version1, version2} {
+		salt := make([]byte, saltSize)
+		_, err := rand.Read(salt)
+		assert.Nil(t, err)
+		copy(salt, magic)
+		salt[len(magic)] = v
+		salt[len(magic)+1] = 0
+
+		key, err := deriveKey(LockedBuffer(t, "secret1"), LockedBuffer(t, string(salt)), DefaultParams)
+		assert.Nil(t, err)
+		out, err := encrypt(key, LockedBuffer(t, "legacy message"))
+		assert.Nil(t, err)
+		legacy := append(salt, out...)
+
+		got, err := Open(LockedBuffer(t, "secret1"), legacy)
+		assert.Nil(t, err, "version %d", v)
+		assert.Equal(t, "legacy message", string(got.Buffer()), "version %d", v)
+
+		if v == version2 {
+			got, err = OpenWith(legacy, NewPassphrase(LockedBuffer(t, "secret1"), Params{}))
+			assert.Nil(t, err)
+			assert.Equal(t, "legacy message", string(got.Buffer()))
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package release
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/keyless"
+	"github.com/stoic-cli/stoic-release/minisign"
 	"github.com/stoic-cli/stoic-release/pgp"
-	"golang.org/x/crypto/openpgp/packet"
 )
 
 // Verifier provides the interface required for verifying
@@ -19,6 +24,13 @@ type Verifier interface {
 	// VerifyDigests asserts that the provided hashes match that of the given
 	// artifact
 	VerifyDigests(digests map[DigestType]string, reader io.Reader) error
+
+	// VerifyArtifacts asserts that the digests of each artifact match
+	// its content and, for artifacts that carry a detached signature,
+	// that the signee's key verifies the signature. Whether an artifact
+	// must carry a signature is recorded in the manifest, which loaders
+	// enforce
+	VerifyArtifacts(signee Signee, artifacts []Artifact) error
 }
 
 // NewVerifier creates a new stand alone verifier
@@ -34,18 +46,51 @@ type verifier struct {
 
 // VerifySignature using the provided input
 func (v *verifier) VerifySignature(signee Signee, signed []byte, signature []byte) ([]string, error) {
-	signeeKey, err := signee.PublicKey()
+	return v.VerifySignatureContext(context.Background(), signee, signed, signature)
+}
+
+// VerifySignatureContext using the provided input
+func (v *verifier) VerifySignatureContext(ctx context.Context, signee Signee, signed []byte, signature []byte) ([]string, error) {
+	signeeKey, err := NewSigneeContext(signee).PublicKeyContext(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch signee's public key")
 	}
 	if v.config == nil {
 		v.config = pgp.DefaultConfig
 	}
-	identities, err := pgp.Verify(signeeKey, signed, signature, v.config)
+	verification, err := pgp.Verify(signeeKey, signed, signature, v.config)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to verify signature")
 	}
-	return identities, nil
+	err = checkIssuer(signee.Key(), verification)
+	if err != nil {
+		return nil, err
+	}
+	return verification.Identities, nil
+}
+
+// ErrWrongSigningKey indicates that the signature was made by
+// another key of the entity than the one of the signee
+var ErrWrongSigningKey = errors.New("signature made by another key than the signee's")
+
+// checkIssuer asserts that a signee whose key is a key ID or fingerprint
+// signed with that key. The public key of the signee is the whole entity,
+// so a signee for a subkey would otherwise accept the primary key and the
+// other subkeys, a signee for the primary key accepts its signing subkeys
+func checkIssuer(key string, verification *pgp.Verification) error {
+	keyID, fingerprint, err := parseKeyID(key)
+	if err != nil {
+		// Not a key ID, e.g., the path of a key file
+		return nil
+	}
+	if fingerprint != "" {
+		if pgp.SameFingerprint(fingerprint, verification.Fingerprint) || pgp.SameFingerprint(fingerprint, verification.KeyFingerprint) {
+			return nil
+		}
+	} else if keyID == fmt.Sprintf("%016x", verification.PrimaryKeyID) || keyID == fmt.Sprintf("%016x", verification.KeyID) {
+		return nil
+	}
+	return errors.Wrapf(ErrWrongSigningKey, "expected: %s, got: %016x", key, verification.KeyID)
 }
 
 var (
@@ -55,6 +100,10 @@ var (
 
 // VerifyDigests using the provided input
 func (v *verifier) VerifyDigests(digests map[DigestType]string, reader io.Reader) error {
+	return verifyDigests(digests, reader)
+}
+
+func verifyDigests(digests map[DigestType]string, reader io.Reader) error {
 	if len(digests) == 0 {
 		return ErrNoDigests
 	}
@@ -89,3 +138,207 @@ func (v *verifier) VerifyDigests(digests map[DigestType]string, reader io.Reader
 
 	return nil
 }
+
+// VerifyArtifacts using the provided input
+func (v *verifier) VerifyArtifacts(signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(context.Background(), v, signee, artifacts)
+}
+
+// VerifyArtifactsContext using the provided input
+func (v *verifier) VerifyArtifactsContext(ctx context.Context, signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(ctx, v, signee, artifacts)
+}
+
+func verifyArtifacts(ctx context.Context, v VerifierContext, signee Signee, artifacts []Artifact) error {
+	for _, artifact := range artifacts {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "failed to verify artifacts")
+		}
+		content, err := ioutil.ReadAll(artifact.Content())
+		if err != nil {
+			return errors.Wrap(err, "failed to read artifact")
+		}
+		err = v.VerifyDigests(artifact.Digests(), bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		if len(artifact.Signature()) == 0 {
+			continue
+		}
+		_, err = v.VerifySignatureContext(ctx, signee, content, artifact.Signature())
+		if err != nil {
+			return errors.Wrap(err, "failed to verify artifact signature")
+		}
+	}
+	return nil
+}
+
+type minisignVerifier struct{}
+
+// NewMinisignVerifier creates a new stand alone verifier for
+// minisign signatures, the verified trusted comment is returned
+// as the identity of a signature
+func NewMinisignVerifier() Verifier {
+	return &minisignVerifier{}
+}
+
+// VerifySignature using the provided input
+func (v *minisignVerifier) VerifySignature(signee Signee, signed []byte, signature []byte) ([]string, error) {
+	return v.VerifySignatureContext(context.Background(), signee, signed, signature)
+}
+
+// VerifySignatureContext using the provided input
+func (v *minisignVerifier) VerifySignatureContext(ctx context.Context, signee Signee, signed []byte, signature []byte) ([]string, error) {
+	signeeKey, err := NewSigneeContext(signee).PublicKeyContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch signee's public key")
+	}
+	sig, err := minisign.Verify(signeeKey, signed, signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify signature")
+	}
+	return []string{sig.TrustedComment}, nil
+}
+
+// VerifyDigests using the provided input
+func (v *minisignVerifier) VerifyDigests(digests map[DigestType]string, reader io.Reader) error {
+	return verifyDigests(digests, reader)
+}
+
+// VerifyArtifacts using the provided input
+func (v *minisignVerifier) VerifyArtifacts(signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(context.Background(), v, signee, artifacts)
+}
+
+// VerifyArtifactsContext using the provided input
+func (v *minisignVerifier) VerifyArtifactsContext(ctx context.Context, signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(ctx, v, signee, artifacts)
+}
+
+type keylessVerifier struct {
+	policy keyless.Policy
+}
+
+// NewKeylessVerifier creates a new stand alone verifier for keyless
+// signature bundles. The certificate must chain to the roots of the
+// policy and its identity must be the subject and issuer of the signee,
+// a keyless signee, the identity of the policy is not used. The subjects
+// of the signing certificate are returned as the identities.
+func NewKeylessVerifier(policy keyless.Policy) Verifier {
+	return &keylessVerifier{
+		policy: policy,
+	}
+}
+
+// VerifySignature using the provided input
+func (v *keylessVerifier) VerifySignature(signee Signee, signed []byte, signature []byte) ([]string, error) {
+	return v.VerifySignatureContext(context.Background(), signee, signed, signature)
+}
+
+// VerifySignatureContext using the provided input, the context
+// is only checked as no key is fetched
+func (v *keylessVerifier) VerifySignatureContext(ctx context.Context, signee Signee, signed []byte, signature []byte) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if signee.Type() != KeylessSigneeType {
+		return nil, fmt.Errorf("%s signee: %s can't make keyless signatures", signee.Type(), signee.User())
+	}
+	bundle, err := keyless.ReadBundle(signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify signature")
+	}
+	policy := v.policy
+	policy.Identity = keyless.Identity{
+		Subject: signee.User(),
+		Issuer:  signee.Key(),
+	}
+	cert, err := keyless.Verify(bundle, signed, policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify signature")
+	}
+	identities, _ := keyless.CertificateIdentity(cert)
+	return identities, nil
+}
+
+// VerifyDigests using the provided input
+func (v *keylessVerifier) VerifyDigests(digests map[DigestType]string, reader io.Reader) error {
+	return verifyDigests(digests, reader)
+}
+
+// VerifyArtifacts using the provided input
+func (v *keylessVerifier) VerifyArtifacts(signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(context.Background(), v, signee, artifacts)
+}
+
+// VerifyArtifactsContext using the provided input
+func (v *keylessVerifier) VerifyArtifactsContext(ctx context.Context, signee Signee, artifacts []Artifact) error {
+	return verifyArtifacts(ctx, v, signee, artifacts)
+}
+
+// ManifestVerifier provides the interface required for verifying
+// the signature of a manifest with the signature scheme that
+// was recorded in it
+type ManifestVerifier interface {
+	// VerifyManifestSignature finds the signee in the manifest and
+	// verifies the signature using the verifier of its scheme
+	VerifyManifestSignature(signee Signee, manifest Manifester, signature []byte) ([]string, error)
+}
+
+type manifestVerifier struct {
+	verifiers map[SignatureScheme]Verifier
+}
+
+// NewManifestVerifier creates a verifier that dispatches to the
+// verifier registered for the signature scheme of a signee. If no
+// verifiers are provided the pgp and minisign defaults are used.
+func NewManifestVerifier(verifiers map[SignatureScheme]Verifier) ManifestVerifier {
+	if len(verifiers) == 0 {
+		verifiers = map[SignatureScheme]Verifier{
+			SignatureSchemePGP:      NewVerifier(pgp.DefaultConfig),
+			SignatureSchemeMinisign: NewMinisignVerifier(),
+		}
+	}
+	return &manifestVerifier{
+		verifiers: verifiers,
+	}
+}
+
+// VerifyManifestSignature using the provided input
+func (mv *manifestVerifier) VerifyManifestSignature(signee Signee, manifest Manifester, signature []byte) ([]string, error) {
+	return mv.VerifyManifestSignatureContext(context.Background(), signee, manifest, signature)
+}
+
+// VerifyManifestSignatureContext using the provided input
+func (mv *manifestVerifier) VerifyManifestSignatureContext(ctx context.Context, signee Signee, manifest Manifester, signature []byte) ([]string, error) {
+	var scheme SignatureScheme
+	found := false
+	for _, s := range manifest.Signees() {
+		if s.User == signee.User() && s.Key == signee.Key() && s.Type == signee.Type() {
+			scheme, found = s.Scheme, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("signee: %s is not listed in the manifest", signee.User())
+	}
+	if len(scheme) == 0 {
+		scheme = SignatureSchemePGP
+	}
+
+	verifier, ok := mv.verifiers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature scheme: %s", scheme)
+	}
+
+	serialised, err := manifest.Serialise()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialise manifest")
+	}
+	signed, err := ioutil.ReadAll(serialised)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifest")
+	}
+
+	return NewVerifierContext(verifier).VerifySignatureContext(ctx, signee, signed, signature)
+}
@@ -1,12 +1,17 @@
 package release_test
 
 import (
+	"crypto/x509"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
 	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/keyless"
 	"github.com/stoic-cli/stoic-release/mock"
 	"github.com/stoic-cli/stoic-release/pgp"
 	"github.com/stretchr/testify/assert"
@@ -32,7 +37,7 @@ func TestVerifySignature(t *testing.T) {
 			signee:    mock.Signee("", "", "", mock.AltSignerPub, nil),
 			signed:    mock.Signed,
 			signature: mock.Signature,
-			expect:    "failed to verify signature: failed to check armored detached signature: openpgp: signature made by unknown entity",
+			expect:    "failed to verify signature: failed to check detached signature: openpgp: signature made by unknown entity",
 			expectErr: true,
 		},
 		{
@@ -110,3 +115,95 @@ func TestVerifyDigests(t *testing.T) {
 		}
 	}
 }
+
+func TestKeylessVerifier(t *testing.T) {
+	ca, root, err := keyless.NewLocalCA()
+	assert.Nil(t, err)
+	log, logKey, err := keyless.NewLocalLog()
+	assert.Nil(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	identity := keyless.Identity{Subject: "bob@builder.com", Issuer: "https://accounts.example.com"}
+	signature, err := release.NewKeylessSigner(ca, log, identity).Sign(nil, mock.Signed)
+	assert.Nil(t, err)
+
+	verifier := release.NewKeylessVerifier(keyless.Policy{Roots: roots, LogPublicKey: logKey})
+	bob := release.NewKeylessSignee(identity.Subject, identity.Issuer)
+	got, err := verifier.VerifySignature(bob, mock.Signed, signature)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob@builder.com"}, got)
+
+	_, err = verifier.VerifySignature(bob, mock.Signed, mock.Signature)
+	assert.Error(t, err)
+
+	// The certificate must have the identity of the signee
+	_, err = verifier.VerifySignature(release.NewKeylessSignee("eve@example.com", identity.Issuer), mock.Signed, signature)
+	assert.Equal(t, keyless.ErrIdentityMismatch, errors.Cause(err))
+	_, err = verifier.VerifySignature(release.NewKeylessSignee(identity.Subject, "https://evil.example.com"), mock.Signed, signature)
+	assert.Equal(t, keyless.ErrIssuerMismatch, errors.Cause(err))
+	_, err = verifier.VerifySignature(mock.ValidSignee(), mock.Signed, signature)
+	assert.Error(t, err)
+}
+
+func TestVerifySigningKey(t *testing.T) {
+	config := *pgp.DefaultConfig
+	config.Algorithm = packet.PubKeyAlgoEdDSA
+
+	kp, err := pgp.NewSigner("Bob the Builder", "I build stuff", "bob@builder.com", &config)
+	assert.Nil(t, err)
+	byPrimary, err := pgp.Sign(kp.PrivateKey, mock.Signed, &config)
+	assert.Nil(t, err)
+
+	kp, err = pgp.AddSigningSubkey(kp.PrivateKey, 24*time.Hour, &config)
+	assert.Nil(t, err)
+	bySubkey, err := pgp.Sign(kp.PrivateKey, mock.Signed, &config)
+	assert.Nil(t, err)
+
+	primary := mock.Signee(fmt.Sprintf("%016x", kp.PublicKeyID), "bob", release.GithubSigneeType, kp.PublicKey, nil)
+	subkey := mock.Signee(fmt.Sprintf("%016X", kp.SigningKeyID), "bob", release.GithubSigneeType, kp.PublicKey, nil)
+	fingerprint := mock.Signee(fmt.Sprintf("%x", kp.PublicKeyFingerPrint), "bob", release.GithubSigneeType, kp.PublicKey, nil)
+
+	testCases := []struct {
+		name      string
+		signee    release.Signee
+		signature []byte
+		expectErr error
+	}{
+		{
+			name:      "Primary key signee and primary key",
+			signee:    primary,
+			signature: byPrimary,
+		},
+		{
+			name:      "Primary key signee and subkey",
+			signee:    primary,
+			signature: bySubkey,
+		},
+		{
+			name:      "Fingerprint signee and subkey",
+			signee:    fingerprint,
+			signature: bySubkey,
+		},
+		{
+			name:      "Subkey signee and subkey",
+			signee:    subkey,
+			signature: bySubkey,
+		},
+		{
+			name:      "Subkey signee and primary key",
+			signee:    subkey,
+			signature: byPrimary,
+			expectErr: release.ErrWrongSigningKey,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := release.NewVerifier(&config).VerifySignature(tc.signee, mock.Signed, tc.signature)
+		if tc.expectErr != nil {
+			assert.Equal(t, tc.expectErr, errors.Cause(err), tc.name)
+		} else {
+			assert.Nil(t, err, tc.name)
+		}
+	}
+}
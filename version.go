@@ -1,6 +1,7 @@
 package release
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/pkg/errors"
@@ -31,6 +32,14 @@ func (pv *providedVersion) Version() (string, error) {
 	return pv.version, nil
 }
 
+// VersionContext returns the provided version
+func (pv *providedVersion) VersionContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return pv.version, nil
+}
+
 type gitVersion struct {
 	repositoryPath string
 	branch         string
@@ -50,6 +59,15 @@ func NewGitHistoryVersion(repositoryPath string, branch string, major int) Versi
 // Version returns the generated version using the
 // git history
 func (gv *gitVersion) Version() (string, error) {
+	return gv.VersionContext(context.Background())
+}
+
+// VersionContext returns the generated version using the git
+// history, walking the history stops once the context is done
+func (gv *gitVersion) VersionContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	r, err := git.PlainOpen(gv.repositoryPath)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to open git repository")
@@ -68,13 +86,16 @@ func (gv *gitVersion) Version() (string, error) {
 	}
 
 	iter, err := r.Log(&git.LogOptions{})
-	defer iter.Close()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to fetch the git log")
 	}
+	defer iter.Close()
 
 	var minor, patch int
 	counterFn := func(commit *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		parentCount := 0
 		parents := commit.Parents()
 		defer parents.Close()
@@ -89,6 +110,11 @@ func (gv *gitVersion) Version() (string, error) {
 		}
 		return nil
 	}
-	_ = iter.ForEach(counterFn)
+	// Other errors, such as parents missing from a shallow
+	// clone, end the walk with the commits counted so far
+	err = iter.ForEach(counterFn)
+	if err != nil && err == ctx.Err() {
+		return "", err
+	}
 	return fmt.Sprintf("v%d.%d.%d", gv.major, minor, patch), nil
 }
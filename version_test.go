@@ -1,11 +1,16 @@
 package release
 
 import (
+	"context"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 func TestNewProvidedVersion(t *testing.T) {
@@ -59,3 +64,43 @@ func TestNewGitVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestGitVersionMissingParent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	r, err := git.PlainInit(dir, false)
+	assert.Nil(t, err)
+	w, err := r.Worktree()
+	assert.Nil(t, err)
+
+	var first string
+	for i, content := range []string{"first", "second", "third"} {
+		err = ioutil.WriteFile(filepath.Join(dir, "file"), []byte(content), 0644)
+		assert.Nil(t, err)
+		_, err = w.Add("file")
+		assert.Nil(t, err)
+		hash, err := w.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "bob", Email: "bob@builder.com", When: time.Now()},
+		})
+		assert.Nil(t, err)
+		if i == 0 {
+			first = hash.String()
+		}
+	}
+
+	// Like a shallow clone, the history ends with a missing parent,
+	// only the commit whose parent is present is counted
+	err = os.Remove(filepath.Join(dir, ".git", "objects", first[:2], first[2:]))
+	assert.Nil(t, err)
+
+	got, err := NewGitHistoryVersion(dir, "master", 1).Version()
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.1", got)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewGitHistoryVersion(dir, "master", 1).(*gitVersion).VersionContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,150 @@
+package release
+
+import (
+	"context"
+	"crypto/sha1" // nolint: gosec
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stoic-cli/stoic-release/pgp"
+)
+
+// zbase32 is the alphabet of the z-base-32 encoding, see:
+// https://philzimmermann.com/docs/human-oriented-base-32-encoding.txt
+const zbase32 = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// WKDURL returns the url of the keys of the email address in the web
+// key directory, using either the advanced or the direct method, see:
+// https://datatracker.ietf.org/doc/html/draft-koch-openpgp-webkey-service#section-3.1
+func WKDURL(email string, advanced bool) (string, error) {
+	i := strings.LastIndex(email, "@")
+	if i < 1 || i == len(email)-1 {
+		return "", fmt.Errorf("invalid email address: %s", email)
+	}
+	local, domain := email[:i], strings.ToLower(email[i+1:])
+
+	digest := sha1.Sum([]byte(strings.ToLower(local))) // nolint: gosec
+	hash := zbase32Encode(digest[:])
+
+	if advanced {
+		return fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s?l=%s", domain, domain, hash, url.QueryEscape(local)), nil
+	}
+	return fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s?l=%s", domain, hash, url.QueryEscape(local)), nil
+}
+
+func zbase32Encode(data []byte) string {
+	var out []byte
+	var buffer, bits uint
+	for _, b := range data {
+		buffer = buffer<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out = append(out, zbase32[buffer>>bits&31])
+		}
+	}
+	if bits > 0 {
+		out = append(out, zbase32[buffer<<(5-bits)&31])
+	}
+	return string(out)
+}
+
+// WKDOption configures how the keys of a wkd signee are fetched
+type WKDOption func(*wkdClient)
+
+// WKDHTTPClient sets the http client used for the
+// requests, by default DefaultHTTPClient is used
+func WKDHTTPClient(client *http.Client) WKDOption {
+	return func(c *wkdClient) {
+		c.client = client
+	}
+}
+
+type wkdClient struct {
+	fetcher
+}
+
+// NewWKDSignee fetches the keys of the signee, an email address,
+// from the web key directory of its domain with the provided options
+func NewWKDSignee(user string, key string, options ...WKDOption) Signee {
+	c := &wkdClient{}
+	for _, option := range options {
+		option(c)
+	}
+	return &signee{
+		user:       user,
+		key:        key,
+		signeeType: WKDSigneeType,
+		wkd:        c,
+	}
+}
+
+// wkdPublicKey fetches the keys of the user, an email address, from
+// the web key directory of its domain. The advanced method is tried
+// first, the direct method only if the advanced host can't be reached
+func (s *signee) wkdPublicKey(ctx context.Context) ([]byte, error) {
+	c := s.wkd
+	if c == nil {
+		c = &wkdClient{}
+	}
+
+	advanced, err := WKDURL(s.user, true)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := c.fetch(ctx, advanced, "application/octet-stream")
+	if err != nil {
+		if _, ok := errors.Cause(err).(*HTTPError); !ok {
+			direct, _ := WKDURL(s.user, false)
+			keys, err = c.fetch(ctx, direct, "application/octet-stream")
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get wkd keys of: %s", s.user)
+	}
+
+	return s.selectKey(keys)
+}
+
+// selectKey returns the key of the signee from the keys of the user,
+// the key may be left out if there is only one. The key must have an
+// identity with the email of the user, if the user is an email
+func (s *signee) selectKey(keys []byte) ([]byte, error) {
+	keyring, err := pgp.ReadKeyRing(keys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read keys of: %s", s.user)
+	}
+
+	var publicKey []byte
+	switch {
+	case s.key != "":
+		publicKey, err = pgp.FindKey(keyring, s.key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "gpg key: %s", s.key)
+		}
+	case len(keyring) == 1:
+		publicKey, err = pgp.FindKey(keyring, fmt.Sprintf("%x", keyring[0].PrimaryKey.Fingerprint))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("found %d keys of: %s, the key must be given", len(keyring), s.user)
+	}
+
+	if !strings.Contains(s.user, "@") {
+		return publicKey, nil
+	}
+	emails, err := pgp.Emails(publicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read gpg key: %s", s.key)
+	}
+	for _, email := range emails {
+		if strings.EqualFold(email, s.user) {
+			return publicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("no identity for: %s in its gpg key", s.user)
+}
@@ -0,0 +1,144 @@
+package release_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stoic-cli/stoic-release"
+	"github.com/stoic-cli/stoic-release/mock"
+	"github.com/stoic-cli/stoic-release/pgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// localClient sends every request to the server, except
+// for those to the unreachable hosts
+func localClient(server *httptest.Server, unreachable ...string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(addr)
+				for _, u := range unreachable {
+					if host == u {
+						return nil, fmt.Errorf("no such host: %s", host)
+					}
+				}
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // nolint: gosec
+		},
+	}
+}
+
+func TestWKDURL(t *testing.T) {
+	// The example from the draft
+	got, err := release.WKDURL("Joe.Doe@Example.ORG", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe", got)
+
+	got, err = release.WKDURL("Joe.Doe@Example.ORG", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe", got)
+
+	_, err = release.WKDURL("Joe.Doe", true)
+	assert.Error(t, err)
+}
+
+func TestWKDSignee(t *testing.T) {
+	signerPub, err := mock.ArmoredToByte(mock.SignerPub)
+	assert.Nil(t, err)
+	altSignerPub, err := mock.ArmoredToByte(mock.AltSignerPub)
+	assert.Nil(t, err)
+
+	// Keys by host and local part
+	keys := map[string]map[string][]byte{
+		"openpgpkey.builder.com": {
+			"bob":   signerPub,
+			"alice": altSignerPub,
+			"both":  append(append([]byte{}, altSignerPub...), signerPub...),
+		},
+		"builder.com": {
+			"bob": signerPub,
+		},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Host)
+		if host == "" {
+			host = r.Host
+		}
+		local := r.URL.Query().Get("l")
+		expected, _ := release.WKDURL(local+"@"+strings.TrimPrefix(host, "openpgpkey."), strings.HasPrefix(host, "openpgpkey."))
+		if key, ok := keys[host][local]; ok && strings.HasSuffix(expected, r.URL.RequestURI()) {
+			w.Write(key) // nolint: errcheck
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		user        string
+		key         string
+		unreachable string
+		expectErr   bool
+	}{
+		{
+			name: "Advanced method",
+			user: "bob@builder.com",
+		},
+		{
+			name: "Advanced method with key",
+			user: "bob@builder.com",
+			key:  mock.SignerFingerPrint,
+		},
+		{
+			name:        "Direct method",
+			user:        "bob@builder.com",
+			unreachable: "openpgpkey.builder.com",
+		},
+		{
+			name:      "Not in the directory",
+			user:      "carol@builder.com",
+			expectErr: true,
+		},
+		{
+			name:      "Key without identity for the email",
+			user:      "alice@builder.com",
+			expectErr: true,
+		},
+		{
+			name:      "Several keys",
+			user:      "both@builder.com",
+			expectErr: true,
+		},
+		{
+			name:      "Wrong key",
+			user:      "bob@builder.com",
+			key:       "0123456789abcdef",
+			expectErr: true,
+		},
+		{
+			name:      "Not an email",
+			user:      "bob",
+			expectErr: true,
+		},
+	}
+
+	verifier := release.NewVerifier(pgp.DefaultConfig)
+	for _, tc := range testCases {
+		s := release.NewWKDSignee(tc.user, tc.key, release.WKDHTTPClient(localClient(server, tc.unreachable)))
+		identities, err := verifier.VerifySignature(s, mock.Signed, mock.Signature)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, []string{"Bob the Builder (I build stuff) <bob@builder.com>"}, identities, tc.name)
+	}
+}